@@ -111,6 +111,7 @@ class GatewayService : Service(), NativeEngineCallback {
             "SEND_SMS" -> {
                 // TODO: Parse data and send SMS via SmsManager
             }
+            "CAPTURE_POLICY" -> TingHookNotificationListener.updateCapturePolicy(data)
         }
     }
     
@@ -9,7 +9,31 @@ import org.json.JSONObject
 class TingHookNotificationListener : NotificationListenerService() {
     companion object {
         private const val TAG = "NotifListener"
-        private val ALLOWED_PACKAGES = setOf<String>()
+        
+        @Volatile private var captureMode = "all"
+        @Volatile private var capturePackages = setOf<String>()
+        
+        fun updateCapturePolicy(data: String) {
+            try {
+                val json = JSONObject(data)
+                val packages = json.optJSONArray("packages")
+                captureMode = json.optString("mode", "all")
+                capturePackages = buildSet {
+                    if (packages != null) {
+                        for (i in 0 until packages.length()) add(packages.getString(i))
+                    }
+                }
+                Log.i(TAG, "Capture policy updated: $captureMode (${capturePackages.size} packages)")
+            } catch (e: Exception) {
+                Log.e(TAG, "Invalid capture policy", e)
+            }
+        }
+        
+        private fun isCaptured(packageName: String): Boolean = when (captureMode) {
+            "allowlist" -> packageName in capturePackages
+            "blocklist" -> packageName !in capturePackages
+            else -> true
+        }
     }
     
     override fun onNotificationPosted(sbn: StatusBarNotification) {
@@ -18,7 +42,7 @@ class TingHookNotificationListener : NotificationListenerService() {
         if (packageName == "com.tinghook.gateway") return
         if (packageName.startsWith("com.android")) return
         
-        if (ALLOWED_PACKAGES.isNotEmpty() && packageName !in ALLOWED_PACKAGES) return
+        if (!isCaptured(packageName)) return
         
         val notification = sbn.notification
         val extras = notification.extras
@@ -41,7 +65,8 @@ class TingHookNotificationListener : NotificationListenerService() {
                 put("app_name", appName)
                 put("title", title)
                 put("content", content)
-                put("timestamp", System.currentTimeMillis())
+                put("category", notification.category ?: "")
+                put("timestamp", java.time.Instant.ofEpochMilli(sbn.postTime).toString())
             }
             NativeEngine.sendMessage("""{"type":"NOTIFICATION_RECEIVED","data":$data}""")
         }
@@ -13,11 +13,19 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/config"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	cfg := config.Load()
 
+	hub, err := newHub(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up WebSocket hub: %v", err)
+	}
+	go hub.Run()
+
 	app := fiber.New(fiber.Config{
 		AppName:      "TingHook API",
 		ReadTimeout:  10 * time.Second,
@@ -29,7 +37,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Idempotency-Key",
 		AllowCredentials: true,
 	}))
 
@@ -61,3 +69,26 @@ func main() {
 
 	log.Println("Server exited gracefully")
 }
+
+// newHub joins the WebSocket cluster shared through REDIS_URL, so devices
+// connected to any replica can be reached from this one. Without a Redis URL
+// the hub only knows its own connections.
+func newHub(cfg *config.Config) (*websockets.Hub, error) {
+	if cfg.RedisURL == "" {
+		return websockets.NewHub(), nil
+	}
+
+	opts, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = websockets.NewInstanceID(hostname)
+	}
+	log.Printf("WebSocket hub joining cluster as %s", instanceID)
+
+	return websockets.NewClusterHub(websockets.NewRedisCluster(redis.NewClient(opts), instanceID)), nil
+}
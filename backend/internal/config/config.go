@@ -11,12 +11,22 @@ type Config struct {
 	Environment string
 	CORSOrigins string
 
+	// InstanceID names this replica in the WebSocket cluster; empty means
+	// one is derived from the hostname at startup
+	InstanceID string
+
 	DatabaseURL string
 	RedisURL    string
 
 	JWTSecret     string
 	JWTExpiration string
 
+	// DeviceAPIKeyAuth lets existing devices that were never issued a device
+	// token connect with the account API key. It stays on while the fleet
+	// migrates: app builds that can store a token are issued one on their
+	// next API-key AUTH. Turn it off once no device signs in with the key.
+	DeviceAPIKeyAuth bool
+
 	TwilioAccountSID string
 	TwilioAuthToken  string
 	TwilioFromNumber string
@@ -30,12 +40,16 @@ func Load() *Config {
 		Environment: getEnv("ENVIRONMENT", "development"),
 		CORSOrigins: getEnv("CORS_ORIGINS", "*"),
 
+		InstanceID: getEnv("INSTANCE_ID", ""),
+
 		DatabaseURL: getEnv("DATABASE_URL", ""),
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 
 		JWTSecret:     getEnv("JWT_SECRET", "change-me-in-production"),
 		JWTExpiration: getEnv("JWT_EXPIRATION", "24h"),
 
+		DeviceAPIKeyAuth: getEnv("DEVICE_API_KEY_AUTH", "true") == "true",
+
 		TwilioAccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
 		TwilioAuthToken:  getEnv("TWILIO_AUTH_TOKEN", ""),
 		TwilioFromNumber: getEnv("TWILIO_FROM_NUMBER", ""),
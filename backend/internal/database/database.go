@@ -38,5 +38,18 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.User{},
 		&models.Device{},
 		&models.ForwardingRule{},
+		&models.MessageLog{},
+		&models.CapturePolicy{},
+		&models.IdempotencyKey{},
+		&models.RoutingPrefix{},
+		&models.DeliveryAttempt{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+		&models.ScheduledMessage{},
+		&models.MessageTemplate{},
+		&models.SuppressedNumber{},
+		&models.DeviceCommand{},
+		&models.PairingToken{},
+		&models.DeviceTelemetry{},
 	)
 }
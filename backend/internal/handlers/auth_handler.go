@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"errors"
+	"net/url"
 	"strings"
 	"time"
 
@@ -130,6 +131,58 @@ func (h *AuthHandler) RefreshAPIKey(c *fiber.Ctx) error {
 	return c.JSON(dto.APIKeyResponse{APIKey: newKey})
 }
 
+func (h *AuthHandler) UpdateStatusCallback(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid user id"})
+	}
+
+	var req dto.UpdateStatusCallbackRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	if req.URL != "" && !isValidCallbackURL(req.URL) {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "url must be an absolute http or https URL"})
+	}
+
+	user, err := h.userService.UpdateStatusCallback(id, req.URL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to update status callback"})
+	}
+
+	return c.JSON(dto.StatusCallbackResponse{
+		URL:           user.StatusCallbackURL,
+		SigningSecret: user.CallbackSecret,
+	})
+}
+
+func (h *AuthHandler) UpdateDefaultCountry(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid user id"})
+	}
+
+	var req dto.UpdateDefaultCountryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	country, err := h.userService.UpdateDefaultCountry(id, req.Country)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedCountry) {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "unsupported country code"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to update default country"})
+	}
+
+	return c.JSON(fiber.Map{"default_country": country})
+}
+
 func (h *AuthHandler) generateToken(userID string) (string, error) {
 	claims := Claims{
 		UserID: userID,
@@ -150,9 +203,20 @@ func toUserDTO(user *models.User) dto.UserDTO {
 		APIKey:           user.APIKey,
 		SubscriptionPlan: user.SubscriptionPlan,
 		Credits:          user.Credits,
+
+		StatusCallbackURL: user.StatusCallbackURL,
+		DefaultCountry:    user.DefaultCountry,
 	}
 }
 
 func isValidEmail(email string) bool {
 	return strings.Contains(email, "@") && strings.Contains(email, ".")
 }
+
+func isValidCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
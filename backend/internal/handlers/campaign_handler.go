@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+type CampaignHandler struct {
+	campaignService services.CampaignService
+	templateService services.TemplateService
+}
+
+func NewCampaignHandler(campaignService services.CampaignService, templateService services.TemplateService) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		templateService: templateService,
+	}
+}
+
+func (h *CampaignHandler) BulkSend(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var req dto.BulkSendRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	// A template is narrowed to one locale for the whole campaign; its default
+	// values are folded into every recipient's variables below
+	var template *services.ResolvedTemplate
+	if req.TemplateID != nil {
+		if req.Content != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content and template_id are mutually exclusive"})
+		}
+
+		resolved, err := h.templateService.Resolve(*req.TemplateID, user.ID, req.Locale)
+		if err != nil {
+			if errors.Is(err, services.ErrTemplateNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "template not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to load template"})
+		}
+		template = resolved
+		req.Content = resolved.Body
+	}
+
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content is required"})
+	}
+
+	if len(req.Recipients) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "recipients is required"})
+	}
+
+	if len(req.Recipients) > services.MaxCampaignRecipients {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "at most 10000 recipients per request"})
+	}
+
+	if req.StatusCallbackURL != "" && !isValidCallbackURL(req.StatusCallbackURL) {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "status_callback_url must be an absolute http or https URL"})
+	}
+
+	recipients := make([]services.CampaignRecipientInput, len(req.Recipients))
+	for i, recipient := range req.Recipients {
+		number, err := services.NormalizePhone(recipient.Phone, user.DefaultCountry)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{
+				Error: fmt.Sprintf("recipient %d: %v", i, err),
+			})
+		}
+		variables := recipient.Variables
+		if template != nil {
+			variables = template.Variables(variables)
+		}
+		recipients[i] = services.CampaignRecipientInput{
+			Phone:     number.E164,
+			Variables: variables,
+		}
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = user.RoutingStrategy
+	}
+	if strategy == "" {
+		strategy = models.RoutingRoundRobin
+	}
+	if err := services.ValidateRoutingStrategy(strategy); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid routing strategy"})
+	}
+
+	maxAttempts := req.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = user.FailoverMaxAttempts
+	}
+
+	content := req.Content
+	if user.SubscriptionPlan == "free" {
+		content = req.Content + freePlanSignature
+	}
+
+	campaign, err := h.campaignService.Create(user.ID, &services.CreateCampaignRequest{
+		Name:          req.Name,
+		Content:       content,
+		Recipients:    recipients,
+		Strategy:      strategy,
+		RatePerMinute: req.RatePerMinute,
+		TTL:           time.Duration(req.TTL) * time.Second,
+		MaxAttempts:   maxAttempts,
+		Transliterate: req.Transliterate,
+
+		StatusCallbackURL: req.StatusCallbackURL,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMissingVariable), errors.Is(err, services.ErrContentTooLong):
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidCampaignRate):
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "rate_per_minute must be between 1 and 120"})
+		case errors.Is(err, services.ErrInvalidTTL):
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "ttl must be between 0 and 259200 seconds"})
+		case errors.Is(err, services.ErrInvalidMaxAttempts):
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "max_attempts must be between 1 and 5"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to create campaign"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(dto.ToCampaignDTO(campaign))
+}
+
+func (h *CampaignHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	params := new(dto.CampaignQueryParams)
+	if err := c.QueryParser(params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid query parameters"})
+	}
+	params.Normalize()
+
+	campaigns, total, err := h.campaignService.List(user.ID, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch campaigns"})
+	}
+
+	return c.JSON(dto.PaginatedCampaigns{
+		Data:       dto.ToCampaignDTOList(campaigns),
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	})
+}
+
+func (h *CampaignHandler) Get(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid campaign id"})
+	}
+
+	campaign, err := h.campaignService.Get(id, user.ID)
+	if err != nil {
+		return h.campaignError(c, err, "failed to fetch campaign")
+	}
+
+	return h.respondWithProgress(c, campaign)
+}
+
+func (h *CampaignHandler) Pause(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.campaignService.Pause)
+}
+
+func (h *CampaignHandler) Resume(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.campaignService.Resume)
+}
+
+func (h *CampaignHandler) Cancel(c *fiber.Ctx) error {
+	return h.changeStatus(c, h.campaignService.Cancel)
+}
+
+// Export streams one CSV row per recipient with the outcome of its message.
+func (h *CampaignHandler) Export(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid campaign id"})
+	}
+
+	results, err := h.campaignService.Results(id, user.ID)
+	if err != nil {
+		return h.campaignError(c, err, "failed to export campaign")
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="campaign-%s.csv"`, id))
+
+	w := csv.NewWriter(c.Response().BodyWriter())
+	_ = w.Write([]string{"phone", "request_id", "status", "device_id", "sim_slot", "error", "submitted_at", "sent_at", "delivered_at", "failed_at"})
+
+	for _, result := range results {
+		recipient := result.Recipient
+		row := []string{recipient.Phone, recipient.RequestID, string(recipient.Status), "", "", recipient.Error, formatCSVTime(recipient.SubmittedAt), "", "", ""}
+
+		if msgLog := result.Log; msgLog != nil {
+			row[2] = string(msgLog.Status)
+			if msgLog.DeviceID != nil {
+				row[3] = msgLog.DeviceID.String()
+			}
+			row[4] = strconv.Itoa(msgLog.SimSlot)
+			row[5] = msgLog.ErrorMessage
+			row[7] = formatCSVTime(msgLog.SentAt)
+			row[8] = formatCSVTime(msgLog.DeliveredAt)
+			row[9] = formatCSVTime(msgLog.FailedAt)
+		}
+
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func (h *CampaignHandler) changeStatus(c *fiber.Ctx, change func(id, userID uuid.UUID) (*models.Campaign, error)) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid campaign id"})
+	}
+
+	campaign, err := change(id, user.ID)
+	if err != nil {
+		return h.campaignError(c, err, "failed to update campaign")
+	}
+
+	return h.respondWithProgress(c, campaign)
+}
+
+func (h *CampaignHandler) respondWithProgress(c *fiber.Ctx, campaign *models.Campaign) error {
+	progress, err := h.campaignService.Progress(campaign.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch campaign progress"})
+	}
+
+	resp := dto.ToCampaignDTO(campaign)
+	resp.Progress = &dto.CampaignProgressDTO{
+		Total:     progress.Total,
+		Pending:   progress.Pending,
+		Queued:    progress.Queued,
+		Sending:   progress.Sending,
+		Sent:      progress.Sent,
+		Delivered: progress.Delivered,
+		Failed:    progress.Failed,
+		Expired:   progress.Expired,
+		Rejected:  progress.Rejected,
+		Cancelled: progress.Cancelled,
+	}
+	return c.JSON(resp)
+}
+
+func (h *CampaignHandler) campaignError(c *fiber.Ctx, err error, fallback string) error {
+	if errors.Is(err, services.ErrCampaignNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "campaign not found"})
+	}
+	if errors.Is(err, services.ErrCampaignStateConflict) {
+		return c.Status(fiber.StatusConflict).JSON(dto.ErrorResponse{Error: "campaign cannot change to the requested state"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: fallback})
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
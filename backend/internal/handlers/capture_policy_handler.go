@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+)
+
+type CapturePolicyHandler struct {
+	hub           *websockets.Hub
+	policyService services.CapturePolicyService
+}
+
+func NewCapturePolicyHandler(hub *websockets.Hub, policyService services.CapturePolicyService) *CapturePolicyHandler {
+	return &CapturePolicyHandler{
+		hub:           hub,
+		policyService: policyService,
+	}
+}
+
+func (h *CapturePolicyHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	policies := router.Group("/capture-policies", authMiddleware)
+	policies.Get("/", h.List)
+	policies.Put("/", h.Set)
+	policies.Delete("/:id", h.Delete)
+}
+
+func (h *CapturePolicyHandler) List(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	policies, err := h.policyService.ListByUser(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch capture policies",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"policies": dto.ToCapturePolicyDTOList(policies),
+	})
+}
+
+func (h *CapturePolicyHandler) Set(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.SetCapturePolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	policy, err := h.policyService.Set(userID, &services.SetCapturePolicyRequest{
+		DeviceID: req.DeviceID,
+		Mode:     req.Mode,
+		Packages: req.Packages,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCaptureMode) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "mode must be 'allowlist' or 'blocklist'",
+			})
+		}
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "device not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to save capture policy",
+		})
+	}
+
+	go websockets.PushCapturePolicy(h.hub, h.policyService, userID, policy.DeviceID)
+
+	return c.JSON(fiber.Map{
+		"policy": dto.ToCapturePolicyDTO(policy),
+	})
+}
+
+func (h *CapturePolicyHandler) Delete(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid capture policy id",
+		})
+	}
+
+	policy, err := h.policyService.Delete(uint(id), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrCapturePolicyNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "capture policy not found",
+			})
+		}
+		if errors.Is(err, services.ErrCapturePolicyAccessDenied) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "access denied",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete capture policy",
+		})
+	}
+
+	go websockets.PushCapturePolicy(h.hub, h.policyService, userID, policy.DeviceID)
+
+	return c.JSON(fiber.Map{
+		"message": "capture policy deleted",
+	})
+}
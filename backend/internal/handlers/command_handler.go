@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+type CommandHandler struct {
+	commands services.CommandService
+	control  services.DeviceControlService
+}
+
+func NewCommandHandler(commands services.CommandService, control services.DeviceControlService) *CommandHandler {
+	return &CommandHandler{
+		commands: commands,
+		control:  control,
+	}
+}
+
+// ListByDevice shows the delivery state of the commands sent to a device,
+// newest first.
+func (h *CommandHandler) ListByDevice(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid device id"})
+	}
+
+	var params dto.CommandQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid query parameters"})
+	}
+	params.Normalize()
+
+	status := models.CommandStatus(params.Status)
+	switch status {
+	case "", models.CommandPending, models.CommandAcked, models.CommandCompleted, models.CommandFailed:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "status must be pending, acked, completed or failed"})
+	}
+
+	cmds, total, err := h.commands.List(user.ID, deviceID, status, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "device not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch commands"})
+	}
+
+	return c.JSON(dto.PaginatedCommands{
+		Data:       dto.ToCommandDTOList(cmds),
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	})
+}
+
+func (h *CommandHandler) Get(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid command id"})
+	}
+
+	cmd, err := h.commands.Get(user.ID, id)
+	if err != nil {
+		if errors.Is(err, services.ErrCommandNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "command not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch command"})
+	}
+
+	return c.JSON(dto.ToCommandDTO(cmd))
+}
+
+// RequestStatus asks the device for a status report. Like every remote
+// control command it answers 202; the report is read back from the command.
+func (h *CommandHandler) RequestStatus(c *fiber.Ctx) error {
+	return h.dispatch(c, func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+		return h.control.RequestStatus(userID, deviceID)
+	})
+}
+
+func (h *CommandHandler) Restart(c *fiber.Ctx) error {
+	return h.dispatch(c, func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+		return h.control.Restart(userID, deviceID)
+	})
+}
+
+func (h *CommandHandler) UpdateConfig(c *fiber.Ctx) error {
+	var req dto.UpdateDeviceConfigRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	return h.dispatch(c, func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+		return h.control.UpdateConfig(userID, deviceID, &services.DeviceConfig{
+			PingInterval:        req.PingInterval,
+			LogLevel:            req.LogLevel,
+			SMSCapture:          req.SMSCapture,
+			NotificationCapture: req.NotificationCapture,
+		})
+	})
+}
+
+func (h *CommandHandler) UploadLogs(c *fiber.Ctx) error {
+	var req dto.UploadLogsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	return h.dispatch(c, func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+		return h.control.UploadLogs(userID, deviceID, req.UploadURL)
+	})
+}
+
+// RunUSSD runs a USSD code such as a balance check; the network's reply is
+// stored as the command result.
+func (h *CommandHandler) RunUSSD(c *fiber.Ctx) error {
+	var req dto.RunUSSDRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	return h.dispatch(c, func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+		return h.control.RunUSSD(userID, deviceID, req.Code, req.SimSlot)
+	})
+}
+
+func (h *CommandHandler) dispatch(c *fiber.Ctx, send func(userID, deviceID uuid.UUID) (*models.DeviceCommand, error)) error {
+	user := c.Locals("user").(*models.User)
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid device id"})
+	}
+
+	cmd, err := send(user.ID, deviceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeviceNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "device not found"})
+		case errors.Is(err, services.ErrDeviceOffline):
+			return c.Status(fiber.StatusConflict).JSON(dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrCommandNotSupported):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(dto.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidDeviceConfig),
+			errors.Is(err, services.ErrInvalidUploadURL),
+			errors.Is(err, services.ErrInvalidUSSDCode),
+			errors.Is(err, services.ErrInvalidSimSlot):
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to send command"})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(dto.ToCommandDTO(cmd))
+}
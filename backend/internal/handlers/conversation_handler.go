@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+type ConversationHandler struct {
+	conversations services.ConversationService
+}
+
+func NewConversationHandler(conversations services.ConversationService) *ConversationHandler {
+	return &ConversationHandler{conversations: conversations}
+}
+
+func (h *ConversationHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var params dto.ConversationQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid query parameters"})
+	}
+	params.Normalize()
+
+	listParams := &services.ThreadListParams{
+		Search:     params.Search,
+		UnreadOnly: params.UnreadOnly,
+		Limit:      params.Limit,
+		Offset:     (params.Page - 1) * params.Limit,
+	}
+	if params.DeviceID != "" {
+		deviceID, err := uuid.Parse(params.DeviceID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid device_id format"})
+		}
+		listParams.DeviceID = &deviceID
+	}
+
+	threads, total, err := h.conversations.List(user.ID, listParams)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch conversations"})
+	}
+
+	data := make([]dto.ConversationDTO, len(threads))
+	for i := range threads {
+		data[i] = toConversationDTO(&threads[i])
+	}
+
+	return c.JSON(dto.PaginatedConversations{
+		Data:       data,
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	})
+}
+
+// Get returns the conversation with a page of its messages, inbound and
+// outbound merged in chronological order.
+func (h *ConversationHandler) Get(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	threadID, err := url.PathUnescape(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid conversation id"})
+	}
+
+	var params dto.ConversationMessagesParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid query parameters"})
+	}
+	params.Normalize()
+
+	thread, err := h.conversations.Get(user.ID, threadID)
+	if err != nil {
+		return conversationError(c, err, "failed to fetch conversation")
+	}
+
+	messages, err := h.conversations.Messages(user.ID, threadID, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		return conversationError(c, err, "failed to fetch conversation")
+	}
+
+	return c.JSON(dto.ConversationDetail{
+		ConversationDTO: toConversationDTO(thread),
+		Messages:        dto.ToLogDTOList(messages),
+		Page:            params.Page,
+		Limit:           params.Limit,
+	})
+}
+
+func (h *ConversationHandler) MarkRead(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	threadID, err := url.PathUnescape(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid conversation id"})
+	}
+
+	marked, err := h.conversations.MarkRead(user.ID, threadID)
+	if err != nil {
+		return conversationError(c, err, "failed to mark conversation read")
+	}
+
+	return c.JSON(fiber.Map{
+		"marked": marked,
+	})
+}
+
+// Reply sends an SMS to the remote number from the device and SIM the
+// conversation runs over.
+func (h *ConversationHandler) Reply(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	threadID, err := url.PathUnescape(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid conversation id"})
+	}
+
+	var req dto.ReplyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	if req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content is required"})
+	}
+
+	if req.TTL < 0 || time.Duration(req.TTL)*time.Second > services.MaxOutboundTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "ttl must be between 0 and 259200 seconds"})
+	}
+
+	content := req.Content
+	if req.Transliterate {
+		content = sms.Transliterate(content)
+	}
+	if user.SubscriptionPlan == "free" {
+		content += freePlanSignature
+	}
+
+	if _, err := services.CheckContent(content); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+	}
+
+	log, err := h.conversations.Reply(user.ID, threadID, &services.ReplyRequest{
+		Content: content,
+		TTL:     time.Duration(req.TTL) * time.Second,
+	})
+	if errors.Is(err, services.ErrRecipientSuppressed) {
+		return suppressedError(c)
+	}
+	if err != nil {
+		return conversationError(c, err, "failed to queue reply")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(dto.ToLogDTO(log))
+}
+
+func conversationError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidThreadID):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrThreadNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: fallback})
+	}
+}
+
+func toConversationDTO(thread *services.Thread) dto.ConversationDTO {
+	conversation := dto.ConversationDTO{
+		ID:            thread.ID,
+		DeviceID:      thread.DeviceID.String(),
+		SimSlot:       thread.SimSlot,
+		RemoteNumber:  thread.RemoteNumber,
+		LastMessageAt: thread.LastMessageAt.Format(time.RFC3339),
+		MessageCount:  thread.MessageCount,
+		UnreadCount:   thread.UnreadCount,
+	}
+	if thread.LastMessage != nil {
+		last := dto.ToLogDTO(thread.LastMessage)
+		conversation.LastMessage = &last
+	}
+	return conversation
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+	ws "github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+)
+
+type DeviceHandler struct {
+	hub           *ws.Hub
+	deviceService services.DeviceService
+	telemetry     services.TelemetryService
+}
+
+func NewDeviceHandler(hub *ws.Hub, deviceService services.DeviceService, telemetry services.TelemetryService) *DeviceHandler {
+	return &DeviceHandler{
+		hub:           hub,
+		deviceService: deviceService,
+		telemetry:     telemetry,
+	}
+}
+
+func (h *DeviceHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	devices := router.Group("/devices", authMiddleware)
+	devices.Get("/", h.List)
+	devices.Post("/pair", h.Pair)
+	devices.Post("/pairing-token", h.CreatePairingToken)
+	devices.Get("/pairing-status/:token", h.PairingStatus)
+	devices.Get("/health", h.Health)
+	devices.Get("/:id/telemetry", h.Telemetry)
+	devices.Post("/:id/token", h.RotateToken)
+	devices.Delete("/:id/token", h.RevokeToken)
+}
+
+func (h *DeviceHandler) List(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	devices, err := h.deviceService.ListByUser(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch devices",
+		})
+	}
+
+	return c.JSON(dto.ToDeviceDTOList(devices))
+}
+
+// Pair registers a device and returns the token it authenticates its socket
+// with. The token is not stored in clear and cannot be shown again.
+func (h *DeviceHandler) Pair(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.PairDeviceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	device, token, err := h.deviceService.Pair(userID, req.Name, req.DeviceUID)
+	if err != nil {
+		if errors.Is(err, services.ErrDuplicateDeviceUID) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "device is paired with another account",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to pair device",
+		})
+	}
+
+	// A re-paired device must reconnect with its new token
+	h.disconnect(device.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(dto.DeviceTokenResponse{
+		DeviceID:    device.ID.String(),
+		DeviceUID:   device.DeviceUID,
+		DeviceToken: token,
+	})
+}
+
+// CreatePairingToken issues the one-time token for the pairing QR code. The
+// phone redeems it in its AUTH frame within services.PairingTokenTTL.
+func (h *DeviceHandler) CreatePairingToken(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	// The dashboard posts without a body when no name was chosen
+	var req dto.PairingTokenRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+	}
+	if len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name must be at most 100 characters",
+		})
+	}
+
+	pairing, token, err := h.deviceService.GeneratePairingToken(userID, req.Name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to generate pairing token",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dto.PairingTokenResponse{
+		Token:      token,
+		DeviceName: pairing.DeviceName,
+		ExpiresAt:  pairing.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// PairingStatus lets the dashboard poll until the phone has redeemed the
+// token shown in the QR code.
+func (h *DeviceHandler) PairingStatus(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	pairing, err := h.deviceService.GetPairingStatus(userID, c.Params("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrPairingTokenNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "pairing token not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch pairing status",
+		})
+	}
+
+	return c.JSON(dto.ToPairingStatusResponse(pairing, time.Now()))
+}
+
+func (h *DeviceHandler) RotateToken(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid device id",
+		})
+	}
+
+	token, err := h.deviceService.IssueToken(userID, deviceID)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "device not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to issue device token",
+		})
+	}
+
+	h.disconnect(deviceID)
+
+	device, err := h.deviceService.GetByID(deviceID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch device",
+		})
+	}
+
+	return c.JSON(dto.DeviceTokenResponse{
+		DeviceID:    device.ID.String(),
+		DeviceUID:   device.DeviceUID,
+		DeviceToken: token,
+	})
+}
+
+// RevokeToken cuts off one device: its token stops working, its socket is
+// closed and the account API key no longer lets it back in.
+func (h *DeviceHandler) RevokeToken(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid device id",
+		})
+	}
+
+	if err := h.deviceService.RevokeToken(userID, deviceID); err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "device not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to revoke device",
+		})
+	}
+
+	h.disconnect(deviceID)
+
+	return c.JSON(fiber.Map{
+		"message": "device access revoked",
+	})
+}
+
+// Health lists every device with its latest telemetry and its uptime over
+// the chosen period.
+func (h *DeviceHandler) Health(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var params dto.HealthQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid query parameters",
+		})
+	}
+	if params.Period == "" {
+		params.Period = "24h"
+	}
+	period, ok := dto.HealthPeriods[params.Period]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "period must be 24h, 7d or 30d",
+		})
+	}
+
+	health, err := h.telemetry.Health(userID, period)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch device health",
+		})
+	}
+
+	resp := dto.DeviceHealthResponse{
+		Period:  params.Period,
+		Devices: make([]dto.DeviceHealthDTO, len(health)),
+	}
+	for i := range health {
+		resp.Devices[i] = dto.DeviceHealthDTO{
+			Device:        dto.ToDeviceDTO(&health[i].Device),
+			UptimePercent: health[i].UptimePercent,
+			Latest:        dto.ToTelemetrySnapshotDTO(health[i].Latest),
+		}
+	}
+	return c.JSON(resp)
+}
+
+// Telemetry returns the chart series of one device: battery, signal,
+// charging, network and queue depth per bucket, plus its uptime over the
+// range.
+func (h *DeviceHandler) Telemetry(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	deviceID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid device id",
+		})
+	}
+
+	var params dto.TelemetryQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid query parameters",
+		})
+	}
+
+	to := time.Now()
+	if params.To != "" {
+		if to, err = time.Parse(time.RFC3339, params.To); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "to must be an RFC 3339 time",
+			})
+		}
+	}
+	from := to.Add(-24 * time.Hour)
+	if params.From != "" {
+		if from, err = time.Parse(time.RFC3339, params.From); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "from must be an RFC 3339 time",
+			})
+		}
+	}
+
+	series, err := h.telemetry.Series(userID, deviceID, from, to, params.Bucket)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeviceNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "device not found",
+			})
+		case errors.Is(err, services.ErrInvalidTelemetryRange), errors.Is(err, services.ErrInvalidTelemetryBucket):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch telemetry",
+		})
+	}
+
+	resp := dto.TelemetrySeriesResponse{
+		DeviceID:      series.DeviceID.String(),
+		From:          series.From.Format(time.RFC3339),
+		To:            series.To.Format(time.RFC3339),
+		Bucket:        series.Bucket,
+		UptimePercent: series.UptimePercent,
+		Points:        make([]dto.TelemetryPointDTO, len(series.Points)),
+	}
+	for i := range series.Points {
+		resp.Points[i] = dto.ToTelemetryPointDTO(&series.Points[i])
+	}
+	return c.JSON(resp)
+}
+
+func (h *DeviceHandler) disconnect(deviceID uuid.UUID) {
+	err := h.hub.Disconnect(deviceID)
+	if err != nil && !errors.Is(err, ws.ErrDeviceNotConnected) {
+		log.Printf("failed to disconnect device %s: %v", deviceID, err)
+	}
+}
@@ -21,12 +21,29 @@ type UserDTO struct {
 	APIKey           string `json:"api_key"`
 	SubscriptionPlan string `json:"subscription_plan"`
 	Credits          int    `json:"credits"`
+
+	StatusCallbackURL string `json:"status_callback_url,omitempty"`
+	DefaultCountry    string `json:"default_country"`
 }
 
 type APIKeyResponse struct {
 	APIKey string `json:"api_key"`
 }
 
+type UpdateStatusCallbackRequest struct {
+	URL string `json:"url" validate:"omitempty,url"`
+}
+
+type StatusCallbackResponse struct {
+	URL           string `json:"url"`
+	SigningSecret string `json:"signing_secret"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+type UpdateDefaultCountryRequest struct {
+	Country string `json:"country" validate:"required,len=2"`
 }
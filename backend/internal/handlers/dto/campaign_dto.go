@@ -0,0 +1,106 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type BulkRecipient struct {
+	Phone     string            `json:"phone" validate:"required"`
+	Variables map[string]string `json:"variables"`
+}
+
+type BulkSendRequest struct {
+	Name          string          `json:"name" validate:"max=255"`
+	Content       string          `json:"content" validate:"required_without=TemplateID"`
+	TemplateID    *uint           `json:"template_id"`
+	Locale        string          `json:"locale"`
+	Recipients    []BulkRecipient `json:"recipients" validate:"required,min=1,max=10000"`
+	Strategy      string          `json:"strategy" validate:"omitempty,oneof=round_robin least_loaded battery_aware sticky carrier_prefix"`
+	RatePerMinute int             `json:"rate_per_minute" validate:"omitempty,min=1,max=120"`
+	TTL           int             `json:"ttl" validate:"omitempty,min=60,max=259200"`
+	MaxAttempts   int             `json:"max_attempts" validate:"omitempty,min=1,max=5"`
+	Transliterate bool            `json:"transliterate"`
+
+	StatusCallbackURL string `json:"status_callback_url" validate:"omitempty,url"`
+}
+
+type CampaignQueryParams struct {
+	Page  int `query:"page"`
+	Limit int `query:"limit"`
+}
+
+func (p *CampaignQueryParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+}
+
+type CampaignProgressDTO struct {
+	Total     int64 `json:"total"`
+	Pending   int64 `json:"pending"`
+	Queued    int64 `json:"queued"`
+	Sending   int64 `json:"sending"`
+	Sent      int64 `json:"sent"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+	Expired   int64 `json:"expired"`
+	Rejected  int64 `json:"rejected"`
+	Cancelled int64 `json:"cancelled"`
+}
+
+type CampaignDTO struct {
+	ID            string               `json:"id"`
+	Name          string               `json:"name"`
+	Content       string               `json:"content"`
+	Status        string               `json:"status"`
+	Total         int                  `json:"total"`
+	Segments      int                  `json:"segments"`
+	Strategy      string               `json:"strategy,omitempty"`
+	RatePerMinute int                  `json:"rate_per_minute"`
+	MaxAttempts   int                  `json:"max_attempts"`
+	Transliterate bool                 `json:"transliterate"`
+	CreatedAt     string               `json:"created_at"`
+	CompletedAt   string               `json:"completed_at,omitempty"`
+	Progress      *CampaignProgressDTO `json:"progress,omitempty"`
+}
+
+type PaginatedCampaigns struct {
+	Data       []CampaignDTO `json:"data"`
+	Total      int64         `json:"total"`
+	Page       int           `json:"page"`
+	Limit      int           `json:"limit"`
+	TotalPages int           `json:"total_pages"`
+}
+
+func ToCampaignDTO(campaign *models.Campaign) CampaignDTO {
+	return CampaignDTO{
+		ID:            campaign.ID.String(),
+		Name:          campaign.Name,
+		Content:       campaign.Content,
+		Status:        string(campaign.Status),
+		Total:         campaign.Total,
+		Segments:      campaign.Segments,
+		Strategy:      campaign.Strategy,
+		RatePerMinute: campaign.RatePerMinute,
+		MaxAttempts:   campaign.MaxAttempts,
+		Transliterate: campaign.Transliterate,
+		CreatedAt:     campaign.CreatedAt.Format(time.RFC3339),
+		CompletedAt:   formatOptionalTime(campaign.CompletedAt),
+	}
+}
+
+func ToCampaignDTOList(campaigns []models.Campaign) []CampaignDTO {
+	dtos := make([]CampaignDTO, len(campaigns))
+	for i, campaign := range campaigns {
+		dtos[i] = ToCampaignDTO(&campaign)
+	}
+	return dtos
+}
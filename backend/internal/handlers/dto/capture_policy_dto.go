@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type SetCapturePolicyRequest struct {
+	DeviceID *string  `json:"device_id"`
+	Mode     string   `json:"mode" validate:"omitempty,oneof=allowlist blocklist"`
+	Packages []string `json:"packages"`
+}
+
+type CapturePolicyDTO struct {
+	ID        uint     `json:"id"`
+	DeviceID  *string  `json:"device_id,omitempty"`
+	Mode      string   `json:"mode"`
+	Packages  []string `json:"packages"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+func ToCapturePolicyDTO(policy *models.CapturePolicy) *CapturePolicyDTO {
+	dto := &CapturePolicyDTO{
+		ID:        policy.ID,
+		Mode:      policy.Mode,
+		Packages:  policy.Packages,
+		UpdatedAt: policy.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if dto.Packages == nil {
+		dto.Packages = []string{}
+	}
+
+	if policy.DeviceID != nil {
+		deviceIDStr := policy.DeviceID.String()
+		dto.DeviceID = &deviceIDStr
+	}
+
+	return dto
+}
+
+func ToCapturePolicyDTOList(policies []models.CapturePolicy) []CapturePolicyDTO {
+	dtos := make([]CapturePolicyDTO, len(policies))
+	for i, policy := range policies {
+		dtos[i] = *ToCapturePolicyDTO(&policy)
+	}
+	return dtos
+}
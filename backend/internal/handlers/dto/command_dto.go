@@ -0,0 +1,100 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type CommandQueryParams struct {
+	Page   int    `query:"page"`
+	Limit  int    `query:"limit"`
+	Status string `query:"status"`
+}
+
+func (p *CommandQueryParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+}
+
+type CommandDTO struct {
+	ID        string `json:"id"`
+	DeviceID  string `json:"device_id"`
+	Type      string `json:"type"`
+	Ref       string `json:"ref,omitempty"`
+	Status    string `json:"status"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	SentAt    string `json:"sent_at"`
+	AckedAt   string `json:"acked_at,omitempty"`
+	ExpiresAt string `json:"expires_at"`
+
+	Result      json.RawMessage `json:"result,omitempty"`
+	CompletedAt string          `json:"completed_at,omitempty"`
+}
+
+type UpdateDeviceConfigRequest struct {
+	PingInterval        *int    `json:"ping_interval"`
+	LogLevel            *string `json:"log_level"`
+	SMSCapture          *bool   `json:"sms_capture"`
+	NotificationCapture *bool   `json:"notification_capture"`
+}
+
+type UploadLogsRequest struct {
+	UploadURL string `json:"upload_url" validate:"required,url"`
+}
+
+type RunUSSDRequest struct {
+	Code    string `json:"code" validate:"required"`
+	SimSlot int    `json:"sim_slot"`
+}
+
+type PaginatedCommands struct {
+	Data       []CommandDTO `json:"data"`
+	Total      int64        `json:"total"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	TotalPages int          `json:"total_pages"`
+}
+
+func ToCommandDTO(cmd *models.DeviceCommand) CommandDTO {
+	dto := CommandDTO{
+		ID:        cmd.ID.String(),
+		DeviceID:  cmd.DeviceID.String(),
+		Type:      cmd.Type,
+		Ref:       cmd.Ref,
+		Status:    string(cmd.Status),
+		Attempts:  cmd.Attempts,
+		Error:     cmd.Error,
+		CreatedAt: cmd.CreatedAt.Format(time.RFC3339),
+		SentAt:    cmd.SentAt.Format(time.RFC3339),
+		ExpiresAt: cmd.ExpiresAt.Format(time.RFC3339),
+	}
+	if cmd.AckedAt != nil {
+		dto.AckedAt = cmd.AckedAt.Format(time.RFC3339)
+	}
+	if cmd.CompletedAt != nil {
+		dto.CompletedAt = cmd.CompletedAt.Format(time.RFC3339)
+	}
+	if json.Valid(cmd.Result) {
+		dto.Result = cmd.Result
+	}
+	return dto
+}
+
+func ToCommandDTOList(cmds []models.DeviceCommand) []CommandDTO {
+	dtos := make([]CommandDTO, len(cmds))
+	for i := range cmds {
+		dtos[i] = ToCommandDTO(&cmds[i])
+	}
+	return dtos
+}
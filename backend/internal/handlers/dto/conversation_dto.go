@@ -0,0 +1,70 @@
+package dto
+
+type ConversationQueryParams struct {
+	Page       int    `query:"page"`
+	Limit      int    `query:"limit"`
+	DeviceID   string `query:"device_id"`
+	Search     string `query:"q"`
+	UnreadOnly bool   `query:"unread"`
+}
+
+func (p *ConversationQueryParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+}
+
+type ConversationMessagesParams struct {
+	Page  int `query:"page"`
+	Limit int `query:"limit"`
+}
+
+func (p *ConversationMessagesParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 50
+	}
+	if p.Limit > 200 {
+		p.Limit = 200
+	}
+}
+
+type ReplyRequest struct {
+	Content       string `json:"content" validate:"required"`
+	TTL           int    `json:"ttl"`
+	Transliterate bool   `json:"transliterate"`
+}
+
+type ConversationDTO struct {
+	ID            string  `json:"id"`
+	DeviceID      string  `json:"device_id"`
+	SimSlot       int     `json:"sim_slot"`
+	RemoteNumber  string  `json:"remote_number"`
+	LastMessage   *LogDTO `json:"last_message,omitempty"`
+	LastMessageAt string  `json:"last_message_at"`
+	MessageCount  int64   `json:"message_count"`
+	UnreadCount   int64   `json:"unread_count"`
+}
+
+type PaginatedConversations struct {
+	Data       []ConversationDTO `json:"data"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+	TotalPages int               `json:"total_pages"`
+}
+
+type ConversationDetail struct {
+	ConversationDTO
+	Messages []LogDTO `json:"messages"`
+	Page     int      `json:"page"`
+	Limit    int      `json:"limit"`
+}
@@ -0,0 +1,106 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type PairDeviceRequest struct {
+	Name      string `json:"name" validate:"max=100"`
+	DeviceUID string `json:"device_uid" validate:"max=255"`
+}
+
+// DeviceTokenResponse carries a device token; it is only ever returned once.
+type DeviceTokenResponse struct {
+	DeviceID    string `json:"device_id"`
+	DeviceUID   string `json:"device_uid"`
+	DeviceToken string `json:"device_token"`
+}
+
+type PairingTokenRequest struct {
+	Name string `json:"name" validate:"max=100"`
+}
+
+// PairingTokenResponse carries the secret to encode in the pairing QR code;
+// like device tokens it is only returned once.
+type PairingTokenResponse struct {
+	Token      string `json:"token"`
+	DeviceName string `json:"device_name,omitempty"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+type PairingStatusResponse struct {
+	Paired     bool   `json:"paired"`
+	Status     string `json:"status"`
+	DeviceID   string `json:"device_id,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	ExpiresAt  string `json:"expires_at"`
+	PairedAt   string `json:"paired_at,omitempty"`
+}
+
+func ToPairingStatusResponse(token *models.PairingToken, now time.Time) PairingStatusResponse {
+	status := token.CurrentStatus(now)
+	resp := PairingStatusResponse{
+		Paired:     status == models.PairingPaired && token.DeviceID != nil,
+		Status:     string(status),
+		DeviceName: token.DeviceName,
+		ExpiresAt:  token.ExpiresAt.Format(time.RFC3339),
+	}
+	if token.DeviceID != nil {
+		resp.DeviceID = token.DeviceID.String()
+	}
+	if token.PairedAt != nil {
+		resp.PairedAt = token.PairedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+type DeviceDTO struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	DeviceUID       string `json:"device_uid"`
+	Status          string `json:"status"`
+	BatteryLevel    int    `json:"battery_level"`
+	SimCount        int    `json:"sim_count"`
+	AppVersion      string `json:"app_version,omitempty"`
+	ProtocolVersion int    `json:"protocol_version"`
+	HasToken        bool   `json:"has_token"`
+	TokenIssuedAt   string `json:"token_issued_at,omitempty"`
+	RevokedAt       string `json:"revoked_at,omitempty"`
+	LastSeenAt      string `json:"last_seen_at,omitempty"`
+	CreatedAt       string `json:"created_at"`
+}
+
+func ToDeviceDTO(device *models.Device) DeviceDTO {
+	dto := DeviceDTO{
+		ID:              device.ID.String(),
+		Name:            device.Name,
+		DeviceUID:       device.DeviceUID,
+		Status:          device.Status,
+		BatteryLevel:    device.BatteryLevel,
+		SimCount:        device.SimCount,
+		AppVersion:      device.AppVersion,
+		ProtocolVersion: device.ProtocolVersion,
+		HasToken:        device.TokenHash != "",
+		CreatedAt:       device.CreatedAt.Format(time.RFC3339),
+	}
+	if device.TokenIssuedAt != nil {
+		dto.TokenIssuedAt = device.TokenIssuedAt.Format(time.RFC3339)
+	}
+	if device.RevokedAt != nil {
+		dto.RevokedAt = device.RevokedAt.Format(time.RFC3339)
+	}
+	if device.LastSeenAt != nil {
+		dto.LastSeenAt = device.LastSeenAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+func ToDeviceDTOList(devices []models.Device) []DeviceDTO {
+	dtos := make([]DeviceDTO, len(devices))
+	for i := range devices {
+		dtos[i] = ToDeviceDTO(&devices[i])
+	}
+	return dtos
+}
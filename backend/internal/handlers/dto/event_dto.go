@@ -0,0 +1,24 @@
+package dto
+
+// DeviceEventData is the payload of device.online, device.offline and
+// device.battery events.
+type DeviceEventData struct {
+	DeviceID string `json:"device_id"`
+	Status   string `json:"status"`
+	Battery  *int   `json:"battery,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// WebhookResultData is the payload of webhook.result events, one per
+// delivery attempt.
+type WebhookResultData struct {
+	RuleID     uint   `json:"rule_id"`
+	LogID      uint   `json:"log_id"`
+	WebhookURL string `json:"webhook_url"`
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Attempt    int    `json:"attempt"`
+	// Final is set when no retry will follow
+	Final bool `json:"final"`
+}
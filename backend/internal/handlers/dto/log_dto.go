@@ -7,13 +7,15 @@ import (
 )
 
 type LogQueryParams struct {
-	Page      int       `query:"page"`
-	Limit     int       `query:"limit"`
-	Direction string    `query:"direction"`
-	Status    string    `query:"status"`
-	DeviceID  string    `query:"device_id"`
-	From      time.Time `query:"from"`
-	To        time.Time `query:"to"`
+	Page       int       `query:"page"`
+	Limit      int       `query:"limit"`
+	Type       string    `query:"type"`
+	Direction  string    `query:"direction"`
+	Status     string    `query:"status"`
+	DeviceID   string    `query:"device_id"`
+	AppPackage string    `query:"app_package"`
+	From       time.Time `query:"from"`
+	To         time.Time `query:"to"`
 }
 
 func (p *LogQueryParams) Normalize() {
@@ -39,30 +41,50 @@ type PaginatedLogs struct {
 type LogDTO struct {
 	ID           uint   `json:"id"`
 	DeviceID     string `json:"device_id,omitempty"`
+	RequestID    string `json:"request_id,omitempty"`
+	Type         string `json:"type"`
 	Direction    string `json:"direction"`
 	SimSlot      int    `json:"sim_slot"`
 	Sender       string `json:"sender"`
 	Receiver     string `json:"receiver"`
+	RemoteNumber string `json:"remote_number,omitempty"`
 	Content      string `json:"content"`
+	Encoding     string `json:"encoding,omitempty"`
+	Segments     int    `json:"segments,omitempty"`
 	Status       string `json:"status"`
 	ErrorMessage string `json:"error_message,omitempty"`
 	RetryCount   int    `json:"retry_count"`
 	CreatedAt    string `json:"created_at"`
 	ProcessedAt  string `json:"processed_at,omitempty"`
+	ReadAt       string `json:"read_at,omitempty"`
+
+	AppPackage string `json:"app_package,omitempty"`
+	AppName    string `json:"app_name,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Category   string `json:"category,omitempty"`
 }
 
 func ToLogDTO(log *models.MessageLog) LogDTO {
 	dto := LogDTO{
 		ID:           log.ID,
+		RequestID:    log.RequestID,
+		Type:         string(log.Type),
 		Direction:    string(log.Direction),
 		SimSlot:      log.SimSlot,
 		Sender:       log.Sender,
 		Receiver:     log.Receiver,
+		RemoteNumber: log.RemoteNumber,
 		Content:      log.Content,
+		Encoding:     log.Encoding,
+		Segments:     log.Segments,
 		Status:       string(log.Status),
 		ErrorMessage: log.ErrorMessage,
 		RetryCount:   log.RetryCount,
 		CreatedAt:    log.CreatedAt.Format(time.RFC3339),
+		AppPackage:   log.AppPackage,
+		AppName:      log.AppName,
+		Title:        log.Title,
+		Category:     log.Category,
 	}
 
 	if log.DeviceID != nil {
@@ -73,6 +95,10 @@ func ToLogDTO(log *models.MessageLog) LogDTO {
 		dto.ProcessedAt = log.ProcessedAt.Format(time.RFC3339)
 	}
 
+	if log.ReadAt != nil {
+		dto.ReadAt = log.ReadAt.Format(time.RFC3339)
+	}
+
 	return dto
 }
 
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type UpdateRoutingStrategyRequest struct {
+	Strategy string `json:"strategy" validate:"required,oneof=round_robin least_loaded battery_aware sticky carrier_prefix"`
+}
+
+type UpdateFailoverRequest struct {
+	MaxAttempts int `json:"max_attempts" validate:"required,min=1,max=5"`
+}
+
+type CreateRoutingPrefixRequest struct {
+	Prefix   string `json:"prefix" validate:"required,max=20"`
+	DeviceID string `json:"device_id" validate:"required,uuid"`
+	SimSlot  int    `json:"sim_slot"`
+}
+
+type RoutingPrefixDTO struct {
+	ID        uint   `json:"id"`
+	Prefix    string `json:"prefix"`
+	DeviceID  string `json:"device_id"`
+	SimSlot   int    `json:"sim_slot"`
+	CreatedAt string `json:"created_at"`
+}
+
+type RoutingSettingsResponse struct {
+	Strategy            string             `json:"strategy"`
+	FailoverMaxAttempts int                `json:"failover_max_attempts"`
+	Prefixes            []RoutingPrefixDTO `json:"prefixes"`
+}
+
+func ToRoutingPrefixDTO(prefix *models.RoutingPrefix) RoutingPrefixDTO {
+	return RoutingPrefixDTO{
+		ID:        prefix.ID,
+		Prefix:    prefix.Prefix,
+		DeviceID:  prefix.DeviceID.String(),
+		SimSlot:   prefix.SimSlot,
+		CreatedAt: prefix.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
+
+func ToRoutingPrefixDTOList(prefixes []models.RoutingPrefix) []RoutingPrefixDTO {
+	dtos := make([]RoutingPrefixDTO, len(prefixes))
+	for i, prefix := range prefixes {
+		dtos[i] = ToRoutingPrefixDTO(&prefix)
+	}
+	return dtos
+}
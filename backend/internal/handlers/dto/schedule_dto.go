@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type UpdateScheduleRequest struct {
+	Phone      *string `json:"phone"`
+	Content    *string `json:"content"`
+	SendAt     *string `json:"send_at"`
+	Timezone   *string `json:"timezone"`
+	Recurrence *string `json:"recurrence"`
+}
+
+type ScheduleQueryParams struct {
+	Page   int    `query:"page"`
+	Limit  int    `query:"limit"`
+	Status string `query:"status"`
+}
+
+func (p *ScheduleQueryParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+}
+
+type ScheduledMessageDTO struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	Phone         string `json:"phone"`
+	Content       string `json:"content"`
+	DeviceID      string `json:"device_id,omitempty"`
+	SimSlot       int    `json:"sim_slot"`
+	Timezone      string `json:"timezone"`
+	Recurrence    string `json:"recurrence,omitempty"`
+	NextRunAt     string `json:"next_run_at,omitempty"`
+	LastRunAt     string `json:"last_run_at,omitempty"`
+	Occurrences   int    `json:"occurrences"`
+	LastRequestID string `json:"last_request_id,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type PaginatedSchedules struct {
+	Data       []ScheduledMessageDTO `json:"data"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+func ToScheduledMessageDTO(schedule *models.ScheduledMessage) ScheduledMessageDTO {
+	resp := ScheduledMessageDTO{
+		ID:            schedule.ID.String(),
+		Status:        string(schedule.Status),
+		Phone:         schedule.Phone,
+		Content:       schedule.Content,
+		SimSlot:       schedule.SimSlot,
+		Timezone:      schedule.Timezone,
+		Recurrence:    schedule.Recurrence,
+		NextRunAt:     formatOptionalTime(schedule.NextRunAt),
+		LastRunAt:     formatOptionalTime(schedule.LastRunAt),
+		Occurrences:   schedule.Occurrences,
+		LastRequestID: schedule.LastRequestID,
+		LastError:     schedule.LastError,
+		CreatedAt:     schedule.CreatedAt.Format(time.RFC3339),
+	}
+
+	if schedule.DeviceID != nil {
+		resp.DeviceID = schedule.DeviceID.String()
+	}
+
+	return resp
+}
+
+func ToScheduledMessageDTOList(schedules []models.ScheduledMessage) []ScheduledMessageDTO {
+	dtos := make([]ScheduledMessageDTO, len(schedules))
+	for i, schedule := range schedules {
+		dtos[i] = ToScheduledMessageDTO(&schedule)
+	}
+	return dtos
+}
@@ -1,16 +1,122 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
 type SendSMSRequest struct {
 	Phone    string  `json:"phone" validate:"required"`
-	Content  string  `json:"content" validate:"required,max=1600"`
+	Content  string  `json:"content" validate:"required_without=TemplateID"`
 	DeviceID *string `json:"device_id"`
 	SimSlot  int     `json:"sim_slot"`
+	TTL      int     `json:"ttl" validate:"omitempty,min=60,max=259200"`
+	Strategy string  `json:"strategy" validate:"omitempty,oneof=round_robin least_loaded battery_aware sticky carrier_prefix"`
+
+	StatusCallbackURL string `json:"status_callback_url" validate:"omitempty,url"`
+
+	// MaxAttempts overrides the account's failover setting for this message
+	MaxAttempts int `json:"max_attempts" validate:"omitempty,min=1,max=5"`
+
+	// TemplateID renders the content from a stored template instead
+	TemplateID *uint             `json:"template_id"`
+	Variables  map[string]string `json:"variables"`
+	Locale     string            `json:"locale"`
+
+	// Transliterate strips diacritics so the message stays in GSM-7
+	Transliterate bool `json:"transliterate"`
+
+	// SendAt defers the message; RFC 3339, or local time in Timezone
+	SendAt     string `json:"send_at"`
+	Timezone   string `json:"timezone"`
+	Recurrence string `json:"recurrence"`
 }
 
 type SendSMSResponse struct {
 	RequestID string `json:"request_id"`
 	Status    string `json:"status"`
-	DeviceID  string `json:"device_id"`
+	DeviceID  string `json:"device_id,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	Encoding  string `json:"encoding"`
+	Segments  int    `json:"segments"`
+	Phone     string `json:"phone"`
+	PhoneType string `json:"phone_type"`
+}
+
+type SMSStatusResponse struct {
+	RequestID    string `json:"request_id"`
+	Status       string `json:"status"`
+	DeviceID     string `json:"device_id,omitempty"`
+	Phone        string `json:"phone"`
+	SimSlot      int    `json:"sim_slot"`
+	Encoding     string `json:"encoding,omitempty"`
+	Segments     int    `json:"segments"`
+	Error        string `json:"error,omitempty"`
+	QueuedAt     string `json:"queued_at"`
+	DispatchedAt string `json:"dispatched_at,omitempty"`
+	SentAt       string `json:"sent_at,omitempty"`
+	DeliveredAt  string `json:"delivered_at,omitempty"`
+	FailedAt     string `json:"failed_at,omitempty"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
+
+	MaxAttempts int                  `json:"max_attempts"`
+	Attempts    []DeliveryAttemptDTO `json:"attempts"`
+}
+
+type DeliveryAttemptDTO struct {
+	Attempt     int    `json:"attempt"`
+	DeviceID    string `json:"device_id"`
+	SimSlot     int    `json:"sim_slot"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+}
+
+func ToSMSStatusResponse(log *models.MessageLog) SMSStatusResponse {
+	resp := SMSStatusResponse{
+		RequestID:    log.RequestID,
+		Status:       string(log.Status),
+		Phone:        log.Receiver,
+		SimSlot:      log.SimSlot,
+		Encoding:     log.Encoding,
+		Segments:     log.Segments,
+		Error:        log.ErrorMessage,
+		QueuedAt:     log.CreatedAt.Format(time.RFC3339),
+		DispatchedAt: formatOptionalTime(log.DispatchedAt),
+		SentAt:       formatOptionalTime(log.SentAt),
+		DeliveredAt:  formatOptionalTime(log.DeliveredAt),
+		FailedAt:     formatOptionalTime(log.FailedAt),
+		ExpiresAt:    formatOptionalTime(log.ExpiresAt),
+		MaxAttempts:  log.MaxAttempts,
+		Attempts:     make([]DeliveryAttemptDTO, 0, len(log.Attempts)),
+	}
+
+	if log.DeviceID != nil {
+		resp.DeviceID = log.DeviceID.String()
+	}
+
+	for _, attempt := range log.Attempts {
+		resp.Attempts = append(resp.Attempts, DeliveryAttemptDTO{
+			Attempt:     attempt.Attempt,
+			DeviceID:    attempt.DeviceID.String(),
+			SimSlot:     attempt.SimSlot,
+			Status:      string(attempt.Status),
+			Error:       attempt.Error,
+			StartedAt:   attempt.CreatedAt.Format(time.RFC3339),
+			CompletedAt: formatOptionalTime(attempt.CompletedAt),
+		})
+	}
+
+	return resp
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
 
 type DeviceStatusDTO struct {
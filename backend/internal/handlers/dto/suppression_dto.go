@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type SuppressionQueryParams struct {
+	Page   int    `query:"page"`
+	Limit  int    `query:"limit"`
+	Search string `query:"q"`
+}
+
+func (p *SuppressionQueryParams) Normalize() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Limit < 1 {
+		p.Limit = 20
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+}
+
+type ImportSuppressionsRequest struct {
+	Phones []string `json:"phones" validate:"required,max=10000"`
+}
+
+type SuppressionKeywordsRequest struct {
+	OptOut []string `json:"opt_out"`
+	OptIn  []string `json:"opt_in"`
+}
+
+type SuppressedNumberDTO struct {
+	Phone     string `json:"phone"`
+	Source    string `json:"source"`
+	Keyword   string `json:"keyword,omitempty"`
+	DeviceID  string `json:"device_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type PaginatedSuppressions struct {
+	Data       []SuppressedNumberDTO `json:"data"`
+	Total      int64                 `json:"total"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+func ToSuppressedNumberDTO(entry *models.SuppressedNumber) SuppressedNumberDTO {
+	dto := SuppressedNumberDTO{
+		Phone:     entry.Phone,
+		Source:    entry.Source,
+		Keyword:   entry.Keyword,
+		CreatedAt: entry.CreatedAt.Format(time.RFC3339),
+	}
+	if entry.DeviceID != nil {
+		dto.DeviceID = entry.DeviceID.String()
+	}
+	return dto
+}
+
+func ToSuppressedNumberDTOList(entries []models.SuppressedNumber) []SuppressedNumberDTO {
+	dtos := make([]SuppressedNumberDTO, len(entries))
+	for i := range entries {
+		dtos[i] = ToSuppressedNumberDTO(&entries[i])
+	}
+	return dtos
+}
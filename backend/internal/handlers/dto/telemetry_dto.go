@@ -0,0 +1,99 @@
+package dto
+
+import (
+	"math"
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+// TelemetryQueryParams selects a chart range as RFC 3339 times. The range
+// defaults to the last 24 hours and the bucket to one suited to it.
+type TelemetryQueryParams struct {
+	From   string `query:"from"`
+	To     string `query:"to"`
+	Bucket string `query:"bucket"`
+}
+
+type HealthQueryParams struct {
+	Period string `query:"period"`
+}
+
+// HealthPeriods are the uptime windows the fleet overview offers.
+var HealthPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+type TelemetryPointDTO struct {
+	Time        string  `json:"time"`
+	Battery     float64 `json:"battery"`
+	MinBattery  int     `json:"min_battery"`
+	Charging    bool    `json:"charging"`
+	Signal      float64 `json:"signal"`
+	NetworkType string  `json:"network_type,omitempty"`
+	QueueDepth  int     `json:"queue_depth"`
+	AppVersion  string  `json:"app_version,omitempty"`
+	Samples     int     `json:"samples"`
+}
+
+type TelemetrySeriesResponse struct {
+	DeviceID      string              `json:"device_id"`
+	From          string              `json:"from"`
+	To            string              `json:"to"`
+	Bucket        string              `json:"bucket"`
+	UptimePercent float64             `json:"uptime_percent"`
+	Points        []TelemetryPointDTO `json:"points"`
+}
+
+func ToTelemetryPointDTO(point *models.TelemetryPoint) TelemetryPointDTO {
+	return TelemetryPointDTO{
+		Time:        point.Bucket.Format(time.RFC3339),
+		Battery:     math.Round(point.Battery*10) / 10,
+		MinBattery:  point.MinBattery,
+		Charging:    point.Charging,
+		Signal:      math.Round(point.Signal*10) / 10,
+		NetworkType: point.NetworkType,
+		QueueDepth:  point.QueueDepth,
+		AppVersion:  point.AppVersion,
+		Samples:     point.Samples,
+	}
+}
+
+// TelemetrySnapshotDTO is the newest telemetry row of a device.
+type TelemetrySnapshotDTO struct {
+	RecordedAt  string `json:"recorded_at"`
+	Battery     int    `json:"battery"`
+	Charging    bool   `json:"charging"`
+	Signal      int    `json:"signal"`
+	NetworkType string `json:"network_type,omitempty"`
+	QueueDepth  int    `json:"queue_depth"`
+	AppVersion  string `json:"app_version,omitempty"`
+}
+
+type DeviceHealthDTO struct {
+	Device        DeviceDTO             `json:"device"`
+	UptimePercent float64               `json:"uptime_percent"`
+	Latest        *TelemetrySnapshotDTO `json:"latest,omitempty"`
+}
+
+type DeviceHealthResponse struct {
+	Period  string            `json:"period"`
+	Devices []DeviceHealthDTO `json:"devices"`
+}
+
+func ToTelemetrySnapshotDTO(point *models.DeviceTelemetry) *TelemetrySnapshotDTO {
+	if point == nil {
+		return nil
+	}
+	return &TelemetrySnapshotDTO{
+		RecordedAt:  point.RecordedAt.Format(time.RFC3339),
+		Battery:     point.Battery,
+		Charging:    point.Charging,
+		Signal:      point.Signal,
+		NetworkType: point.NetworkType,
+		QueueDepth:  point.QueueDepth,
+		AppVersion:  point.AppVersion,
+	}
+}
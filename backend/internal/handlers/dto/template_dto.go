@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+)
+
+type TemplateRequest struct {
+	Name     string            `json:"name" validate:"required,max=100"`
+	Body     string            `json:"body" validate:"required,max=1600"`
+	Locales  map[string]string `json:"locales"`
+	Defaults map[string]string `json:"defaults"`
+}
+
+type RenderTemplateRequest struct {
+	Locale    string            `json:"locale"`
+	Variables map[string]string `json:"variables"`
+}
+
+type TemplateDTO struct {
+	ID           uint              `json:"id"`
+	Name         string            `json:"name"`
+	Body         string            `json:"body"`
+	Locales      map[string]string `json:"locales"`
+	Defaults     map[string]string `json:"defaults"`
+	Placeholders []string          `json:"placeholders"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+type RenderedTemplateResponse struct {
+	Content  string `json:"content"`
+	Locale   string `json:"locale,omitempty"`
+	Encoding string `json:"encoding"`
+	Segments int    `json:"segments"`
+}
+
+// ToTemplateDTO converts a template; placeholders are the variable names its
+// body uses.
+func ToTemplateDTO(template *models.MessageTemplate, placeholders []string) TemplateDTO {
+	dto := TemplateDTO{
+		ID:           template.ID,
+		Name:         template.Name,
+		Body:         template.Body,
+		Locales:      template.Locales,
+		Defaults:     template.Defaults,
+		Placeholders: placeholders,
+		UpdatedAt:    template.UpdatedAt.Format(time.RFC3339),
+	}
+
+	if dto.Locales == nil {
+		dto.Locales = map[string]string{}
+	}
+	if dto.Defaults == nil {
+		dto.Defaults = map[string]string{}
+	}
+	if dto.Placeholders == nil {
+		dto.Placeholders = []string{}
+	}
+
+	return dto
+}
@@ -8,12 +8,22 @@ import (
 )
 
 type Handlers struct {
-	Auth *AuthHandler
-	WS   *WSHandler
-	SMS  *SMSHandler
+	Auth          *AuthHandler
+	WS            *WSHandler
+	UserWS        *UserWSHandler
+	SMS           *SMSHandler
+	CapturePolicy *CapturePolicyHandler
+	Routing       *RoutingHandler
+	Campaign      *CampaignHandler
+	Schedule      *ScheduleHandler
+	Template      *TemplateHandler
+	Suppression   *SuppressionHandler
+	Conversation  *ConversationHandler
+	Command       *CommandHandler
+	Device        *DeviceHandler
 }
 
-func SetupRoutes(app *fiber.App, h *Handlers, jwtSecret string, userService services.UserService) {
+func SetupRoutes(app *fiber.App, h *Handlers, jwtSecret string, userService services.UserService, idempotencyService services.IdempotencyService) {
 	api := app.Group("/api")
 
 	auth := api.Group("/auth")
@@ -21,11 +31,48 @@ func SetupRoutes(app *fiber.App, h *Handlers, jwtSecret string, userService serv
 	auth.Post("/login", h.Auth.Login)
 	auth.Get("/me", middleware.JWTMiddleware(jwtSecret), h.Auth.GetMe)
 	auth.Post("/refresh-key", middleware.JWTMiddleware(jwtSecret), h.Auth.RefreshAPIKey)
+	auth.Put("/status-callback", middleware.JWTMiddleware(jwtSecret), h.Auth.UpdateStatusCallback)
+	auth.Put("/default-country", middleware.JWTMiddleware(jwtSecret), h.Auth.UpdateDefaultCountry)
+
+	h.CapturePolicy.RegisterRoutes(api, middleware.JWTMiddleware(jwtSecret))
+	h.Routing.RegisterRoutes(api, middleware.JWTMiddleware(jwtSecret))
+	h.Template.RegisterRoutes(api, middleware.JWTMiddleware(jwtSecret))
+	h.Suppression.RegisterRoutes(api, middleware.JWTMiddleware(jwtSecret))
+	h.Device.RegisterRoutes(api, middleware.JWTMiddleware(jwtSecret))
 
 	v1 := api.Group("/v1")
 	v1.Use(middleware.APIKeyMiddleware(userService))
-	v1.Post("/sms/send", h.SMS.SendSMS)
+	v1.Post("/sms/send", middleware.IdempotencyMiddleware(idempotencyService), h.SMS.SendSMS)
+	v1.Get("/sms/:request_id", h.SMS.GetSMSStatus)
+	v1.Post("/sms/bulk", middleware.IdempotencyMiddleware(idempotencyService), h.Campaign.BulkSend)
 	v1.Get("/devices/status", h.SMS.GetDevicesStatus)
+	v1.Get("/devices/:id/commands", h.Command.ListByDevice)
+	v1.Post("/devices/:id/commands/status", h.Command.RequestStatus)
+	v1.Post("/devices/:id/commands/restart", h.Command.Restart)
+	v1.Post("/devices/:id/commands/config", h.Command.UpdateConfig)
+	v1.Post("/devices/:id/commands/logs", h.Command.UploadLogs)
+	v1.Post("/devices/:id/commands/ussd", h.Command.RunUSSD)
+	v1.Get("/commands/:id", h.Command.Get)
+
+	scheduled := v1.Group("/scheduled")
+	scheduled.Get("/", h.Schedule.List)
+	scheduled.Get("/:id", h.Schedule.Get)
+	scheduled.Put("/:id", h.Schedule.Update)
+	scheduled.Delete("/:id", h.Schedule.Cancel)
+
+	campaigns := v1.Group("/campaigns")
+	campaigns.Get("/", h.Campaign.List)
+	campaigns.Get("/:id", h.Campaign.Get)
+	campaigns.Post("/:id/pause", h.Campaign.Pause)
+	campaigns.Post("/:id/resume", h.Campaign.Resume)
+	campaigns.Post("/:id/cancel", h.Campaign.Cancel)
+	campaigns.Get("/:id/export", h.Campaign.Export)
+
+	conversations := v1.Group("/conversations")
+	conversations.Get("/", h.Conversation.List)
+	conversations.Get("/:id", h.Conversation.Get)
+	conversations.Post("/:id/read", h.Conversation.MarkRead)
+	conversations.Post("/:id/reply", h.Conversation.Reply)
 
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
@@ -35,4 +82,5 @@ func SetupRoutes(app *fiber.App, h *Handlers, jwtSecret string, userService serv
 	})
 
 	app.Get("/ws/device", websocket.New(h.WS.HandleDeviceWS))
+	app.Get("/ws/user", middleware.WebSocketJWTMiddleware(jwtSecret), websocket.New(h.UserWS.HandleUserWS))
 }
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+type RoutingHandler struct {
+	userService    services.UserService
+	routingService services.RoutingService
+}
+
+func NewRoutingHandler(userService services.UserService, routingService services.RoutingService) *RoutingHandler {
+	return &RoutingHandler{
+		userService:    userService,
+		routingService: routingService,
+	}
+}
+
+func (h *RoutingHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	routing := router.Group("/routing", authMiddleware)
+	routing.Get("/", h.Get)
+	routing.Put("/strategy", h.UpdateStrategy)
+	routing.Put("/failover", h.UpdateFailover)
+	routing.Post("/prefixes", h.CreatePrefix)
+	routing.Delete("/prefixes/:id", h.DeletePrefix)
+}
+
+func (h *RoutingHandler) Get(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	user, err := h.userService.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "user not found",
+		})
+	}
+
+	prefixes, err := h.routingService.ListPrefixes(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch routing prefixes",
+		})
+	}
+
+	strategy := user.RoutingStrategy
+	if strategy == "" {
+		strategy = models.RoutingRoundRobin
+	}
+
+	maxAttempts := user.FailoverMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	return c.JSON(dto.RoutingSettingsResponse{
+		Strategy:            strategy,
+		FailoverMaxAttempts: maxAttempts,
+		Prefixes:            dto.ToRoutingPrefixDTOList(prefixes),
+	})
+}
+
+func (h *RoutingHandler) UpdateStrategy(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.UpdateRoutingStrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if err := h.userService.UpdateRoutingStrategy(userID, req.Strategy); err != nil {
+		if errors.Is(err, services.ErrInvalidRoutingStrategy) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "strategy must be one of round_robin, least_loaded, battery_aware, sticky, carrier_prefix",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update routing strategy",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"strategy": req.Strategy,
+	})
+}
+
+func (h *RoutingHandler) UpdateFailover(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.UpdateFailoverRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	if err := h.userService.UpdateFailoverMaxAttempts(userID, req.MaxAttempts); err != nil {
+		if errors.Is(err, services.ErrInvalidMaxAttempts) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "max_attempts must be between 1 and 5",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update failover settings",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"max_attempts": req.MaxAttempts,
+	})
+}
+
+func (h *RoutingHandler) CreatePrefix(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.CreateRoutingPrefixRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	prefix, err := h.routingService.CreatePrefix(userID, &services.CreateRoutingPrefixRequest{
+		Prefix:   req.Prefix,
+		DeviceID: req.DeviceID,
+		SimSlot:  req.SimSlot,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRoutingPrefix) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "prefix must be 1-20 characters",
+			})
+		}
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "device not found",
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"prefix": dto.ToRoutingPrefixDTO(prefix),
+	})
+}
+
+func (h *RoutingHandler) DeletePrefix(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid prefix id",
+		})
+	}
+
+	if err := h.routingService.DeletePrefix(uint(id), userID); err != nil {
+		if errors.Is(err, services.ErrRoutingPrefixNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "routing prefix not found",
+			})
+		}
+		if errors.Is(err, services.ErrRoutingPrefixDenied) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "access denied",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to delete routing prefix",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "routing prefix deleted",
+	})
+}
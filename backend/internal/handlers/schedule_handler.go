@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+var sendAtLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+type ScheduleHandler struct {
+	scheduleService services.ScheduleService
+}
+
+func NewScheduleHandler(scheduleService services.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{scheduleService: scheduleService}
+}
+
+func (h *ScheduleHandler) List(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	params := new(dto.ScheduleQueryParams)
+	if err := c.QueryParser(params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid query parameters"})
+	}
+	params.Normalize()
+
+	schedules, total, err := h.scheduleService.List(user.ID, params.Status, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch scheduled messages"})
+	}
+
+	return c.JSON(dto.PaginatedSchedules{
+		Data:       dto.ToScheduledMessageDTOList(schedules),
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	})
+}
+
+func (h *ScheduleHandler) Get(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid schedule id"})
+	}
+
+	schedule, err := h.scheduleService.Get(id, user.ID)
+	if err != nil {
+		return scheduleError(c, err, "failed to fetch scheduled message")
+	}
+
+	return c.JSON(dto.ToScheduledMessageDTO(schedule))
+}
+
+func (h *ScheduleHandler) Update(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid schedule id"})
+	}
+
+	var req dto.UpdateScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request body"})
+	}
+
+	update := &services.UpdateScheduleRequest{
+		Timezone:   req.Timezone,
+		Recurrence: req.Recurrence,
+	}
+
+	if req.Phone != nil {
+		number, err := services.NormalizePhone(*req.Phone, user.DefaultCountry)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+		}
+		update.Phone = &number.E164
+	}
+
+	if req.Content != nil {
+		if *req.Content == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content is required"})
+		}
+		content := *req.Content
+		if user.SubscriptionPlan == "free" {
+			content += freePlanSignature
+		}
+		if _, err := services.CheckContent(content); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+		}
+		update.Content = &content
+	}
+
+	if req.SendAt != nil {
+		current, err := h.scheduleService.Get(id, user.ID)
+		if err != nil {
+			return scheduleError(c, err, "failed to update scheduled message")
+		}
+
+		timezone := current.Timezone
+		if req.Timezone != nil {
+			timezone = *req.Timezone
+		}
+		loc, err := services.LoadTimezone(timezone)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid timezone"})
+		}
+
+		sendAt, err := parseSendAt(*req.SendAt, loc)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "send_at must be RFC 3339 or YYYY-MM-DDTHH:MM[:SS] in timezone"})
+		}
+		update.SendAt = &sendAt
+	}
+
+	schedule, err := h.scheduleService.Update(id, user.ID, update)
+	if err != nil {
+		return scheduleError(c, err, "failed to update scheduled message")
+	}
+
+	return c.JSON(dto.ToScheduledMessageDTO(schedule))
+}
+
+func (h *ScheduleHandler) Cancel(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid schedule id"})
+	}
+
+	schedule, err := h.scheduleService.Cancel(id, user.ID)
+	if err != nil {
+		return scheduleError(c, err, "failed to cancel scheduled message")
+	}
+
+	return c.JSON(dto.ToScheduledMessageDTO(schedule))
+}
+
+// parseSendAt accepts an RFC 3339 timestamp, or a wall-clock time that is
+// interpreted in loc.
+func parseSendAt(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	var lastErr error
+	for _, layout := range sendAtLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, lastErr
+}
+
+func scheduleError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "scheduled message not found"})
+	case errors.Is(err, services.ErrScheduleNotActive):
+		return c.Status(fiber.StatusConflict).JSON(dto.ErrorResponse{Error: "scheduled message is no longer pending"})
+	case errors.Is(err, services.ErrInvalidSendAt):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "send_at must be in the future and within one year"})
+	case errors.Is(err, services.ErrInvalidTimezone):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid timezone"})
+	case errors.Is(err, services.ErrInvalidRecurrence):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "recurrence must be an RRULE with FREQ=DAILY, WEEKLY or MONTHLY and optional INTERVAL, COUNT or UNTIL"})
+	case errors.Is(err, services.ErrInvalidMaxAttempts):
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "max_attempts must be between 1 and 5"})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: fallback})
+}
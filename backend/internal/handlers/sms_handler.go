@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"regexp"
+	"errors"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -9,11 +10,11 @@ import (
 	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
 )
 
 const (
-	maxSMSContentLength = 1600
-	freePlanSignature   = "\n\n- Sent via TingHook"
+	freePlanSignature = "\n\n- Sent via TingHook"
 )
 
 type SMSHandler struct {
@@ -21,6 +22,10 @@ type SMSHandler struct {
 	userService   services.UserService
 	deviceService services.DeviceService
 	logService    services.LogService
+	outbound      services.OutboundService
+	schedules     services.ScheduleService
+	templates     services.TemplateService
+	suppressions  services.SuppressionService
 }
 
 func NewSMSHandler(
@@ -28,12 +33,20 @@ func NewSMSHandler(
 	userService services.UserService,
 	deviceService services.DeviceService,
 	logService services.LogService,
+	outbound services.OutboundService,
+	schedules services.ScheduleService,
+	templates services.TemplateService,
+	suppressions services.SuppressionService,
 ) *SMSHandler {
 	return &SMSHandler{
 		hub:           hub,
 		userService:   userService,
 		deviceService: deviceService,
 		logService:    logService,
+		outbound:      outbound,
+		schedules:     schedules,
+		templates:     templates,
+		suppressions:  suppressions,
 	}
 }
 
@@ -49,28 +62,72 @@ func (h *SMSHandler) SendSMS(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "phone is required"})
 	}
 
+	if req.TemplateID != nil {
+		if req.Content != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content and template_id are mutually exclusive"})
+		}
+
+		rendered, err := h.templates.Render(*req.TemplateID, user.ID, req.Locale, req.Variables)
+		if err != nil {
+			if errors.Is(err, services.ErrTemplateNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "template not found"})
+			}
+			if errors.Is(err, services.ErrMissingVariable) {
+				return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to render template"})
+		}
+		req.Content = rendered.Content
+	}
+
 	if req.Content == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content is required"})
 	}
 
-	if len(req.Content) > maxSMSContentLength {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "content exceeds maximum length of 1600 characters"})
+	number, err := services.NormalizePhone(req.Phone, user.DefaultCountry)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
+	}
+
+	if req.TTL < 0 || time.Duration(req.TTL)*time.Second > services.MaxOutboundTTL {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "ttl must be between 0 and 259200 seconds"})
+	}
+
+	if req.StatusCallbackURL != "" && !isValidCallbackURL(req.StatusCallbackURL) {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "status_callback_url must be an absolute http or https URL"})
+	}
+
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = user.RoutingStrategy
+	}
+	if strategy == "" {
+		strategy = models.RoutingRoundRobin
+	}
+	if err := services.ValidateRoutingStrategy(strategy); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid routing strategy"})
 	}
 
-	if !isValidPhone(req.Phone) {
-		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid phone number format"})
+	maxAttempts := req.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = user.FailoverMaxAttempts
+	}
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+	if maxAttempts < 1 || maxAttempts > services.MaxFailoverAttempts {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "max_attempts must be between 1 and 5"})
 	}
 
-	var targetDeviceID uuid.UUID
-	var err error
+	var targetDeviceID *uuid.UUID
 
 	if req.DeviceID != nil && *req.DeviceID != "" {
-		targetDeviceID, err = uuid.Parse(*req.DeviceID)
+		parsed, err := uuid.Parse(*req.DeviceID)
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid device_id format"})
 		}
 
-		device, err := h.deviceService.GetByID(targetDeviceID)
+		device, err := h.deviceService.GetByID(parsed)
 		if err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "device not found"})
 		}
@@ -79,57 +136,117 @@ func (h *SMSHandler) SendSMS(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "device not found"})
 		}
 
-		if !h.hub.GetDeviceStatus(targetDeviceID) {
-			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "device is offline"})
-		}
-	} else {
-		onlineDevices := h.hub.GetOnlineDevices(user.ID)
-		if len(onlineDevices) == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "no online device available"})
-		}
-		targetDeviceID = onlineDevices[0]
+		targetDeviceID = &parsed
 	}
 
 	content := req.Content
+	if req.Transliterate {
+		content = sms.Transliterate(content)
+	}
 	if user.SubscriptionPlan == "free" {
-		content = req.Content + freePlanSignature
+		content += freePlanSignature
+	}
+
+	info, err := services.CheckContent(content)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: err.Error()})
 	}
 
-	requestID := uuid.New()
+	sendReq := services.SendRequest{
+		Phone:    number.E164,
+		Content:  content,
+		DeviceID: targetDeviceID,
+		SimSlot:  req.SimSlot,
+		TTL:      time.Duration(req.TTL) * time.Second,
+		Strategy: strategy,
+
+		StatusCallbackURL: req.StatusCallbackURL,
+		MaxAttempts:       maxAttempts,
+	}
 
-	log, err := h.logService.Create(
-		user.ID,
-		&targetDeviceID,
-		models.DirectionOutbound,
-		"",
-		req.Phone,
-		content,
-		req.SimSlot,
-	)
+	suppressed, err := h.suppressions.IsSuppressed(user.ID, number.E164)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to create message log"})
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to check suppression list"})
+	}
+	if suppressed {
+		return suppressedError(c)
 	}
 
-	sendMsg, err := websockets.NewMessage(websockets.MsgTypeSendSMS, websockets.SendSMSData{
-		RequestID: requestID.String(),
-		Phone:     req.Phone,
-		Content:   content,
-		SimSlot:   req.SimSlot,
-	})
+	if req.SendAt != "" || req.Recurrence != "" {
+		return h.schedule(c, user, &req, sendReq)
+	}
+
+	log, err := h.outbound.Send(user.ID, &sendReq)
+	if errors.Is(err, services.ErrRecipientSuppressed) {
+		return suppressedError(c)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to queue message"})
+	}
+
+	resp := dto.SendSMSResponse{
+		RequestID: log.RequestID,
+		Status:    string(log.Status),
+		Encoding:  string(info.Encoding),
+		Segments:  info.Segments,
+		Phone:     number.E164,
+		PhoneType: string(number.Type),
+	}
+	if log.DeviceID != nil {
+		resp.DeviceID = log.DeviceID.String()
+	}
+	if log.ExpiresAt != nil {
+		resp.ExpiresAt = log.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(resp)
+}
+
+func (h *SMSHandler) schedule(c *fiber.Ctx, user *models.User, req *dto.SendSMSRequest, sendReq services.SendRequest) error {
+	if req.SendAt == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "send_at is required with recurrence"})
+	}
+
+	loc, err := services.LoadTimezone(req.Timezone)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to create message"})
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid timezone"})
 	}
 
-	if err := h.hub.SendToDevice(targetDeviceID, sendMsg); err != nil {
-		_ = h.logService.UpdateStatus(log.ID, models.StatusFailed, "failed to send to device")
-		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to send SMS to device"})
+	sendAt, err := parseSendAt(req.SendAt, loc)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "send_at must be RFC 3339 or YYYY-MM-DDTHH:MM[:SS] in timezone"})
 	}
 
-	return c.Status(fiber.StatusAccepted).JSON(dto.SendSMSResponse{
-		RequestID: requestID.String(),
-		Status:    "queued",
-		DeviceID:  targetDeviceID.String(),
+	schedule, err := h.schedules.Create(user.ID, &services.ScheduleMessageRequest{
+		Send:       sendReq,
+		SendAt:     sendAt,
+		Timezone:   req.Timezone,
+		Recurrence: req.Recurrence,
 	})
+	if err != nil {
+		return scheduleError(c, err, "failed to schedule message")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(dto.ToScheduledMessageDTO(schedule))
+}
+
+func (h *SMSHandler) GetSMSStatus(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	requestID := c.Params("request_id")
+	if _, err := uuid.Parse(requestID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(dto.ErrorResponse{Error: "invalid request_id format"})
+	}
+
+	log, err := h.outbound.GetByRequestID(user.ID, requestID)
+	if err != nil {
+		if errors.Is(err, services.ErrLogNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(dto.ErrorResponse{Error: "message not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(dto.ErrorResponse{Error: "failed to fetch message status"})
+	}
+
+	return c.JSON(dto.ToSMSStatusResponse(log))
 }
 
 func (h *SMSHandler) GetDevicesStatus(c *fiber.Ctx) error {
@@ -169,7 +286,9 @@ func (h *SMSHandler) GetDevicesStatus(c *fiber.Ctx) error {
 	})
 }
 
-func isValidPhone(phone string) bool {
-	phoneRegex := regexp.MustCompile(`^\+?[0-9]{7,15}$`)
-	return phoneRegex.MatchString(phone)
+func suppressedError(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(dto.ErrorResponse{
+		Error: "recipient has opted out of messages",
+		Code:  services.SuppressedErrorCode,
+	})
 }
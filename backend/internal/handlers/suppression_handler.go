@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+type SuppressionHandler struct {
+	suppressionService services.SuppressionService
+}
+
+func NewSuppressionHandler(suppressionService services.SuppressionService) *SuppressionHandler {
+	return &SuppressionHandler{suppressionService: suppressionService}
+}
+
+func (h *SuppressionHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	suppressions := router.Group("/suppressions", authMiddleware)
+	suppressions.Get("/", h.List)
+	suppressions.Post("/import", h.Import)
+	suppressions.Get("/keywords", h.GetKeywords)
+	suppressions.Put("/keywords", h.UpdateKeywords)
+	suppressions.Delete("/:phone", h.Remove)
+}
+
+func (h *SuppressionHandler) List(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var params dto.SuppressionQueryParams
+	if err := c.QueryParser(&params); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid query parameters",
+		})
+	}
+	params.Normalize()
+
+	entries, total, err := h.suppressionService.List(userID, params.Search, params.Limit, (params.Page-1)*params.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch suppression list",
+		})
+	}
+
+	return c.JSON(dto.PaginatedSuppressions{
+		Data:       dto.ToSuppressedNumberDTOList(entries),
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: int(math.Ceil(float64(total) / float64(params.Limit))),
+	})
+}
+
+// Import accepts either {"phones": [...]} or a CSV/plain-text body with one
+// number in the first column of each line.
+func (h *SuppressionHandler) Import(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var phones []string
+	contentType := string(c.Request().Header.ContentType())
+	if strings.HasPrefix(contentType, "text/csv") || strings.HasPrefix(contentType, "text/plain") {
+		phones, err = readPhoneColumn(c.Body())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid csv body",
+			})
+		}
+	} else {
+		var req dto.ImportSuppressionsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body",
+			})
+		}
+		phones = req.Phones
+	}
+
+	if len(phones) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "phones are required",
+		})
+	}
+	if len(phones) > services.MaxSuppressionImport {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "at most 10000 numbers can be imported at once",
+		})
+	}
+
+	result, err := h.suppressionService.Import(userID, phones)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to import suppression list",
+		})
+	}
+
+	return c.JSON(result)
+}
+
+func (h *SuppressionHandler) Remove(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	phone, err := url.PathUnescape(c.Params("phone"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid phone number",
+		})
+	}
+
+	if err := h.suppressionService.Remove(userID, phone); err != nil {
+		if errors.Is(err, services.ErrInvalidPhone) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, services.ErrSuppressionNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "number is not suppressed",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to remove suppressed number",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "number removed from suppression list",
+	})
+}
+
+func (h *SuppressionHandler) GetKeywords(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	keywords, err := h.suppressionService.Keywords(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch keywords",
+		})
+	}
+
+	return c.JSON(keywords)
+}
+
+func (h *SuppressionHandler) UpdateKeywords(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.SuppressionKeywordsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	keywords, err := h.suppressionService.UpdateKeywords(userID, &services.SuppressionKeywords{
+		OptOut: req.OptOut,
+		OptIn:  req.OptIn,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidKeywords) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to update keywords",
+		})
+	}
+
+	return c.JSON(keywords)
+}
+
+func readPhoneColumn(body []byte) ([]string, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var phones []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return phones, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || strings.EqualFold(strings.TrimSpace(record[0]), "phone") {
+			continue
+		}
+		phones = append(phones, record[0])
+	}
+}
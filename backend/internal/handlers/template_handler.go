@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+type TemplateHandler struct {
+	templateService services.TemplateService
+}
+
+func NewTemplateHandler(templateService services.TemplateService) *TemplateHandler {
+	return &TemplateHandler{templateService: templateService}
+}
+
+func (h *TemplateHandler) RegisterRoutes(router fiber.Router, authMiddleware fiber.Handler) {
+	templates := router.Group("/templates", authMiddleware)
+	templates.Get("/", h.List)
+	templates.Post("/", h.Create)
+	templates.Get("/:id", h.Get)
+	templates.Put("/:id", h.Update)
+	templates.Delete("/:id", h.Delete)
+	templates.Post("/:id/render", h.Render)
+}
+
+func (h *TemplateHandler) List(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	templates, err := h.templateService.List(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to fetch templates",
+		})
+	}
+
+	dtos := make([]dto.TemplateDTO, len(templates))
+	for i := range templates {
+		dtos[i] = toTemplateDTO(&templates[i])
+	}
+
+	return c.JSON(fiber.Map{
+		"templates": dtos,
+	})
+}
+
+func (h *TemplateHandler) Get(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid template id",
+		})
+	}
+
+	template, err := h.templateService.Get(uint(id), userID)
+	if err != nil {
+		return templateError(c, err, "failed to fetch template")
+	}
+
+	return c.JSON(toTemplateDTO(template))
+}
+
+func (h *TemplateHandler) Create(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	var req dto.TemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	template, err := h.templateService.Create(userID, &services.TemplateRequest{
+		Name:     req.Name,
+		Body:     req.Body,
+		Locales:  req.Locales,
+		Defaults: req.Defaults,
+	})
+	if err != nil {
+		return templateError(c, err, "failed to create template")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(toTemplateDTO(template))
+}
+
+func (h *TemplateHandler) Update(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid template id",
+		})
+	}
+
+	var req dto.TemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	template, err := h.templateService.Update(uint(id), userID, &services.TemplateRequest{
+		Name:     req.Name,
+		Body:     req.Body,
+		Locales:  req.Locales,
+		Defaults: req.Defaults,
+	})
+	if err != nil {
+		return templateError(c, err, "failed to update template")
+	}
+
+	return c.JSON(toTemplateDTO(template))
+}
+
+func (h *TemplateHandler) Delete(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid template id",
+		})
+	}
+
+	if err := h.templateService.Delete(uint(id), userID); err != nil {
+		return templateError(c, err, "failed to delete template")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "template deleted",
+	})
+}
+
+// Render previews a template with the given variables, including the
+// encoding and segment count the message would be sent with.
+func (h *TemplateHandler) Render(c *fiber.Ctx) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "unauthorized",
+		})
+	}
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid template id",
+		})
+	}
+
+	var req dto.RenderTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	rendered, err := h.templateService.Render(uint(id), userID, req.Locale, req.Variables)
+	if err != nil {
+		return templateError(c, err, "failed to render template")
+	}
+
+	return c.JSON(toRenderedTemplateResponse(rendered))
+}
+
+func toTemplateDTO(template *models.MessageTemplate) dto.TemplateDTO {
+	return dto.ToTemplateDTO(template, services.Placeholders(template.Body))
+}
+
+func toRenderedTemplateResponse(rendered *services.RenderedMessage) dto.RenderedTemplateResponse {
+	return dto.RenderedTemplateResponse{
+		Content:  rendered.Content,
+		Locale:   rendered.Locale,
+		Encoding: string(rendered.Encoding),
+		Segments: rendered.Segments,
+	}
+}
+
+func templateError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "template not found",
+		})
+	case errors.Is(err, services.ErrDuplicateTemplateName):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "a template with this name already exists",
+		})
+	case errors.Is(err, services.ErrInvalidTemplate), errors.Is(err, services.ErrMissingVariable):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": fallback,
+	})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+	ws "github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+)
+
+// UserWSHandler serves the dashboard's live event stream.
+type UserWSHandler struct {
+	hub *ws.UserHub
+}
+
+func NewUserWSHandler(hub *ws.UserHub) *UserWSHandler {
+	return &UserWSHandler{hub: hub}
+}
+
+// HandleUserWS runs behind middleware.WebSocketJWTMiddleware, which leaves
+// the user ID in the connection's locals.
+func (h *UserWSHandler) HandleUserWS(c *websocket.Conn) {
+	defer c.Close()
+
+	userIDStr, _ := c.Locals("user_id").(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		log.Printf("user socket without a valid user id")
+		return
+	}
+
+	conn := ws.NewUserConnection(userID, c, h.hub)
+	h.hub.Register(conn)
+
+	go conn.WritePump()
+	conn.ReadPump()
+}
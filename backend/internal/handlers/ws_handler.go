@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
 	ws "github.com/octopuslowtech/tinghook-project/backend/internal/websockets"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/workers"
 )
 
 const (
@@ -22,6 +26,11 @@ type WSHandler struct {
 	logService    services.LogService
 	ruleService   services.RuleService
 	deviceHandler *ws.DeviceHandler
+	events        services.EventBus
+
+	// allowAPIKeyAuth lets devices without a token authenticate with the
+	// account API key
+	allowAPIKeyAuth bool
 }
 
 func NewWSHandler(
@@ -30,8 +39,67 @@ func NewWSHandler(
 	deviceService services.DeviceService,
 	logService services.LogService,
 	ruleService services.RuleService,
+	policyService services.CapturePolicyService,
+	outbound services.OutboundService,
+	suppressions services.SuppressionService,
+	commands services.CommandService,
+	events services.EventBus,
+	telemetry services.TelemetryService,
+	dispatcher *workers.WebhookDispatcher,
+	allowAPIKeyAuth bool,
 ) *WSHandler {
-	deviceHandler := ws.NewDeviceHandler(hub, userService, deviceService, logService, ruleService)
+	deviceHandler := ws.NewDeviceHandler(hub, userService, deviceService, logService, ruleService, policyService, outbound, suppressions, commands, events, telemetry, dispatcher)
+	hub.UseOutbox(commands)
+	commands.OnFailed(func(cmd *models.DeviceCommand) {
+		if cmd.Type != ws.MsgTypeSendSMS {
+			return
+		}
+		if err := outbound.MarkUnacknowledged(cmd.Ref, cmd.DeviceID, "device did not acknowledge send command: "+cmd.Error); err != nil {
+			log.Printf("failed to mark request %s as failed: %v", cmd.Ref, err)
+		}
+	})
+	// A message that expired or was cancelled must not be sent by a device
+	// that reconnects later
+	outbound.AddStatusListener(func(msgLog *models.MessageLog) {
+		if msgLog.Status != models.StatusExpired && msgLog.Status != models.StatusFailed {
+			return
+		}
+		if err := commands.CancelRef(msgLog.RequestID, "message "+string(msgLog.Status)); err != nil {
+			log.Printf("failed to cancel send commands for request %s: %v", msgLog.RequestID, err)
+		}
+	})
+	// Status follows the hub's session so a superseded socket closing late
+	// cannot mark a reconnected device offline
+	hub.OnRegister(func(conn *ws.DeviceConnection) {
+		if err := deviceService.SetOnline(conn.DeviceID, 0); err != nil {
+			log.Printf("failed to set device online: %v", err)
+		}
+		services.PublishEvent(events, conn.UserID, services.EventDeviceOnline, &conn.DeviceID, &dto.DeviceEventData{
+			DeviceID: conn.DeviceID.String(),
+			Status:   models.DeviceStatusOnline,
+		})
+	})
+	hub.OnUnregister(func(conn *ws.DeviceConnection) {
+		if err := deviceService.SetOffline(conn.DeviceID); err != nil {
+			log.Printf("failed to set device offline: %v", err)
+		}
+		services.PublishEvent(events, conn.UserID, services.EventDeviceOffline, &conn.DeviceID, &dto.DeviceEventData{
+			DeviceID: conn.DeviceID.String(),
+			Status:   models.DeviceStatusOffline,
+		})
+		log.Printf("device %s disconnected", conn.DeviceID)
+	})
+	outbound.AddStatusListener(func(msgLog *models.MessageLog) {
+		services.PublishEvent(events, msgLog.UserID, services.EventLogStatus, msgLog.DeviceID, dto.ToLogDTO(msgLog))
+	})
+	if dispatcher != nil {
+		outbound.AddStatusListener(workers.NewStatusCallbackListener(dispatcher, userService))
+	}
+	hub.OnRegister(func(conn *ws.DeviceConnection) {
+		if _, err := outbound.FlushDevice(conn.UserID, conn.DeviceID); err != nil {
+			log.Printf("failed to flush queued messages for device %s: %v", conn.DeviceID, err)
+		}
+	})
 	return &WSHandler{
 		hub:           hub,
 		userService:   userService,
@@ -39,6 +107,9 @@ func NewWSHandler(
 		logService:    logService,
 		ruleService:   ruleService,
 		deviceHandler: deviceHandler,
+		events:        events,
+
+		allowAPIKeyAuth: allowAPIKeyAuth,
 	}
 }
 
@@ -60,12 +131,11 @@ func (h *WSHandler) HandleDeviceWS(c *websocket.Conn) {
 	select {
 	case conn := <-authChan:
 		h.hub.RegisterDevice(conn)
+		h.deviceHandler.SendCapturePolicy(conn)
 
 		go conn.WritePump()
 		conn.ReadPump(h.deviceHandler.HandleMessage)
 
-		h.handleDisconnect(conn)
-
 	case err := <-errChan:
 		log.Printf("auth failed: %v", err)
 		h.sendAuthFail(c, err.Error())
@@ -96,34 +166,32 @@ func (h *WSHandler) waitForAuth(c *websocket.Conn) (*ws.DeviceConnection, error)
 		return nil, err
 	}
 
-	user, err := h.userService.GetByAPIKey(authData.APIKey)
+	protocol, err := ws.Negotiate(authData.ProtocolVersion, authData.Capabilities)
 	if err != nil {
-		return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	device, err := h.deviceService.GetByDeviceUID(authData.DeviceUID)
+	device, deviceToken, err := h.authenticate(&authData, protocol)
 	if err != nil {
-		device, err = h.deviceService.Register(user.ID, "Unknown Device", authData.DeviceUID)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if device.UserID != user.ID {
-		return nil, fiber.NewError(fiber.StatusUnauthorized, "device belongs to another user")
+		return nil, err
 	}
 
-	conn, err := ws.NewDeviceConnection(device.ID.String(), user.ID.String(), c, h.hub)
+	conn, err := ws.NewDeviceConnection(device.ID.String(), device.UserID.String(), c, h.hub)
 	if err != nil {
 		return nil, err
 	}
+	conn.Protocol = protocol
+	conn.AppVersion = authData.AppVersion
 
-	if err := h.deviceService.SetOnline(device.ID, 0); err != nil {
-		log.Printf("failed to set device online: %v", err)
+	if err := h.deviceService.UpdateProtocol(device.ID, authData.AppVersion, protocol.Version); err != nil {
+		log.Printf("failed to record protocol of device %s: %v", device.ID, err)
 	}
 
 	authOKMsg, err := ws.NewMessage(ws.MsgTypeAuthOK, &ws.AuthOKData{
-		DeviceID: device.ID.String(),
+		DeviceID:        device.ID.String(),
+		DeviceToken:     deviceToken,
+		ProtocolVersion: protocol.Version,
+		Capabilities:    protocol.List(),
 	})
 	if err != nil {
 		return nil, err
@@ -138,15 +206,88 @@ func (h *WSHandler) waitForAuth(c *websocket.Conn) (*ws.DeviceConnection, error)
 		return nil, err
 	}
 
-	log.Printf("device %s authenticated for user %s", device.ID, user.ID)
+	log.Printf("device %s authenticated for user %s (protocol v%d, app %q)", device.ID, device.UserID, protocol.Version, authData.AppVersion)
 	return conn, nil
 }
 
-func (h *WSHandler) handleDisconnect(conn *ws.DeviceConnection) {
-	if err := h.deviceService.SetOffline(conn.DeviceID); err != nil {
-		log.Printf("failed to set device offline: %v", err)
+// authenticate resolves the device from its own token, or pairs it by
+// redeeming a pairing token, in which case the device's new token is returned
+// too. The account API key is only accepted from existing devices that were
+// never issued a token, while allowAPIKeyAuth keeps older app builds working;
+// those that can store a token are issued one so they move off the API key.
+func (h *WSHandler) authenticate(authData *ws.AuthData, protocol *ws.Negotiated) (*models.Device, string, error) {
+	if authData.DeviceToken != "" {
+		device, err := h.deviceService.AuthenticateToken(authData.DeviceToken)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidDeviceToken) || errors.Is(err, services.ErrDeviceRevoked) {
+				return nil, "", fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			}
+			return nil, "", err
+		}
+		if authData.DeviceUID != "" && authData.DeviceUID != device.DeviceUID {
+			return nil, "", fiber.NewError(fiber.StatusUnauthorized, "device token does not match device")
+		}
+		return device, "", nil
+	}
+
+	if authData.PairingToken != "" {
+		device, token, err := h.deviceService.RedeemPairingToken(authData.PairingToken, authData.DeviceUID, authData.DeviceName)
+		if err != nil {
+			if errors.Is(err, services.ErrPairingTokenInvalid) {
+				return nil, "", fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			}
+			if errors.Is(err, services.ErrDuplicateDeviceUID) {
+				return nil, "", fiber.NewError(fiber.StatusUnauthorized, "device belongs to another user")
+			}
+			return nil, "", err
+		}
+		log.Printf("device %s paired for user %s", device.ID, device.UserID)
+		services.PublishEvent(h.events, device.UserID, services.EventDevicePaired, &device.ID, &dto.DeviceEventData{
+			DeviceID: device.ID.String(),
+			Status:   device.Status,
+			Name:     device.Name,
+		})
+		return device, token, nil
+	}
+
+	if !h.allowAPIKeyAuth {
+		return nil, "", fiber.NewError(fiber.StatusUnauthorized, "device token required")
+	}
+
+	user, err := h.userService.GetByAPIKey(authData.APIKey)
+	if err != nil {
+		return nil, "", fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
+	}
+
+	// The API key only lets in devices that exist already; new ones must pair,
+	// or a revoked phone could come back under a fresh device_uid
+	device, err := h.deviceService.GetByDeviceUID(authData.DeviceUID)
+	if err != nil {
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			return nil, "", fiber.NewError(fiber.StatusUnauthorized, "unknown device: pair it first")
+		}
+		return nil, "", err
+	}
+
+	if device.UserID != user.ID {
+		return nil, "", fiber.NewError(fiber.StatusUnauthorized, "device belongs to another user")
+	}
+	if device.RevokedAt != nil {
+		return nil, "", fiber.NewError(fiber.StatusUnauthorized, services.ErrDeviceRevoked.Error())
+	}
+	if device.TokenHash != "" {
+		return nil, "", fiber.NewError(fiber.StatusUnauthorized, "device token required")
+	}
+
+	if !protocol.Supports(ws.CapDeviceToken) {
+		return device, "", nil
+	}
+	token, err := h.deviceService.IssueToken(user.ID, device.ID)
+	if err != nil {
+		return nil, "", err
 	}
-	log.Printf("device %s disconnected", conn.DeviceID)
+	log.Printf("device %s moved from the api key to a device token", device.ID)
+	return device, token, nil
 }
 
 func (h *WSHandler) sendAuthFail(c *websocket.Conn, errorMsg string) {
@@ -1,10 +1,15 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
 )
 
@@ -25,26 +30,46 @@ func JWTMiddleware(jwtSecret string) fiber.Handler {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid authorization header format"})
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid signing method")
-			}
-			return []byte(jwtSecret), nil
-		})
+		return authenticateJWT(c, parts[1], jwtSecret)
+	}
+}
 
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+// WebSocketJWTMiddleware authenticates a socket upgrade. Browsers cannot set
+// headers on WebSocket requests, so the token may also come in the token
+// query parameter.
+func WebSocketJWTMiddleware(jwtSecret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := c.Query("token")
+		if parts := strings.Split(c.Get("Authorization"), " "); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+			tokenString = parts[1]
+		}
+		if tokenString == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing token"})
 		}
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token claims"})
+		return authenticateJWT(c, tokenString, jwtSecret)
+	}
+}
+
+func authenticateJWT(c *fiber.Ctx, tokenString, jwtSecret string) error {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid signing method")
 		}
+		return []byte(jwtSecret), nil
+	})
 
-		c.Locals("user_id", claims.UserID)
-		return c.Next()
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
 	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token claims"})
+	}
+
+	c.Locals("user_id", claims.UserID)
+	return c.Next()
 }
 
 func APIKeyMiddleware(userService services.UserService) fiber.Handler {
@@ -76,3 +101,71 @@ func RateLimiter() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// IdempotencyMiddleware honours the Idempotency-Key header on the routes it
+// wraps. A retry with the same key and body replays the stored response; a
+// different body under the same key is rejected with 422.
+func IdempotencyMiddleware(idempotencyService services.IdempotencyService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := strings.TrimSpace(c.Get("Idempotency-Key"))
+		if key == "" {
+			return c.Next()
+		}
+
+		if len(key) > services.MaxIdempotencyKeyLen {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "idempotency key too long"})
+		}
+
+		userID, err := uuid.Parse(fmt.Sprint(c.Locals("user_id")))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+
+		record, replay, err := idempotencyService.Begin(userID, key, requestFingerprint(c))
+		if err != nil {
+			if errors.Is(err, services.ErrIdempotencyKeyReused) {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "idempotency key was already used with a different request"})
+			}
+			if errors.Is(err, services.ErrIdempotencyKeyInProgress) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "a request with this idempotency key is still in progress"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to check idempotency key"})
+		}
+
+		if replay {
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Status(record.StatusCode).Send(record.ResponseBody)
+		}
+
+		stop := idempotencyService.KeepAlive(record)
+		err = c.Next()
+		stop()
+		if err != nil {
+			_ = idempotencyService.Abandon(record)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			_ = idempotencyService.Abandon(record)
+			return nil
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		if err := idempotencyService.Complete(record, status, body); err != nil {
+			_ = idempotencyService.Abandon(record)
+		}
+		return nil
+	}
+}
+
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.Path()))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
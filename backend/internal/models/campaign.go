@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CampaignStatus string
+
+const (
+	CampaignRunning   CampaignStatus = "running"
+	CampaignPaused    CampaignStatus = "paused"
+	CampaignCancelled CampaignStatus = "cancelled"
+	CampaignCompleted CampaignStatus = "completed"
+)
+
+type RecipientStatus string
+
+const (
+	RecipientPending   RecipientStatus = "pending"
+	RecipientSubmitted RecipientStatus = "submitted"
+	RecipientRejected  RecipientStatus = "rejected"
+	RecipientCancelled RecipientStatus = "cancelled"
+)
+
+// Campaign is a bulk send: one message body rendered per recipient and fed to
+// the outbound queue at a paced rate.
+type Campaign struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name          string         `gorm:"size:255" json:"name"`
+	Content       string         `gorm:"type:text;not null" json:"content"`
+	Status        CampaignStatus `gorm:"size:20;default:running;index" json:"status"`
+	Total         int            `gorm:"not null" json:"total"`
+	Segments      int            `gorm:"default:0" json:"segments"`
+	Strategy      string         `gorm:"size:20" json:"strategy"`
+	RatePerMinute int            `gorm:"not null" json:"rate_per_minute"`
+	TTLSeconds    int            `gorm:"default:0" json:"ttl_seconds"`
+	MaxAttempts   int            `gorm:"default:1" json:"max_attempts"`
+	Transliterate bool           `gorm:"default:false" json:"transliterate"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	CompletedAt   *time.Time     `json:"completed_at,omitempty"`
+
+	StatusCallbackURL string `gorm:"type:text" json:"-"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// CampaignRecipient is one row of a campaign. Once submitted it is linked to
+// the outbound message through RequestID.
+type CampaignRecipient struct {
+	ID          uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	CampaignID  uuid.UUID         `gorm:"type:uuid;not null;index" json:"campaign_id"`
+	Phone       string            `gorm:"size:50;not null" json:"phone"`
+	Variables   map[string]string `gorm:"type:text;serializer:json" json:"variables,omitempty"`
+	Status      RecipientStatus   `gorm:"size:20;default:pending;index" json:"status"`
+	RequestID   string            `gorm:"size:36" json:"request_id,omitempty"`
+	Error       string            `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	SubmittedAt *time.Time        `json:"submitted_at,omitempty"`
+}
+
+func (CampaignRecipient) TableName() string {
+	return "campaign_recipients"
+}
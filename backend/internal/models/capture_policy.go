@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CaptureModeAllowlist = "allowlist"
+	CaptureModeBlocklist = "blocklist"
+)
+
+// CapturePolicy controls which Android packages a device forwards notifications
+// for. A policy with a nil DeviceID applies account-wide; a device-specific
+// policy takes precedence over it.
+type CapturePolicy struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	DeviceID  *uuid.UUID `gorm:"type:uuid;index" json:"device_id,omitempty"`
+	Mode      string     `gorm:"size:20;not null;default:blocklist" json:"mode"`
+	Packages  []string   `gorm:"type:text;serializer:json" json:"packages"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Relations
+	User   User    `gorm:"foreignKey:UserID" json:"-"`
+	Device *Device `gorm:"foreignKey:DeviceID" json:"-"`
+}
+
+func (CapturePolicy) TableName() string {
+	return "capture_policies"
+}
+
+// Allows reports whether notifications from the given package may be captured.
+func (p *CapturePolicy) Allows(pkg string) bool {
+	listed := false
+	for _, candidate := range p.Packages {
+		if candidate == pkg {
+			listed = true
+			break
+		}
+	}
+
+	if p.Mode == CaptureModeAllowlist {
+		return listed
+	}
+	return !listed
+}
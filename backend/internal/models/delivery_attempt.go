@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryAttempt records one hop of an outbound message: the device and SIM
+// it was handed to and how that attempt ended.
+type DeliveryAttempt struct {
+	ID          uint          `gorm:"primaryKey;autoIncrement" json:"id"`
+	LogID       uint          `gorm:"not null;index" json:"log_id"`
+	Attempt     int           `gorm:"not null" json:"attempt"`
+	DeviceID    uuid.UUID     `gorm:"type:uuid;not null" json:"device_id"`
+	SimSlot     int           `gorm:"default:0" json:"sim_slot"`
+	Status      MessageStatus `gorm:"size:20;default:pending" json:"status"`
+	Error       string        `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty"`
+}
+
+func (DeliveryAttempt) TableName() string {
+	return "delivery_attempts"
+}
@@ -12,16 +12,25 @@ const (
 )
 
 type Device struct {
-	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
-	Name         string     `gorm:"not null" json:"name"`
-	DeviceUID    string     `gorm:"uniqueIndex;not null" json:"device_uid"`
-	FCMToken     string     `gorm:"type:text" json:"-"`
-	Status       string     `gorm:"default:offline" json:"status"`
-	BatteryLevel int        `gorm:"default:0" json:"battery_level"`
-	AppVersion   string     `json:"app_version"`
-	LastSeenAt   *time.Time `json:"last_seen_at"`
-	CreatedAt    time.Time  `json:"created_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name            string     `gorm:"not null" json:"name"`
+	DeviceUID       string     `gorm:"uniqueIndex;not null" json:"device_uid"`
+	FCMToken        string     `gorm:"type:text" json:"-"`
+	Status          string     `gorm:"default:offline" json:"status"`
+	BatteryLevel    int        `gorm:"default:0" json:"battery_level"`
+	SimCount        int        `gorm:"default:1" json:"sim_count"`
+	AppVersion      string     `json:"app_version"`
+	ProtocolVersion int        `gorm:"default:1" json:"protocol_version"`
+	LastSeenAt      *time.Time `json:"last_seen_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	// TokenHash is the SHA-256 of the device's socket credential; the token
+	// itself is only shown once when it is issued
+	TokenHash     string     `gorm:"size:64;index" json:"-"`
+	TokenIssuedAt *time.Time `json:"token_issued_at,omitempty"`
+	// RevokedAt blocks the device from connecting until a new token is issued
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 
 	// Relations
 	User            User             `gorm:"foreignKey:UserID" json:"-"`
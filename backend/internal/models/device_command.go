@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CommandStatus string
+
+const (
+	// CommandPending commands were written to the device and wait for its ACK
+	CommandPending CommandStatus = "pending"
+	CommandAcked   CommandStatus = "acked"
+	// CommandCompleted commands reported a successful result
+	CommandCompleted CommandStatus = "completed"
+	// CommandFailed commands ran out of resends, expired unacknowledged or
+	// reported an error
+	CommandFailed CommandStatus = "failed"
+)
+
+// Remote control commands. Unlike SEND_SMS they answer with a
+// COMMAND_RESULT frame, stored in DeviceCommand.Result.
+const (
+	CommandRequestStatus  = "REQUEST_STATUS"
+	CommandRestartService = "RESTART_SERVICE"
+	CommandUpdateConfig   = "UPDATE_CONFIG"
+	CommandUploadLogs     = "UPLOAD_LOGS"
+	CommandRunUSSD        = "RUN_USSD"
+)
+
+// DeviceCommand is a server-to-device frame that must be acknowledged. Until
+// the device ACKs its ID the command stays in the device's outbox and is
+// resent whenever the device reconnects.
+type DeviceCommand struct {
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	DeviceID uuid.UUID `gorm:"type:uuid;not null;index" json:"device_id"`
+	Type     string    `gorm:"size:50;not null" json:"type"`
+
+	// Ref correlates the command with the entity it acts on, e.g. the
+	// request ID of a SEND_SMS
+	Ref string `gorm:"size:100;index" json:"ref,omitempty"`
+
+	Payload   []byte        `gorm:"type:bytea" json:"-"`
+	Status    CommandStatus `gorm:"size:20;default:pending;index" json:"status"`
+	Attempts  int           `gorm:"default:1" json:"attempts"`
+	Error     string        `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	SentAt    time.Time     `json:"sent_at"`
+	AckedAt   *time.Time    `json:"acked_at,omitempty"`
+	ExpiresAt time.Time     `gorm:"index" json:"expires_at"`
+
+	// Result is the JSON a remote control command answered with
+	Result      []byte     `gorm:"type:bytea" json:"-"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func (DeviceCommand) TableName() string {
+	return "device_commands"
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TelemetryResolution string
+
+const (
+	// TelemetryRaw rows are single heartbeats
+	TelemetryRaw TelemetryResolution = "raw"
+	// TelemetryHourly rows summarise an hour of raw rows once they age out
+	TelemetryHourly TelemetryResolution = "hour"
+)
+
+// DeviceTelemetry is one point of a device's health time series. Hourly rows
+// hold averages for Battery and Signal, the lowest battery in MinBattery,
+// the highest QueueDepth and the last NetworkType and AppVersion seen.
+type DeviceTelemetry struct {
+	ID          uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeviceID    uuid.UUID           `gorm:"type:uuid;not null;index:idx_device_telemetry_device_time,priority:1" json:"device_id"`
+	Resolution  TelemetryResolution `gorm:"size:10;not null;default:raw" json:"resolution"`
+	RecordedAt  time.Time           `gorm:"not null;index:idx_device_telemetry_device_time,priority:2" json:"recorded_at"`
+	Battery     int                 `json:"battery"`
+	MinBattery  int                 `json:"min_battery"`
+	Charging    bool                `json:"charging"`
+	Signal      int                 `json:"signal"`
+	NetworkType string              `gorm:"size:20" json:"network_type,omitempty"`
+	QueueDepth  int                 `json:"queue_depth"`
+	AppVersion  string              `gorm:"size:50" json:"app_version,omitempty"`
+	// Samples is 1 for raw rows and the number of heartbeats behind an
+	// hourly row
+	Samples int `gorm:"default:1" json:"samples"`
+	// OnlineMinutes counts the minutes of an hourly row that had at least one
+	// heartbeat; it is unused on raw rows
+	OnlineMinutes int `gorm:"default:0" json:"online_minutes"`
+}
+
+func (DeviceTelemetry) TableName() string {
+	return "device_telemetry"
+}
+
+// TelemetryPoint is one bucket of a device's telemetry series. Battery and
+// Signal are averaged over the heartbeats in the bucket.
+type TelemetryPoint struct {
+	Bucket      time.Time
+	Battery     float64
+	MinBattery  int
+	Charging    bool
+	Signal      float64
+	NetworkType string
+	QueueDepth  int
+	AppVersion  string
+	Samples     int
+}
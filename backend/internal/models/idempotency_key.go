@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey remembers the outcome of a request sent with an
+// Idempotency-Key header so retries can be answered without side effects.
+// A zero StatusCode means the original request is still being processed;
+// once LockedUntil passes, a retry may take the key over.
+type IdempotencyKey struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_keys_user_key" json:"user_id"`
+	Key          string     `gorm:"size:255;not null;uniqueIndex:idx_idempotency_keys_user_key" json:"key"`
+	Fingerprint  string     `gorm:"size:64;not null" json:"-"`
+	StatusCode   int        `gorm:"default:0" json:"status_code"`
+	ResponseBody []byte     `gorm:"type:bytea" json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `gorm:"index;not null" json:"expires_at"`
+	LockedUntil  *time.Time `json:"-"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
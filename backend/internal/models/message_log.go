@@ -8,35 +8,77 @@ import (
 
 type MessageDirection string
 type MessageStatus string
+type MessageType string
 
 const (
 	DirectionInbound  MessageDirection = "inbound"
 	DirectionOutbound MessageDirection = "outbound"
 
+	StatusQueued    MessageStatus = "queued"
 	StatusPending   MessageStatus = "pending"
 	StatusSent      MessageStatus = "sent"
 	StatusDelivered MessageStatus = "delivered"
 	StatusFailed    MessageStatus = "failed"
+	StatusExpired   MessageStatus = "expired"
+
+	MessageTypeSMS          MessageType = "sms"
+	MessageTypeNotification MessageType = "notification"
 )
 
 type MessageLog struct {
 	ID           uint             `gorm:"primaryKey" json:"id"`
 	UserID       uuid.UUID        `gorm:"type:uuid;not null;index" json:"user_id"`
 	DeviceID     *uuid.UUID       `gorm:"type:uuid;index" json:"device_id,omitempty"`
+	RequestID    string           `gorm:"size:36;uniqueIndex:idx_message_logs_request_id,where:request_id IS NOT NULL AND request_id <> ''" json:"request_id,omitempty"`
+	Type         MessageType      `gorm:"size:20;not null;default:sms;index" json:"type"`
 	Direction    MessageDirection `gorm:"not null" json:"direction"`
 	SimSlot      int              `gorm:"default:0" json:"sim_slot"`
 	Sender       string           `gorm:"size:50" json:"sender"`
 	Receiver     string           `gorm:"size:50" json:"receiver"`
 	Content      string           `gorm:"type:text" json:"content"`
+	Encoding     string           `gorm:"size:10" json:"encoding,omitempty"`
+	Segments     int              `gorm:"default:0" json:"segments"`
 	Status       MessageStatus    `gorm:"default:pending" json:"status"`
 	ErrorMessage string           `gorm:"type:text" json:"error_message,omitempty"`
 	RetryCount   int              `gorm:"default:0" json:"retry_count"`
+	MaxAttempts  int              `gorm:"default:1" json:"max_attempts"`
+	Pinned       bool             `gorm:"default:false" json:"-"`
 	CreatedAt    time.Time        `gorm:"index" json:"created_at"`
 	ProcessedAt  *time.Time       `json:"processed_at,omitempty"`
+	ExpiresAt    *time.Time       `gorm:"index" json:"expires_at,omitempty"`
+
+	// RemoteNumber is the other party of an SMS, the sender of inbound and
+	// the receiver of outbound messages; threads group on it
+	RemoteNumber string `gorm:"size:50" json:"remote_number,omitempty"`
+	// ReadAt marks an inbound SMS as seen in its conversation thread
+	ReadAt *time.Time `json:"read_at,omitempty"`
+
+	// EventID is the device-generated ID of the inbound event this log was
+	// created from; a replayed event with the same ID is not stored twice
+	EventID *string `gorm:"size:64" json:"event_id,omitempty"`
+
+	// CampaignID links messages produced by a bulk send to their campaign
+	CampaignID *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+
+	// StatusCallbackURL receives signed status transitions of an outbound message
+	StatusCallbackURL string `gorm:"type:text" json:"-"`
+
+	// Outbound lifecycle timestamps; CreatedAt doubles as the queued time
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+	FailedAt     *time.Time `json:"failed_at,omitempty"`
+
+	// Notification fields, only set when Type is MessageTypeNotification
+	AppPackage string `gorm:"size:255;index" json:"app_package,omitempty"`
+	AppName    string `gorm:"size:255" json:"app_name,omitempty"`
+	Title      string `gorm:"type:text" json:"title,omitempty"`
+	Category   string `gorm:"size:50" json:"category,omitempty"`
 
 	// Relations
-	User   User    `gorm:"foreignKey:UserID" json:"-"`
-	Device *Device `gorm:"foreignKey:DeviceID" json:"-"`
+	User     User              `gorm:"foreignKey:UserID" json:"-"`
+	Device   *Device           `gorm:"foreignKey:DeviceID" json:"-"`
+	Attempts []DeliveryAttempt `gorm:"foreignKey:LogID" json:"attempts,omitempty"`
 }
 
 func (MessageLog) TableName() string {
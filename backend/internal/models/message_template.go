@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageTemplate is reusable SMS text with {{name}} placeholders. Locales
+// maps a locale tag such as "vi" or "en-US" to a translated body; Body is
+// used when no variant matches.
+type MessageTemplate struct {
+	ID        uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_message_templates_user_name" json:"user_id"`
+	Name      string            `gorm:"size:100;not null;uniqueIndex:idx_message_templates_user_name" json:"name"`
+	Body      string            `gorm:"type:text;not null" json:"body"`
+	Locales   map[string]string `gorm:"type:text;serializer:json" json:"locales"`
+	Defaults  map[string]string `gorm:"type:text;serializer:json" json:"defaults"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (MessageTemplate) TableName() string {
+	return "message_templates"
+}
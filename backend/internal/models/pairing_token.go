@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PairingStatus string
+
+const (
+	PairingPending PairingStatus = "pending"
+	PairingPaired  PairingStatus = "paired"
+	// PairingExpired is never stored; pending tokens past ExpiresAt report it
+	PairingExpired PairingStatus = "expired"
+)
+
+// PairingToken is the single-use secret shown in the dashboard QR code. The
+// phone redeems it in its AUTH frame to create the device under the user
+// who generated it.
+type PairingToken struct {
+	ID         uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID     `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash  string        `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	DeviceName string        `gorm:"size:100" json:"device_name,omitempty"`
+	Status     PairingStatus `gorm:"size:20;default:pending" json:"status"`
+	DeviceID   *uuid.UUID    `gorm:"type:uuid" json:"device_id,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ExpiresAt  time.Time     `gorm:"index;not null" json:"expires_at"`
+	PairedAt   *time.Time    `json:"paired_at,omitempty"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (PairingToken) TableName() string {
+	return "pairing_tokens"
+}
+
+// CurrentStatus reports PairingExpired for pending tokens that can no longer
+// be redeemed.
+func (t *PairingToken) CurrentStatus(now time.Time) PairingStatus {
+	if t.Status == PairingPending && !now.Before(t.ExpiresAt) {
+		return PairingExpired
+	}
+	return t.Status
+}
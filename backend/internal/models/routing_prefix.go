@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	RoutingRoundRobin   = "round_robin"
+	RoutingLeastLoaded  = "least_loaded"
+	RoutingBatteryAware = "battery_aware"
+	RoutingSticky       = "sticky"
+	RoutingCarrier      = "carrier_prefix"
+)
+
+// RoutingPrefix pins recipients whose number starts with Prefix to a device
+// and SIM, used by the carrier_prefix routing strategy.
+type RoutingPrefix struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Prefix    string    `gorm:"size:20;not null" json:"prefix"`
+	DeviceID  uuid.UUID `gorm:"type:uuid;not null;index" json:"device_id"`
+	SimSlot   int       `gorm:"default:0" json:"sim_slot"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	User   User   `gorm:"foreignKey:UserID" json:"-"`
+	Device Device `gorm:"foreignKey:DeviceID" json:"-"`
+}
+
+func (RoutingPrefix) TableName() string {
+	return "routing_prefixes"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "scheduled"
+	ScheduleCompleted ScheduleStatus = "completed"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+)
+
+// ScheduledMessage is an outbound SMS held back until NextRunAt. Recurring
+// schedules move NextRunAt forward after every run until the rule ends.
+type ScheduledMessage struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Phone       string         `gorm:"size:50;not null" json:"phone"`
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	DeviceID    *uuid.UUID     `gorm:"type:uuid" json:"device_id,omitempty"`
+	SimSlot     int            `gorm:"default:0" json:"sim_slot"`
+	Strategy    string         `gorm:"size:20" json:"strategy,omitempty"`
+	TTLSeconds  int            `gorm:"default:0" json:"ttl_seconds"`
+	MaxAttempts int            `gorm:"default:1" json:"max_attempts"`
+	Timezone    string         `gorm:"size:64;default:UTC" json:"timezone"`
+	Recurrence  string         `gorm:"size:255" json:"recurrence,omitempty"`
+	Status      ScheduleStatus `gorm:"size:20;default:scheduled;index" json:"status"`
+	NextRunAt   *time.Time     `gorm:"index" json:"next_run_at,omitempty"`
+	Occurrences int            `gorm:"default:0" json:"occurrences"`
+	LastRunAt   *time.Time     `json:"last_run_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	// Outcome of the most recent run
+	LastRequestID string `gorm:"size:36" json:"last_request_id,omitempty"`
+	LastError     string `gorm:"type:text" json:"last_error,omitempty"`
+
+	StatusCallbackURL string `gorm:"type:text" json:"-"`
+
+	// AnchorAt is the run a recurring series counts from: the first send_at,
+	// or the one it was last moved to
+	AnchorAt *time.Time `json:"-"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_messages"
+}
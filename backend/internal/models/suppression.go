@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SuppressionSourceKeyword = "keyword"
+	SuppressionSourceImport  = "import"
+)
+
+// SuppressedNumber is a recipient who opted out of the user's messages.
+// Outbound sends to it are refused until it is removed or the recipient
+// opts back in.
+type SuppressedNumber struct {
+	ID     uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_suppressed_user_phone" json:"user_id"`
+	// Phone is in E.164
+	Phone  string `gorm:"size:20;not null;uniqueIndex:idx_suppressed_user_phone" json:"phone"`
+	Source string `gorm:"size:20;not null" json:"source"`
+	// Keyword and DeviceID record the inbound message that opted the number out
+	Keyword   string     `gorm:"size:50" json:"keyword,omitempty"`
+	DeviceID  *uuid.UUID `gorm:"type:uuid" json:"device_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relations
+	User User `gorm:"foreignKey:UserID" json:"-"`
+}
+
+func (SuppressedNumber) TableName() string {
+	return "suppressed_numbers"
+}
@@ -16,6 +16,26 @@ type User struct {
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 
+	// Default destination and HMAC secret for outbound status callbacks
+	StatusCallbackURL string `gorm:"type:text" json:"status_callback_url"`
+	CallbackSecret    string `gorm:"size:64" json:"-"`
+
+	// RoutingStrategy picks the sending device when a request names none
+	RoutingStrategy string `gorm:"size:20;default:round_robin" json:"routing_strategy"`
+
+	// FailoverMaxAttempts caps how many device/SIM hops an outbound message
+	// may take; 1 disables failover
+	FailoverMaxAttempts int `gorm:"default:1" json:"failover_max_attempts"`
+
+	// DefaultCountry is the ISO 3166 region national-format phone numbers
+	// are read in, e.g. "0912345678" with VN becomes +84912345678
+	DefaultCountry string `gorm:"size:2;default:VN" json:"default_country"`
+
+	// Inbound replies starting with one of these words add or remove the
+	// sender from the suppression list; nil uses the built-in defaults
+	OptOutKeywords []string `gorm:"type:text;serializer:json" json:"opt_out_keywords"`
+	OptInKeywords  []string `gorm:"type:text;serializer:json" json:"opt_in_keywords"`
+
 	// Relations
 	Devices         []Device         `gorm:"foreignKey:UserID" json:"devices,omitempty"`
 	ForwardingRules []ForwardingRule `gorm:"foreignKey:UserID" json:"forwarding_rules,omitempty"`
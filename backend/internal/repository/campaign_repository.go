@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const recipientBatchSize = 500
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+)
+
+type CampaignRepository interface {
+	Create(campaign *models.Campaign, recipients []models.CampaignRecipient) error
+	FindByID(id uuid.UUID) (*models.Campaign, error)
+	FindByUserID(userID uuid.UUID, limit, offset int) ([]models.Campaign, int64, error)
+	FindRunning() ([]models.Campaign, error)
+	UpdateStatus(id uuid.UUID, from []models.CampaignStatus, to models.CampaignStatus) (bool, error)
+	FindPendingRecipients(campaignID uuid.UUID, limit int) ([]models.CampaignRecipient, error)
+	FindRecipients(campaignID uuid.UUID) ([]models.CampaignRecipient, error)
+	MarkRecipientSubmitted(id uint, requestID string) error
+	MarkRecipientRejected(id uint, reason string) error
+	CancelPendingRecipients(campaignID uuid.UUID) (int64, error)
+	CountRecipients(campaignID uuid.UUID) (map[models.RecipientStatus]int64, error)
+}
+
+type campaignRepository struct {
+	db *gorm.DB
+}
+
+func NewCampaignRepository(db *gorm.DB) CampaignRepository {
+	return &campaignRepository{db: db}
+}
+
+// Create stores the campaign and its recipients in one transaction so a
+// half-written campaign is never picked up by the dispatcher.
+func (r *campaignRepository) Create(campaign *models.Campaign, recipients []models.CampaignRecipient) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(campaign).Error; err != nil {
+			return err
+		}
+
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(recipients, recipientBatchSize).Error
+	})
+}
+
+func (r *campaignRepository) FindByID(id uuid.UUID) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := r.db.Where("id = ?", id).First(&campaign).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func (r *campaignRepository) FindByUserID(userID uuid.UUID, limit, offset int) ([]models.Campaign, int64, error) {
+	var campaigns []models.Campaign
+	var total int64
+
+	query := r.db.Model(&models.Campaign{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&campaigns).Error
+	return campaigns, total, err
+}
+
+func (r *campaignRepository) FindRunning() ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	err := r.db.Where("status = ?", models.CampaignRunning).Order("created_at ASC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+// UpdateStatus moves the campaign to a new status only from one of the given
+// states, reporting whether the transition happened.
+func (r *campaignRepository) UpdateStatus(id uuid.UUID, from []models.CampaignStatus, to models.CampaignStatus) (bool, error) {
+	updates := map[string]interface{}{
+		"status":     to,
+		"updated_at": time.Now(),
+	}
+	if to == models.CampaignCompleted || to == models.CampaignCancelled {
+		updates["completed_at"] = time.Now()
+	}
+
+	result := r.db.Model(&models.Campaign{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *campaignRepository) FindPendingRecipients(campaignID uuid.UUID, limit int) ([]models.CampaignRecipient, error) {
+	var recipients []models.CampaignRecipient
+	err := r.db.Where("campaign_id = ? AND status = ?", campaignID, models.RecipientPending).
+		Order("id ASC").
+		Limit(limit).
+		Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *campaignRepository) FindRecipients(campaignID uuid.UUID) ([]models.CampaignRecipient, error) {
+	var recipients []models.CampaignRecipient
+	err := r.db.Where("campaign_id = ?", campaignID).Order("id ASC").Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *campaignRepository) MarkRecipientSubmitted(id uint, requestID string) error {
+	return r.db.Model(&models.CampaignRecipient{}).
+		Where("id = ? AND status = ?", id, models.RecipientPending).
+		Updates(map[string]interface{}{
+			"status":       models.RecipientSubmitted,
+			"request_id":   requestID,
+			"submitted_at": time.Now(),
+		}).Error
+}
+
+func (r *campaignRepository) MarkRecipientRejected(id uint, reason string) error {
+	return r.db.Model(&models.CampaignRecipient{}).
+		Where("id = ? AND status = ?", id, models.RecipientPending).
+		Updates(map[string]interface{}{
+			"status": models.RecipientRejected,
+			"error":  reason,
+		}).Error
+}
+
+func (r *campaignRepository) CancelPendingRecipients(campaignID uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, models.RecipientPending).
+		Update("status", models.RecipientCancelled)
+	return result.RowsAffected, result.Error
+}
+
+func (r *campaignRepository) CountRecipients(campaignID uuid.UUID) (map[models.RecipientStatus]int64, error) {
+	var rows []struct {
+		Status models.RecipientStatus
+		Count  int64
+	}
+
+	err := r.db.Model(&models.CampaignRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.RecipientStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCapturePolicyNotFound = errors.New("capture policy not found")
+)
+
+type CapturePolicyRepository interface {
+	FindByID(id uint) (*models.CapturePolicy, error)
+	FindByUserID(userID uuid.UUID) ([]models.CapturePolicy, error)
+	FindByScope(userID uuid.UUID, deviceID *uuid.UUID) (*models.CapturePolicy, error)
+	Save(policy *models.CapturePolicy) error
+	Delete(id uint) error
+}
+
+type capturePolicyRepository struct {
+	db *gorm.DB
+}
+
+func NewCapturePolicyRepository(db *gorm.DB) CapturePolicyRepository {
+	return &capturePolicyRepository{db: db}
+}
+
+func (r *capturePolicyRepository) FindByID(id uint) (*models.CapturePolicy, error) {
+	var policy models.CapturePolicy
+	err := r.db.Where("id = ?", id).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCapturePolicyNotFound
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *capturePolicyRepository) FindByUserID(userID uuid.UUID) ([]models.CapturePolicy, error) {
+	var policies []models.CapturePolicy
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&policies).Error
+	return policies, err
+}
+
+// FindByScope returns the account-wide policy when deviceID is nil, otherwise
+// the policy attached to that specific device.
+func (r *capturePolicyRepository) FindByScope(userID uuid.UUID, deviceID *uuid.UUID) (*models.CapturePolicy, error) {
+	var policy models.CapturePolicy
+	query := r.db.Where("user_id = ?", userID)
+	if deviceID == nil {
+		query = query.Where("device_id IS NULL")
+	} else {
+		query = query.Where("device_id = ?", *deviceID)
+	}
+
+	err := query.First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCapturePolicyNotFound
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *capturePolicyRepository) Save(policy *models.CapturePolicy) error {
+	now := time.Now()
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = now
+	}
+	policy.UpdatedAt = now
+	return r.db.Save(policy).Error
+}
+
+func (r *capturePolicyRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.CapturePolicy{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCapturePolicyNotFound
+	}
+	return nil
+}
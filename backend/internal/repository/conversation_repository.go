@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ThreadKey identifies a two-way SMS conversation: one remote number talking
+// to one SIM of one device.
+type ThreadKey struct {
+	DeviceID     uuid.UUID
+	SimSlot      int
+	RemoteNumber string
+}
+
+type ThreadSummary struct {
+	ThreadKey
+	LastMessageID uint
+	LastMessageAt time.Time
+	MessageCount  int64
+	UnreadCount   int64
+}
+
+type ThreadFilter struct {
+	DeviceID   *uuid.UUID
+	Search     string
+	UnreadOnly bool
+}
+
+type ConversationRepository interface {
+	FindThreads(userID uuid.UUID, filter *ThreadFilter, limit, offset int) ([]ThreadSummary, int64, error)
+	FindThread(userID uuid.UUID, key ThreadKey) (*ThreadSummary, error)
+	FindMessages(userID uuid.UUID, key ThreadKey, limit, offset int) ([]models.MessageLog, error)
+	FindLogs(ids []uint) ([]models.MessageLog, error)
+	MarkRead(userID uuid.UUID, key ThreadKey, now time.Time) (int64, error)
+}
+
+type conversationRepository struct {
+	db *gorm.DB
+}
+
+func NewConversationRepository(db *gorm.DB) ConversationRepository {
+	return &conversationRepository{db: db}
+}
+
+// threaded selects the SMS logs that belong to a thread. Outbound messages
+// still waiting for a device have no thread yet.
+func (r *conversationRepository) threaded(userID uuid.UUID) *gorm.DB {
+	return r.db.Model(&models.MessageLog{}).
+		Where("user_id = ? AND type = ? AND device_id IS NOT NULL AND remote_number <> ''", userID, models.MessageTypeSMS)
+}
+
+func (r *conversationRepository) summaries(query *gorm.DB) *gorm.DB {
+	return query.Select(
+		"device_id, sim_slot, remote_number, "+
+			"MAX(id) AS last_message_id, MAX(created_at) AS last_message_at, COUNT(*) AS message_count, "+
+			"COUNT(*) FILTER (WHERE direction = ? AND read_at IS NULL) AS unread_count",
+		models.DirectionInbound,
+	).Group("device_id, sim_slot, remote_number")
+}
+
+func (r *conversationRepository) FindThreads(userID uuid.UUID, filter *ThreadFilter, limit, offset int) ([]ThreadSummary, int64, error) {
+	query := r.threaded(userID)
+	if filter.DeviceID != nil {
+		query = query.Where("device_id = ?", *filter.DeviceID)
+	}
+	if filter.Search != "" {
+		query = query.Where("remote_number LIKE ?", "%"+filter.Search+"%")
+	}
+
+	grouped := r.summaries(query)
+	if filter.UnreadOnly {
+		grouped = grouped.Having("COUNT(*) FILTER (WHERE direction = ? AND read_at IS NULL) > 0", models.DirectionInbound)
+	}
+
+	var total int64
+	if err := r.db.Table("(?) AS threads", grouped).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var threads []ThreadSummary
+	err := r.db.Table("(?) AS threads", grouped).
+		Order("last_message_at DESC").
+		Limit(limit).Offset(offset).
+		Scan(&threads).Error
+	return threads, total, err
+}
+
+func (r *conversationRepository) FindThread(userID uuid.UUID, key ThreadKey) (*ThreadSummary, error) {
+	var threads []ThreadSummary
+	err := r.summaries(r.threadQuery(userID, key)).Scan(&threads).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(threads) == 0 {
+		return nil, ErrLogNotFound
+	}
+	return &threads[0], nil
+}
+
+// FindMessages returns a page of the thread newest first.
+func (r *conversationRepository) FindMessages(userID uuid.UUID, key ThreadKey, limit, offset int) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	err := r.threadQuery(userID, key).
+		Order("created_at DESC, id DESC").
+		Limit(limit).Offset(offset).
+		Find(&logs).Error
+	return logs, err
+}
+
+func (r *conversationRepository) FindLogs(ids []uint) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	if len(ids) == 0 {
+		return logs, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&logs).Error
+	return logs, err
+}
+
+func (r *conversationRepository) MarkRead(userID uuid.UUID, key ThreadKey, now time.Time) (int64, error) {
+	result := r.threadQuery(userID, key).
+		Where("direction = ? AND read_at IS NULL", models.DirectionInbound).
+		Update("read_at", now)
+	return result.RowsAffected, result.Error
+}
+
+func (r *conversationRepository) threadQuery(userID uuid.UUID, key ThreadKey) *gorm.DB {
+	return r.threaded(userID).
+		Where("device_id = ? AND sim_slot = ? AND remote_number = ?", key.DeviceID, key.SimSlot, key.RemoteNumber)
+}
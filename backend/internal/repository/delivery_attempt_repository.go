@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type DeliveryAttemptRepository interface {
+	Create(attempt *models.DeliveryAttempt) error
+	FindByLogID(logID uint) ([]models.DeliveryAttempt, error)
+	CompleteLatest(logID uint, status models.MessageStatus, errorMsg string) error
+}
+
+type deliveryAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryAttemptRepository(db *gorm.DB) DeliveryAttemptRepository {
+	return &deliveryAttemptRepository{db: db}
+}
+
+func (r *deliveryAttemptRepository) Create(attempt *models.DeliveryAttempt) error {
+	if attempt.CreatedAt.IsZero() {
+		attempt.CreatedAt = time.Now()
+	}
+	return r.db.Create(attempt).Error
+}
+
+func (r *deliveryAttemptRepository) FindByLogID(logID uint) ([]models.DeliveryAttempt, error) {
+	var attempts []models.DeliveryAttempt
+	err := r.db.Where("log_id = ?", logID).Order("attempt ASC").Find(&attempts).Error
+	return attempts, err
+}
+
+// CompleteLatest records the outcome of the most recent hop of a message.
+func (r *deliveryAttemptRepository) CompleteLatest(logID uint, status models.MessageStatus, errorMsg string) error {
+	var latest models.DeliveryAttempt
+	err := r.db.Where("log_id = ?", logID).Order("attempt DESC").First(&latest).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status": status,
+	}
+	if status != models.StatusSent {
+		updates["completed_at"] = now
+	}
+	if errorMsg != "" {
+		updates["error"] = errorMsg
+	}
+
+	return r.db.Model(&models.DeliveryAttempt{}).Where("id = ?", latest.ID).Updates(updates).Error
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCommandNotFound = errors.New("device command not found")
+)
+
+type DeviceCommandRepository interface {
+	Create(cmd *models.DeviceCommand) error
+	Delete(id uuid.UUID) error
+	FindByID(id uuid.UUID) (*models.DeviceCommand, error)
+	FindByDevice(deviceID uuid.UUID, status models.CommandStatus, limit, offset int) ([]models.DeviceCommand, int64, error)
+	// FindUnacked returns the device's pending commands, oldest first
+	FindUnacked(deviceID uuid.UUID) ([]models.DeviceCommand, error)
+	MarkResent(id uuid.UUID, now time.Time) error
+	Ack(deviceID, id uuid.UUID, now time.Time) (bool, error)
+	AckByRef(deviceID uuid.UUID, ref string, now time.Time) (int64, error)
+	Fail(id uuid.UUID, reason string) (bool, error)
+	// FailByRef fails every pending command for ref on any device
+	FailByRef(ref, reason string) (int64, error)
+	// Complete stores the result of a command that was not settled yet
+	Complete(deviceID, id uuid.UUID, status models.CommandStatus, errMsg string, result []byte, now time.Time) (bool, error)
+	FindExpired(now time.Time) ([]models.DeviceCommand, error)
+}
+
+type deviceCommandRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceCommandRepository(db *gorm.DB) DeviceCommandRepository {
+	return &deviceCommandRepository{db: db}
+}
+
+func (r *deviceCommandRepository) Create(cmd *models.DeviceCommand) error {
+	if cmd.Status == "" {
+		cmd.Status = models.CommandPending
+	}
+	return r.db.Create(cmd).Error
+}
+
+func (r *deviceCommandRepository) Delete(id uuid.UUID) error {
+	return r.db.Where("id = ?", id).Delete(&models.DeviceCommand{}).Error
+}
+
+func (r *deviceCommandRepository) FindByID(id uuid.UUID) (*models.DeviceCommand, error) {
+	var cmd models.DeviceCommand
+	err := r.db.Where("id = ?", id).First(&cmd).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCommandNotFound
+		}
+		return nil, err
+	}
+	return &cmd, nil
+}
+
+func (r *deviceCommandRepository) FindByDevice(deviceID uuid.UUID, status models.CommandStatus, limit, offset int) ([]models.DeviceCommand, int64, error) {
+	query := r.db.Model(&models.DeviceCommand{}).Where("device_id = ?", deviceID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var cmds []models.DeviceCommand
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&cmds).Error
+	return cmds, total, err
+}
+
+func (r *deviceCommandRepository) FindUnacked(deviceID uuid.UUID) ([]models.DeviceCommand, error) {
+	var cmds []models.DeviceCommand
+	err := r.db.Where("device_id = ? AND status = ?", deviceID, models.CommandPending).
+		Order("created_at ASC").
+		Find(&cmds).Error
+	return cmds, err
+}
+
+func (r *deviceCommandRepository) MarkResent(id uuid.UUID, now time.Time) error {
+	return r.db.Model(&models.DeviceCommand{}).
+		Where("id = ? AND status = ?", id, models.CommandPending).
+		Updates(map[string]interface{}{
+			"attempts": gorm.Expr("attempts + 1"),
+			"sent_at":  now,
+		}).Error
+}
+
+// Ack reports whether the command was still pending; duplicate ACKs for a
+// resent command are harmless.
+func (r *deviceCommandRepository) Ack(deviceID, id uuid.UUID, now time.Time) (bool, error) {
+	result := r.db.Model(&models.DeviceCommand{}).
+		Where("id = ? AND device_id = ? AND status = ?", id, deviceID, models.CommandPending).
+		Updates(map[string]interface{}{
+			"status":   models.CommandAcked,
+			"acked_at": now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *deviceCommandRepository) AckByRef(deviceID uuid.UUID, ref string, now time.Time) (int64, error) {
+	result := r.db.Model(&models.DeviceCommand{}).
+		Where("device_id = ? AND ref = ? AND status = ?", deviceID, ref, models.CommandPending).
+		Updates(map[string]interface{}{
+			"status":   models.CommandAcked,
+			"acked_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *deviceCommandRepository) Fail(id uuid.UUID, reason string) (bool, error) {
+	result := r.db.Model(&models.DeviceCommand{}).
+		Where("id = ? AND status = ?", id, models.CommandPending).
+		Updates(map[string]interface{}{
+			"status": models.CommandFailed,
+			"error":  reason,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *deviceCommandRepository) FailByRef(ref, reason string) (int64, error) {
+	result := r.db.Model(&models.DeviceCommand{}).
+		Where("ref = ? AND status = ?", ref, models.CommandPending).
+		Updates(map[string]interface{}{
+			"status": models.CommandFailed,
+			"error":  reason,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Complete accepts results for pending commands too: a device may answer
+// before its ACK arrives, or skip the ACK altogether.
+func (r *deviceCommandRepository) Complete(deviceID, id uuid.UUID, status models.CommandStatus, errMsg string, result []byte, now time.Time) (bool, error) {
+	res := r.db.Model(&models.DeviceCommand{}).
+		Where("id = ? AND device_id = ? AND status IN ?", id, deviceID, []models.CommandStatus{models.CommandPending, models.CommandAcked}).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"error":        errMsg,
+			"result":       result,
+			"completed_at": now,
+			"acked_at":     gorm.Expr("COALESCE(acked_at, ?)", now),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *deviceCommandRepository) FindExpired(now time.Time) ([]models.DeviceCommand, error) {
+	var cmds []models.DeviceCommand
+	err := r.db.Where("status = ? AND expires_at <= ?", models.CommandPending, now).
+		Find(&cmds).Error
+	return cmds, err
+}
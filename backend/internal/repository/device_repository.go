@@ -18,12 +18,18 @@ type DeviceRepository interface {
 	Create(device *models.Device) error
 	FindByID(id uuid.UUID) (*models.Device, error)
 	FindByDeviceUID(uid string) (*models.Device, error)
+	FindByTokenHash(hash string) (*models.Device, error)
 	FindByUserID(userID uuid.UUID) ([]models.Device, error)
+	FindByIDs(ids []uuid.UUID) ([]models.Device, error)
 	Update(device *models.Device) error
 	Delete(id uuid.UUID) error
 	UpdateStatus(id uuid.UUID, status string) error
 	UpdateLastSeen(id uuid.UUID, battery int) error
 	UpdateFCMToken(id uuid.UUID, token string) error
+	UpdateSimCount(id uuid.UUID, count int) error
+	UpdateProtocol(id uuid.UUID, appVersion string, protocolVersion int) error
+	SetTokenHash(id uuid.UUID, hash string, issuedAt time.Time) error
+	RevokeToken(id uuid.UUID, revokedAt time.Time) error
 }
 
 type deviceRepository struct {
@@ -79,6 +85,12 @@ func (r *deviceRepository) FindByUserID(userID uuid.UUID) ([]models.Device, erro
 	return devices, err
 }
 
+func (r *deviceRepository) FindByIDs(ids []uuid.UUID) ([]models.Device, error) {
+	var devices []models.Device
+	err := r.db.Where("id IN ?", ids).Find(&devices).Error
+	return devices, err
+}
+
 func (r *deviceRepository) Update(device *models.Device) error {
 	result := r.db.Save(device)
 	if result.Error != nil {
@@ -141,6 +153,77 @@ func (r *deviceRepository) UpdateFCMToken(id uuid.UUID, token string) error {
 	return nil
 }
 
+func (r *deviceRepository) UpdateSimCount(id uuid.UUID, count int) error {
+	result := r.db.Model(&models.Device{}).Where("id = ?", id).Update("sim_count", count)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *deviceRepository) FindByTokenHash(hash string) (*models.Device, error) {
+	var device models.Device
+	err := r.db.Where("token_hash = ?", hash).First(&device).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	return &device, nil
+}
+
+// SetTokenHash replaces the device credential and lifts any revocation.
+func (r *deviceRepository) SetTokenHash(id uuid.UUID, hash string, issuedAt time.Time) error {
+	result := r.db.Model(&models.Device{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"token_hash":      hash,
+		"token_issued_at": issuedAt,
+		"revoked_at":      nil,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *deviceRepository) RevokeToken(id uuid.UUID, revokedAt time.Time) error {
+	result := r.db.Model(&models.Device{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"token_hash": "",
+		"revoked_at": revokedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *deviceRepository) UpdateProtocol(id uuid.UUID, appVersion string, protocolVersion int) error {
+	updates := map[string]interface{}{
+		"protocol_version": protocolVersion,
+	}
+	if appVersion != "" {
+		updates["app_version"] = appVersion
+	}
+
+	result := r.db.Model(&models.Device{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
 func isDuplicateKeyError(err error) bool {
 	if err == nil {
 		return false
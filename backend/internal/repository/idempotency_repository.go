@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIdempotencyKeyNotFound  = errors.New("idempotency key not found")
+	ErrDuplicateIdempotencyKey = errors.New("idempotency key already exists")
+)
+
+type IdempotencyRepository interface {
+	Create(record *models.IdempotencyKey) error
+	Find(userID uuid.UUID, key string) (*models.IdempotencyKey, error)
+	// TakeOver renews the lease of an in-progress record whose lease ran
+	// out, reporting false if another request completed or renewed it first
+	TakeOver(id uint, now, lockedUntil time.Time) (bool, error)
+	// Renew, Complete and Release only act while the record is in progress
+	// under the lease the caller holds, reporting false once another request
+	// took the key over
+	Renew(id uint, held, lockedUntil time.Time) (bool, error)
+	Complete(id uint, held time.Time, statusCode int, body []byte) (bool, error)
+	Release(id uint, held time.Time) (bool, error)
+	Delete(id uint) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Create(record *models.IdempotencyKey) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	err := r.db.Create(record).Error
+	if err != nil && isDuplicateKeyError(err) {
+		return ErrDuplicateIdempotencyKey
+	}
+	return err
+}
+
+func (r *idempotencyRepository) Find(userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) TakeOver(id uint, now, lockedUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status_code = 0 AND (locked_until IS NULL OR locked_until <= ?)", id, now).
+		Update("locked_until", lockedUntil)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *idempotencyRepository) Renew(id uint, held, lockedUntil time.Time) (bool, error) {
+	result := r.db.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status_code = 0 AND locked_until = ?", id, held).
+		Update("locked_until", lockedUntil)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *idempotencyRepository) Complete(id uint, held time.Time, statusCode int, body []byte) (bool, error) {
+	result := r.db.Model(&models.IdempotencyKey{}).
+		Where("id = ? AND status_code = 0 AND locked_until = ?", id, held).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *idempotencyRepository) Release(id uint, held time.Time) (bool, error) {
+	result := r.db.Where("id = ? AND status_code = 0 AND locked_until = ?", id, held).
+		Delete(&models.IdempotencyKey{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *idempotencyRepository) Delete(id uint) error {
+	return r.db.Delete(&models.IdempotencyKey{}, "id = ?", id).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -11,15 +11,38 @@ import (
 )
 
 var (
-	ErrLogNotFound = errors.New("message log not found")
+	ErrLogNotFound        = errors.New("message log not found")
+	ErrDuplicateEvent     = errors.New("device event already recorded")
+	ErrDuplicateRequestID = errors.New("request id already in use")
 )
 
 type LogRepository interface {
 	Create(log *models.MessageLog) error
 	FindByID(id uint) (*models.MessageLog, error)
+	FindByRequestID(requestID string) (*models.MessageLog, error)
+	// FindOutboundByRequestID only finds outbound messages of the user, so a
+	// request ID guessed from another account reads as not found
+	FindOutboundByRequestID(userID uuid.UUID, requestID string) (*models.MessageLog, error)
+	// FindOutboundOnDevice only finds a message while it is assigned to the
+	// device, so a device can only report on its own hop
+	FindOutboundOnDevice(userID, deviceID uuid.UUID, requestID string) (*models.MessageLog, error)
+	FindByEventID(deviceID uuid.UUID, eventID string) (*models.MessageLog, error)
 	FindByUserID(userID uuid.UUID, params *dto.LogQueryParams) ([]models.MessageLog, int64, error)
 	UpdateStatus(id uint, status models.MessageStatus, errorMsg string) error
+	// UpdateStatusAt is UpdateStatus with the time the status was reached,
+	// e.g. the delivery time a device reported
+	UpdateStatusAt(id uint, status models.MessageStatus, errorMsg string, at time.Time) error
 	IncrementRetry(id uint) error
+	FindQueued(userID, deviceID uuid.UUID, now time.Time) ([]models.MessageLog, error)
+	ClaimQueued(id uint, deviceID uuid.UUID) (bool, error)
+	Requeue(id uint, deviceID *uuid.UUID) error
+	Reassign(id uint, deviceID uuid.UUID, simSlot int) error
+	ExpireQueued(now time.Time) ([]models.MessageLog, error)
+	CountInFlight(deviceIDs []uuid.UUID) (map[uuid.UUID]int64, error)
+	CountByCampaign(campaignID uuid.UUID) (map[models.MessageStatus]int64, error)
+	FindByCampaign(campaignID uuid.UUID) ([]models.MessageLog, error)
+	CancelQueuedByCampaign(campaignID uuid.UUID, reason string) ([]models.MessageLog, error)
+	FindLastOutboundTo(userID uuid.UUID, receiver string) (*models.MessageLog, error)
 	GetStats(userID uuid.UUID, from, to time.Time) (*dto.LogStats, error)
 }
 
@@ -35,7 +58,26 @@ func (r *logRepository) Create(log *models.MessageLog) error {
 	if log.CreatedAt.IsZero() {
 		log.CreatedAt = time.Now()
 	}
-	return r.db.Create(log).Error
+	if log.Type == "" {
+		log.Type = models.MessageTypeSMS
+	}
+	if log.RemoteNumber == "" && log.Type == models.MessageTypeSMS {
+		log.RemoteNumber = log.Receiver
+		if log.Direction == models.DirectionInbound {
+			log.RemoteNumber = log.Sender
+		}
+	}
+
+	err := r.db.Create(log).Error
+	if err != nil && isDuplicateKeyError(err) {
+		switch {
+		case log.EventID != nil:
+			return ErrDuplicateEvent
+		case log.RequestID != "":
+			return ErrDuplicateRequestID
+		}
+	}
+	return err
 }
 
 func (r *logRepository) FindByID(id uint) (*models.MessageLog, error) {
@@ -50,12 +92,67 @@ func (r *logRepository) FindByID(id uint) (*models.MessageLog, error) {
 	return &log, nil
 }
 
+func (r *logRepository) FindByRequestID(requestID string) (*models.MessageLog, error) {
+	var log models.MessageLog
+	err := r.db.Where("request_id = ?", requestID).First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *logRepository) FindOutboundByRequestID(userID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	var log models.MessageLog
+	err := r.db.Where("user_id = ? AND request_id = ? AND direction = ?", userID, requestID, models.DirectionOutbound).
+		First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *logRepository) FindOutboundOnDevice(userID, deviceID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	var log models.MessageLog
+	err := r.db.Where("user_id = ? AND device_id = ? AND request_id = ? AND direction = ?",
+		userID, deviceID, requestID, models.DirectionOutbound).
+		First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
+func (r *logRepository) FindByEventID(deviceID uuid.UUID, eventID string) (*models.MessageLog, error) {
+	var log models.MessageLog
+	err := r.db.Where("device_id = ? AND event_id = ?", deviceID, eventID).First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
 func (r *logRepository) FindByUserID(userID uuid.UUID, params *dto.LogQueryParams) ([]models.MessageLog, int64, error) {
 	var logs []models.MessageLog
 	var total int64
 
 	query := r.db.Model(&models.MessageLog{}).Where("user_id = ?", userID)
 
+	if params.Type != "" {
+		query = query.Where("type = ?", params.Type)
+	}
+
 	if params.Direction != "" {
 		query = query.Where("direction = ?", params.Direction)
 	}
@@ -71,6 +168,10 @@ func (r *logRepository) FindByUserID(userID uuid.UUID, params *dto.LogQueryParam
 		}
 	}
 
+	if params.AppPackage != "" {
+		query = query.Where("app_package = ?", params.AppPackage)
+	}
+
 	if !params.From.IsZero() {
 		query = query.Where("created_at >= ?", params.From)
 	}
@@ -93,16 +194,28 @@ func (r *logRepository) FindByUserID(userID uuid.UUID, params *dto.LogQueryParam
 }
 
 func (r *logRepository) UpdateStatus(id uint, status models.MessageStatus, errorMsg string) error {
-	now := time.Now()
+	return r.UpdateStatusAt(id, status, errorMsg, time.Now())
+}
+
+func (r *logRepository) UpdateStatusAt(id uint, status models.MessageStatus, errorMsg string, at time.Time) error {
 	updates := map[string]interface{}{
 		"status":       status,
-		"processed_at": now,
+		"processed_at": time.Now(),
 	}
 
 	if errorMsg != "" {
 		updates["error_message"] = errorMsg
 	}
 
+	switch status {
+	case models.StatusSent:
+		updates["sent_at"] = at
+	case models.StatusDelivered:
+		updates["delivered_at"] = at
+	case models.StatusFailed, models.StatusExpired:
+		updates["failed_at"] = at
+	}
+
 	result := r.db.Model(&models.MessageLog{}).Where("id = ?", id).Updates(updates)
 	if result.Error != nil {
 		return result.Error
@@ -125,6 +238,212 @@ func (r *logRepository) IncrementRetry(id uint) error {
 	return nil
 }
 
+// FindQueued returns unexpired outbound messages waiting for a device, either
+// pinned to deviceID or free to go out through any of the user's devices.
+func (r *logRepository) FindQueued(userID, deviceID uuid.UUID, now time.Time) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	err := r.db.Where("user_id = ? AND direction = ? AND status = ?", userID, models.DirectionOutbound, models.StatusQueued).
+		Where("device_id = ? OR device_id IS NULL", deviceID).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("created_at ASC").
+		Find(&logs).Error
+	return logs, err
+}
+
+// ClaimQueued moves a queued message to pending on the given device. It
+// reports false when another device already claimed it or it expired.
+func (r *logRepository) ClaimQueued(id uint, deviceID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.MessageLog{}).
+		Where("id = ? AND status = ?", id, models.StatusQueued).
+		Updates(map[string]interface{}{
+			"status":        models.StatusPending,
+			"device_id":     deviceID,
+			"dispatched_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Requeue returns a claimed message to the queue, restoring the device it was
+// pinned to (nil if any device may send it).
+func (r *logRepository) Requeue(id uint, deviceID *uuid.UUID) error {
+	result := r.db.Model(&models.MessageLog{}).
+		Where("id = ? AND status = ?", id, models.StatusPending).
+		Updates(map[string]interface{}{
+			"status":        models.StatusQueued,
+			"device_id":     deviceID,
+			"dispatched_at": nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLogNotFound
+	}
+	return nil
+}
+
+// Reassign hands an in-flight message over to another device or SIM after a
+// failed attempt, counting the hop in retry_count.
+func (r *logRepository) Reassign(id uint, deviceID uuid.UUID, simSlot int) error {
+	result := r.db.Model(&models.MessageLog{}).
+		Where("id = ? AND status = ?", id, models.StatusPending).
+		Updates(map[string]interface{}{
+			"device_id":     deviceID,
+			"sim_slot":      simSlot,
+			"retry_count":   gorm.Expr("retry_count + 1"),
+			"dispatched_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLogNotFound
+	}
+	return nil
+}
+
+// ExpireQueued marks every queued message whose TTL has passed as expired and
+// returns the affected rows.
+func (r *logRepository) ExpireQueued(now time.Time) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	err := r.db.Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", models.StatusQueued, now).
+		Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+
+	expired := make([]models.MessageLog, 0, len(logs))
+	for _, log := range logs {
+		result := r.db.Model(&models.MessageLog{}).
+			Where("id = ? AND status = ?", log.ID, models.StatusQueued).
+			Updates(map[string]interface{}{
+				"status":        models.StatusExpired,
+				"error_message": "expired before a device became available",
+				"processed_at":  now,
+				"failed_at":     now,
+			})
+		if result.Error != nil {
+			return expired, result.Error
+		}
+		if result.RowsAffected == 1 {
+			log.Status = models.StatusExpired
+			log.ErrorMessage = "expired before a device became available"
+			log.ProcessedAt = &now
+			log.FailedAt = &now
+			expired = append(expired, log)
+		}
+	}
+
+	return expired, nil
+}
+
+// CountInFlight returns how many outbound messages each device has been
+// handed but not yet reported on.
+func (r *logRepository) CountInFlight(deviceIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		DeviceID uuid.UUID
+		Count    int64
+	}
+
+	err := r.db.Model(&models.MessageLog{}).
+		Select("device_id, COUNT(*) AS count").
+		Where("device_id IN ? AND direction = ? AND status = ?", deviceIDs, models.DirectionOutbound, models.StatusPending).
+		Group("device_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.DeviceID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *logRepository) CountByCampaign(campaignID uuid.UUID) (map[models.MessageStatus]int64, error) {
+	var rows []struct {
+		Status models.MessageStatus
+		Count  int64
+	}
+
+	err := r.db.Model(&models.MessageLog{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.MessageStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *logRepository) FindByCampaign(campaignID uuid.UUID) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	err := r.db.Where("campaign_id = ?", campaignID).Order("id ASC").Find(&logs).Error
+	return logs, err
+}
+
+// CancelQueuedByCampaign fails the campaign's messages that no device has
+// picked up yet. Messages already handed to a device are left to finish.
+func (r *logRepository) CancelQueuedByCampaign(campaignID uuid.UUID, reason string) ([]models.MessageLog, error) {
+	var logs []models.MessageLog
+	err := r.db.Where("campaign_id = ? AND status = ?", campaignID, models.StatusQueued).Find(&logs).Error
+	if err != nil || len(logs) == 0 {
+		return nil, err
+	}
+
+	now := time.Now()
+	cancelled := make([]models.MessageLog, 0, len(logs))
+	for _, log := range logs {
+		result := r.db.Model(&models.MessageLog{}).
+			Where("id = ? AND status = ?", log.ID, models.StatusQueued).
+			Updates(map[string]interface{}{
+				"status":        models.StatusFailed,
+				"error_message": reason,
+				"processed_at":  now,
+				"failed_at":     now,
+			})
+		if result.Error != nil {
+			return cancelled, result.Error
+		}
+		if result.RowsAffected == 1 {
+			log.Status = models.StatusFailed
+			log.ErrorMessage = reason
+			log.ProcessedAt = &now
+			log.FailedAt = &now
+			cancelled = append(cancelled, log)
+		}
+	}
+
+	return cancelled, nil
+}
+
+// FindLastOutboundTo returns the most recent outbound message that a device
+// actually picked up for the receiver.
+func (r *logRepository) FindLastOutboundTo(userID uuid.UUID, receiver string) (*models.MessageLog, error) {
+	var log models.MessageLog
+	err := r.db.Where("user_id = ? AND receiver = ? AND direction = ? AND device_id IS NOT NULL", userID, receiver, models.DirectionOutbound).
+		Where("status IN ?", []models.MessageStatus{models.StatusPending, models.StatusSent, models.StatusDelivered}).
+		Order("created_at DESC").
+		First(&log).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return &log, nil
+}
+
 func (r *logRepository) GetStats(userID uuid.UUID, from, to time.Time) (*dto.LogStats, error) {
 	stats := &dto.LogStats{}
 
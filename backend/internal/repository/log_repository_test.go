@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/database"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var errRollback = errors.New("rollback")
+
+// withTestDB runs fn in a transaction on the Postgres database named by
+// TEST_DATABASE_URL and rolls it back afterwards. The tests are skipped when
+// it is not set.
+func withTestDB(t *testing.T, fn func(tx *gorm.DB)) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := database.AutoMigrate(tx); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		fn(tx)
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("transaction: %v", err)
+	}
+}
+
+func createTestUser(t *testing.T, tx *gorm.DB) uuid.UUID {
+	t.Helper()
+	user := &models.User{
+		ID:           uuid.New(),
+		Email:        uuid.NewString() + "@example.com",
+		PasswordHash: "x",
+		APIKey:       uuid.NewString(),
+	}
+	if err := tx.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user.ID
+}
+
+func outboundLog(userID uuid.UUID, requestID string) *models.MessageLog {
+	return &models.MessageLog{
+		UserID:    userID,
+		RequestID: requestID,
+		Direction: models.DirectionOutbound,
+		Receiver:  "+84912345678",
+		Content:   "hello",
+		Status:    models.StatusQueued,
+	}
+}
+
+func TestLogRepositoryRejectsDuplicateRequestID(t *testing.T) {
+	withTestDB(t, func(tx *gorm.DB) {
+		userID := createTestUser(t, tx)
+		requestID := uuid.NewString()
+
+		if err := NewLogRepository(tx).Create(outboundLog(userID, requestID)); err != nil {
+			t.Fatalf("first Create: %v", err)
+		}
+
+		// a savepoint keeps the failed insert from aborting the transaction
+		err := tx.Transaction(func(inner *gorm.DB) error {
+			return NewLogRepository(inner).Create(outboundLog(createTestUser(t, inner), requestID))
+		})
+		if !errors.Is(err, ErrDuplicateRequestID) {
+			t.Fatalf("duplicate Create error = %v, want %v", err, ErrDuplicateRequestID)
+		}
+
+		// the index is partial: messages without a request ID never collide
+		repo := NewLogRepository(tx)
+		for i := 0; i < 2; i++ {
+			if err := repo.Create(outboundLog(userID, "")); err != nil {
+				t.Fatalf("Create without request id: %v", err)
+			}
+		}
+	})
+}
+
+func TestLogRepositoryFindOutboundByRequestID(t *testing.T) {
+	withTestDB(t, func(tx *gorm.DB) {
+		repo := NewLogRepository(tx)
+		owner, other := createTestUser(t, tx), createTestUser(t, tx)
+
+		outbound := outboundLog(owner, uuid.NewString())
+		if err := repo.Create(outbound); err != nil {
+			t.Fatal(err)
+		}
+		inbound := outboundLog(owner, uuid.NewString())
+		inbound.Direction = models.DirectionInbound
+		if err := repo.Create(inbound); err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := repo.FindOutboundByRequestID(owner, outbound.RequestID)
+		if err != nil || found.ID != outbound.ID {
+			t.Fatalf("owner lookup = %v, %v", found, err)
+		}
+		if _, err := repo.FindOutboundByRequestID(other, outbound.RequestID); !errors.Is(err, ErrLogNotFound) {
+			t.Errorf("other user lookup error = %v, want %v", err, ErrLogNotFound)
+		}
+		if _, err := repo.FindOutboundByRequestID(owner, inbound.RequestID); !errors.Is(err, ErrLogNotFound) {
+			t.Errorf("inbound lookup error = %v, want %v", err, ErrLogNotFound)
+		}
+	})
+}
+
+func createTestDevice(t *testing.T, tx *gorm.DB, userID uuid.UUID) uuid.UUID {
+	t.Helper()
+	device := &models.Device{ID: uuid.New(), UserID: userID, Name: "test", DeviceUID: uuid.NewString()}
+	if err := tx.Create(device).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	return device.ID
+}
+
+func TestLogRepositoryFindOutboundOnDevice(t *testing.T) {
+	withTestDB(t, func(tx *gorm.DB) {
+		repo := NewLogRepository(tx)
+		owner, other := createTestUser(t, tx), createTestUser(t, tx)
+		current, previous := createTestDevice(t, tx, owner), createTestDevice(t, tx, owner)
+
+		msgLog := outboundLog(owner, uuid.NewString())
+		msgLog.DeviceID = &current
+		if err := repo.Create(msgLog); err != nil {
+			t.Fatal(err)
+		}
+
+		found, err := repo.FindOutboundOnDevice(owner, current, msgLog.RequestID)
+		if err != nil || found.ID != msgLog.ID {
+			t.Fatalf("current device lookup = %v, %v", found, err)
+		}
+		if _, err := repo.FindOutboundOnDevice(owner, previous, msgLog.RequestID); !errors.Is(err, ErrLogNotFound) {
+			t.Errorf("other device lookup error = %v, want %v", err, ErrLogNotFound)
+		}
+		if _, err := repo.FindOutboundOnDevice(other, current, msgLog.RequestID); !errors.Is(err, ErrLogNotFound) {
+			t.Errorf("other user lookup error = %v, want %v", err, ErrLogNotFound)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPairingTokenNotFound = errors.New("pairing token not found")
+)
+
+type PairingTokenRepository interface {
+	Create(token *models.PairingToken) error
+	FindByHash(hash string) (*models.PairingToken, error)
+	// Claim marks a pending, unexpired token paired. It reports false when
+	// the token was already used or has expired, so only one redemption wins.
+	Claim(id uuid.UUID, now time.Time) (bool, error)
+	// Release returns a claimed token to pending when pairing failed
+	Release(id uuid.UUID) error
+	SetDevice(id, deviceID uuid.UUID) error
+	DeleteExpired(before time.Time) (int64, error)
+}
+
+type pairingTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPairingTokenRepository(db *gorm.DB) PairingTokenRepository {
+	return &pairingTokenRepository{db: db}
+}
+
+func (r *pairingTokenRepository) Create(token *models.PairingToken) error {
+	if token.ID == uuid.Nil {
+		token.ID = uuid.New()
+	}
+	if token.Status == "" {
+		token.Status = models.PairingPending
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+	return r.db.Create(token).Error
+}
+
+func (r *pairingTokenRepository) FindByHash(hash string) (*models.PairingToken, error) {
+	var token models.PairingToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPairingTokenNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *pairingTokenRepository) Claim(id uuid.UUID, now time.Time) (bool, error) {
+	result := r.db.Model(&models.PairingToken{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, models.PairingPending, now).
+		Updates(map[string]interface{}{
+			"status":    models.PairingPaired,
+			"paired_at": now,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *pairingTokenRepository) Release(id uuid.UUID) error {
+	return r.db.Model(&models.PairingToken{}).
+		Where("id = ? AND device_id IS NULL", id).
+		Updates(map[string]interface{}{
+			"status":    models.PairingPending,
+			"paired_at": nil,
+		}).Error
+}
+
+func (r *pairingTokenRepository) SetDevice(id, deviceID uuid.UUID) error {
+	result := r.db.Model(&models.PairingToken{}).Where("id = ?", id).Update("device_id", deviceID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPairingTokenNotFound
+	}
+	return nil
+}
+
+func (r *pairingTokenRepository) DeleteExpired(before time.Time) (int64, error) {
+	result := r.db.Where("expires_at <= ?", before).Delete(&models.PairingToken{})
+	return result.RowsAffected, result.Error
+}
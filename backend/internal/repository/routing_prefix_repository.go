@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoutingPrefixNotFound = errors.New("routing prefix not found")
+)
+
+type RoutingPrefixRepository interface {
+	Create(prefix *models.RoutingPrefix) error
+	FindByID(id uint) (*models.RoutingPrefix, error)
+	FindByUserID(userID uuid.UUID) ([]models.RoutingPrefix, error)
+	Delete(id uint) error
+}
+
+type routingPrefixRepository struct {
+	db *gorm.DB
+}
+
+func NewRoutingPrefixRepository(db *gorm.DB) RoutingPrefixRepository {
+	return &routingPrefixRepository{db: db}
+}
+
+func (r *routingPrefixRepository) Create(prefix *models.RoutingPrefix) error {
+	if prefix.CreatedAt.IsZero() {
+		prefix.CreatedAt = time.Now()
+	}
+	return r.db.Create(prefix).Error
+}
+
+func (r *routingPrefixRepository) FindByID(id uint) (*models.RoutingPrefix, error) {
+	var prefix models.RoutingPrefix
+	err := r.db.Where("id = ?", id).First(&prefix).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoutingPrefixNotFound
+		}
+		return nil, err
+	}
+	return &prefix, nil
+}
+
+// FindByUserID returns the user's prefixes longest first, so the first match
+// is the most specific one.
+func (r *routingPrefixRepository) FindByUserID(userID uuid.UUID) ([]models.RoutingPrefix, error) {
+	var prefixes []models.RoutingPrefix
+	err := r.db.Where("user_id = ?", userID).Order("LENGTH(prefix) DESC, id ASC").Find(&prefixes).Error
+	return prefixes, err
+}
+
+func (r *routingPrefixRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.RoutingPrefix{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoutingPrefixNotFound
+	}
+	return nil
+}
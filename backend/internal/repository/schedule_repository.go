@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("scheduled message not found")
+)
+
+type ScheduleRepository interface {
+	Create(schedule *models.ScheduledMessage) error
+	FindByID(id uuid.UUID) (*models.ScheduledMessage, error)
+	FindByUserID(userID uuid.UUID, status string, limit, offset int) ([]models.ScheduledMessage, int64, error)
+	Update(schedule *models.ScheduledMessage) error
+	Cancel(id uuid.UUID) (bool, error)
+	Advance(id uuid.UUID, runAt time.Time, next *time.Time) (bool, error)
+	RecordRun(id uuid.UUID, requestID, errorMsg string) error
+}
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepository{db: db}
+}
+
+func (r *scheduleRepository) Create(schedule *models.ScheduledMessage) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *scheduleRepository) FindByID(id uuid.UUID) (*models.ScheduledMessage, error) {
+	var schedule models.ScheduledMessage
+	err := r.db.Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) FindByUserID(userID uuid.UUID, status string, limit, offset int) ([]models.ScheduledMessage, int64, error) {
+	var schedules []models.ScheduledMessage
+	var total int64
+
+	query := r.db.Model(&models.ScheduledMessage{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("next_run_at ASC NULLS LAST, created_at DESC").Limit(limit).Offset(offset).Find(&schedules).Error
+	return schedules, total, err
+}
+
+// Update saves an edit of a schedule that is still active.
+func (r *scheduleRepository) Update(schedule *models.ScheduledMessage) error {
+	result := r.db.Model(schedule).
+		Where("status = ?", models.ScheduleActive).
+		Select("phone", "content", "timezone", "recurrence", "next_run_at", "anchor_at", "updated_at").
+		Updates(schedule)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *scheduleRepository) Cancel(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, models.ScheduleActive).
+		Updates(map[string]interface{}{
+			"status":      models.ScheduleCancelled,
+			"next_run_at": nil,
+			"updated_at":  time.Now(),
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+// Advance claims the run due at runAt and moves the schedule to next, or
+// completes it when next is nil. It reports false when the run is stale
+// because the schedule was edited, cancelled or already advanced.
+func (r *scheduleRepository) Advance(id uuid.UUID, runAt time.Time, next *time.Time) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"next_run_at": next,
+		"occurrences": gorm.Expr("occurrences + 1"),
+		"last_run_at": now,
+		"updated_at":  now,
+	}
+	if next == nil {
+		updates["status"] = models.ScheduleCompleted
+	}
+
+	result := r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", id, models.ScheduleActive, runAt).
+		Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *scheduleRepository) RecordRun(id uuid.UUID, requestID, errorMsg string) error {
+	return r.db.Model(&models.ScheduledMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"last_request_id": requestID,
+			"last_error":      errorMsg,
+		}).Error
+}
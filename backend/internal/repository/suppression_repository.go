@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSuppressionNotFound = errors.New("suppressed number not found")
+)
+
+const suppressionBatchSize = 500
+
+type SuppressionRepository interface {
+	// Add stores the numbers, skipping ones already suppressed, and returns
+	// how many were new
+	Add(entries []models.SuppressedNumber) (int64, error)
+	Remove(userID uuid.UUID, phone string) error
+	IsSuppressed(userID uuid.UUID, phone string) (bool, error)
+	FindByUserID(userID uuid.UUID, search string, limit, offset int) ([]models.SuppressedNumber, int64, error)
+}
+
+type suppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) SuppressionRepository {
+	return &suppressionRepository{db: db}
+}
+
+func (r *suppressionRepository) Add(entries []models.SuppressedNumber) (int64, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	for i := range entries {
+		if entries[i].CreatedAt.IsZero() {
+			entries[i].CreatedAt = now
+		}
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(entries, suppressionBatchSize)
+	return result.RowsAffected, result.Error
+}
+
+func (r *suppressionRepository) Remove(userID uuid.UUID, phone string) error {
+	result := r.db.Delete(&models.SuppressedNumber{}, "user_id = ? AND phone = ?", userID, phone)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+func (r *suppressionRepository) IsSuppressed(userID uuid.UUID, phone string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.SuppressedNumber{}).
+		Where("user_id = ? AND phone = ?", userID, phone).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *suppressionRepository) FindByUserID(userID uuid.UUID, search string, limit, offset int) ([]models.SuppressedNumber, int64, error) {
+	var entries []models.SuppressedNumber
+	var total int64
+
+	query := r.db.Model(&models.SuppressedNumber{}).Where("user_id = ?", userID)
+	if search != "" {
+		query = query.Where("phone LIKE ?", "%"+search+"%")
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, total, err
+}
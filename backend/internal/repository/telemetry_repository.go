@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type TelemetryRepository interface {
+	Create(point *models.DeviceTelemetry) error
+	// Series aggregates a device's rows in [from, to) into buckets of the
+	// given Postgres date_trunc unit: minute, hour or day
+	Series(deviceID uuid.UUID, from, to time.Time, bucket string) ([]models.TelemetryPoint, error)
+	// OnlineMinutes counts, per device, the minutes in [from, to) with at
+	// least one heartbeat
+	OnlineMinutes(deviceIDs []uuid.UUID, from, to time.Time) (map[uuid.UUID]int64, error)
+	// Latest returns the newest row of each device that has one
+	Latest(deviceIDs []uuid.UUID) (map[uuid.UUID]*models.DeviceTelemetry, error)
+	// Downsample folds raw rows recorded before the given time into hourly
+	// rows and deletes them. before must fall on an hour so no hour ends up
+	// split across resolutions.
+	Downsample(before time.Time) (int64, error)
+	DeleteBefore(resolution models.TelemetryResolution, before time.Time) (int64, error)
+}
+
+type telemetryRepository struct {
+	db *gorm.DB
+}
+
+func NewTelemetryRepository(db *gorm.DB) TelemetryRepository {
+	return &telemetryRepository{db: db}
+}
+
+func (r *telemetryRepository) Create(point *models.DeviceTelemetry) error {
+	if point.RecordedAt.IsZero() {
+		point.RecordedAt = time.Now()
+	}
+	if point.Resolution == "" {
+		point.Resolution = models.TelemetryRaw
+	}
+	if point.Samples == 0 {
+		point.Samples = 1
+	}
+	if point.Resolution == models.TelemetryRaw {
+		point.MinBattery = point.Battery
+	}
+	return r.db.Create(point).Error
+}
+
+func (r *telemetryRepository) Series(deviceID uuid.UUID, from, to time.Time, bucket string) ([]models.TelemetryPoint, error) {
+	var points []models.TelemetryPoint
+
+	// Hourly rows stand for many heartbeats, so averages are weighted by
+	// samples to give raw and hourly rows their due share of a bucket
+	err := r.db.Model(&models.DeviceTelemetry{}).
+		Select(`date_trunc(?, recorded_at) AS bucket,
+			SUM(battery * samples)::float / SUM(samples) AS battery,
+			MIN(min_battery) AS min_battery,
+			bool_or(charging) AS charging,
+			SUM(signal * samples)::float / SUM(samples) AS signal,
+			(array_agg(network_type ORDER BY recorded_at DESC))[1] AS network_type,
+			MAX(queue_depth) AS queue_depth,
+			(array_agg(app_version ORDER BY recorded_at DESC))[1] AS app_version,
+			SUM(samples) AS samples`, bucket).
+		Where("device_id = ? AND recorded_at >= ? AND recorded_at < ?", deviceID, from, to).
+		Group("bucket").
+		Order("bucket ASC").
+		Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+func (r *telemetryRepository) OnlineMinutes(deviceIDs []uuid.UUID, from, to time.Time) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		DeviceID uuid.UUID
+		Minutes  int64
+	}
+
+	err := r.db.Model(&models.DeviceTelemetry{}).
+		Select(`device_id,
+			COUNT(DISTINCT CASE WHEN resolution = ? THEN date_trunc('minute', recorded_at) END) +
+			COALESCE(SUM(CASE WHEN resolution = ? THEN online_minutes END), 0) AS minutes`,
+			models.TelemetryRaw, models.TelemetryHourly).
+		Where("device_id IN ? AND recorded_at >= ? AND recorded_at < ?", deviceIDs, from, to).
+		Group("device_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	minutes := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		minutes[row.DeviceID] = row.Minutes
+	}
+	return minutes, nil
+}
+
+func (r *telemetryRepository) Latest(deviceIDs []uuid.UUID) (map[uuid.UUID]*models.DeviceTelemetry, error) {
+	var rows []models.DeviceTelemetry
+	err := r.db.Select("DISTINCT ON (device_id) *").
+		Where("device_id IN ?", deviceIDs).
+		Order("device_id, recorded_at DESC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[uuid.UUID]*models.DeviceTelemetry, len(rows))
+	for i := range rows {
+		latest[rows[i].DeviceID] = &rows[i]
+	}
+	return latest, nil
+}
+
+func (r *telemetryRepository) Downsample(before time.Time) (int64, error) {
+	var compacted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO device_telemetry
+				(device_id, resolution, recorded_at, battery, min_battery, charging, signal,
+				 network_type, queue_depth, app_version, samples, online_minutes)
+			SELECT device_id, ?, date_trunc('hour', recorded_at),
+				ROUND(AVG(battery)), MIN(battery), bool_or(charging), ROUND(AVG(signal)),
+				(array_agg(network_type ORDER BY recorded_at DESC))[1], MAX(queue_depth),
+				(array_agg(app_version ORDER BY recorded_at DESC))[1], COUNT(*),
+				COUNT(DISTINCT date_trunc('minute', recorded_at))
+			FROM device_telemetry
+			WHERE resolution = ? AND recorded_at < ?
+			GROUP BY device_id, date_trunc('hour', recorded_at)`,
+			models.TelemetryHourly, models.TelemetryRaw, before).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("resolution = ? AND recorded_at < ?", models.TelemetryRaw, before).
+			Delete(&models.DeviceTelemetry{})
+		compacted = result.RowsAffected
+		return result.Error
+	})
+	return compacted, err
+}
+
+func (r *telemetryRepository) DeleteBefore(resolution models.TelemetryResolution, before time.Time) (int64, error) {
+	result := r.db.Where("resolution = ? AND recorded_at < ?", resolution, before).Delete(&models.DeviceTelemetry{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrDuplicateTemplateName = errors.New("template name already exists")
+)
+
+type TemplateRepository interface {
+	Create(template *models.MessageTemplate) error
+	FindByID(id uint) (*models.MessageTemplate, error)
+	FindByUserID(userID uuid.UUID) ([]models.MessageTemplate, error)
+	Update(template *models.MessageTemplate) error
+	Delete(id uint) error
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
+
+func (r *templateRepository) Create(template *models.MessageTemplate) error {
+	err := r.db.Create(template).Error
+	if err != nil && isDuplicateKeyError(err) {
+		return ErrDuplicateTemplateName
+	}
+	return err
+}
+
+func (r *templateRepository) FindByID(id uint) (*models.MessageTemplate, error) {
+	var template models.MessageTemplate
+	err := r.db.Where("id = ?", id).First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *templateRepository) FindByUserID(userID uuid.UUID) ([]models.MessageTemplate, error) {
+	var templates []models.MessageTemplate
+	err := r.db.Where("user_id = ?", userID).Order("name ASC").Find(&templates).Error
+	return templates, err
+}
+
+func (r *templateRepository) Update(template *models.MessageTemplate) error {
+	err := r.db.Save(template).Error
+	if err != nil && isDuplicateKeyError(err) {
+		return ErrDuplicateTemplateName
+	}
+	return err
+}
+
+func (r *templateRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.MessageTemplate{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
@@ -23,6 +23,11 @@ type UserRepository interface {
 	Update(user *models.User) error
 	Delete(id uuid.UUID) error
 	UpdateAPIKey(id uuid.UUID, newKey string) error
+	UpdateStatusCallback(id uuid.UUID, url, secret string) error
+	UpdateRoutingStrategy(id uuid.UUID, strategy string) error
+	UpdateFailoverMaxAttempts(id uuid.UUID, maxAttempts int) error
+	UpdateDefaultCountry(id uuid.UUID, country string) error
+	UpdateKeywords(id uuid.UUID, optOut, optIn []string) error
 }
 
 type userRepository struct {
@@ -123,3 +128,64 @@ func (r *userRepository) UpdateAPIKey(id uuid.UUID, newKey string) error {
 	}
 	return nil
 }
+
+func (r *userRepository) UpdateStatusCallback(id uuid.UUID, url, secret string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_callback_url": url,
+		"callback_secret":     secret,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateRoutingStrategy(id uuid.UUID, strategy string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("routing_strategy", strategy)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateFailoverMaxAttempts(id uuid.UUID, maxAttempts int) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("failover_max_attempts", maxAttempts)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateDefaultCountry(id uuid.UUID, country string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("default_country", country)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateKeywords(id uuid.UUID, optOut, optIn []string) error {
+	result := r.db.Model(&models.User{ID: id}).Select("OptOutKeywords", "OptInKeywords").Updates(&models.User{
+		OptOutKeywords: optOut,
+		OptInKeywords:  optIn,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
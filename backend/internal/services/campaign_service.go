@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+const (
+	MaxCampaignRecipients = 10000
+
+	DefaultCampaignRate = 20
+	MaxCampaignRate     = 120
+)
+
+var (
+	ErrCampaignNotFound      = errors.New("campaign not found")
+	ErrCampaignEmpty         = errors.New("campaign has no recipients")
+	ErrCampaignTooLarge      = errors.New("too many recipients")
+	ErrInvalidCampaignRate   = errors.New("invalid rate per minute")
+	ErrCampaignStateConflict = errors.New("campaign cannot change to the requested state")
+)
+
+type CampaignRecipientInput struct {
+	Phone     string
+	Variables map[string]string
+}
+
+type CreateCampaignRequest struct {
+	Name          string
+	Content       string
+	Recipients    []CampaignRecipientInput
+	Strategy      string
+	RatePerMinute int
+	TTL           time.Duration
+	MaxAttempts   int
+	Transliterate bool
+
+	StatusCallbackURL string
+}
+
+// CampaignProgress combines recipients not yet handed to the queue with the
+// state of the messages that were.
+type CampaignProgress struct {
+	Total     int64 `json:"total"`
+	Pending   int64 `json:"pending"`
+	Queued    int64 `json:"queued"`
+	Sending   int64 `json:"sending"`
+	Sent      int64 `json:"sent"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+	Expired   int64 `json:"expired"`
+	Rejected  int64 `json:"rejected"`
+	Cancelled int64 `json:"cancelled"`
+}
+
+// CampaignResult is one recipient row joined with its outbound message, nil
+// while the recipient has not been submitted.
+type CampaignResult struct {
+	Recipient models.CampaignRecipient
+	Log       *models.MessageLog
+}
+
+type CampaignService interface {
+	Create(userID uuid.UUID, req *CreateCampaignRequest) (*models.Campaign, error)
+	Get(id, userID uuid.UUID) (*models.Campaign, error)
+	List(userID uuid.UUID, limit, offset int) ([]models.Campaign, int64, error)
+	Progress(campaignID uuid.UUID) (*CampaignProgress, error)
+	Pause(id, userID uuid.UUID) (*models.Campaign, error)
+	Resume(id, userID uuid.UUID) (*models.Campaign, error)
+	Cancel(id, userID uuid.UUID) (*models.Campaign, error)
+	Results(id, userID uuid.UUID) ([]CampaignResult, error)
+	// DispatchDue is run periodically by the worker; see
+	// workers.TypeCampaignDispatch
+	DispatchDue(ctx context.Context) (int, error)
+}
+
+type campaignService struct {
+	repo     repository.CampaignRepository
+	logRepo  repository.LogRepository
+	outbound OutboundService
+	gateway  SMSGateway
+	router   RoutingService
+	limiter  DeviceRateLimiter
+}
+
+func NewCampaignService(
+	repo repository.CampaignRepository,
+	logRepo repository.LogRepository,
+	outbound OutboundService,
+	gateway SMSGateway,
+	router RoutingService,
+	limiter DeviceRateLimiter,
+) CampaignService {
+	return &campaignService{
+		repo:     repo,
+		logRepo:  logRepo,
+		outbound: outbound,
+		gateway:  gateway,
+		router:   router,
+		limiter:  limiter,
+	}
+}
+
+// Create validates every recipient up front, so a campaign never starts with
+// rows that cannot be rendered, and stores it as running.
+func (s *campaignService) Create(userID uuid.UUID, req *CreateCampaignRequest) (*models.Campaign, error) {
+	if len(req.Recipients) == 0 {
+		return nil, ErrCampaignEmpty
+	}
+	if len(req.Recipients) > MaxCampaignRecipients {
+		return nil, ErrCampaignTooLarge
+	}
+
+	rate := req.RatePerMinute
+	if rate == 0 {
+		rate = DefaultCampaignRate
+	}
+	if rate < 1 || rate > MaxCampaignRate {
+		return nil, ErrInvalidCampaignRate
+	}
+
+	if req.TTL < 0 || req.TTL > MaxOutboundTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	maxAttempts := req.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+	if maxAttempts < 1 || maxAttempts > MaxFailoverAttempts {
+		return nil, ErrInvalidMaxAttempts
+	}
+
+	segments := 0
+	recipients := make([]models.CampaignRecipient, len(req.Recipients))
+	for i, input := range req.Recipients {
+		rendered, err := RenderContent(req.Content, input.Variables)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i, err)
+		}
+		if req.Transliterate {
+			rendered = sms.Transliterate(rendered)
+		}
+
+		info, err := CheckContent(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("recipient %d: %w", i, err)
+		}
+		segments += info.Segments
+
+		recipients[i] = models.CampaignRecipient{
+			Phone:     input.Phone,
+			Variables: input.Variables,
+			Status:    models.RecipientPending,
+		}
+	}
+
+	campaign := &models.Campaign{
+		UserID:        userID,
+		Name:          req.Name,
+		Content:       req.Content,
+		Status:        models.CampaignRunning,
+		Total:         len(recipients),
+		Segments:      segments,
+		Strategy:      req.Strategy,
+		RatePerMinute: rate,
+		TTLSeconds:    int(req.TTL / time.Second),
+		MaxAttempts:   maxAttempts,
+		Transliterate: req.Transliterate,
+
+		StatusCallbackURL: req.StatusCallbackURL,
+	}
+
+	if err := s.repo.Create(campaign, recipients); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+func (s *campaignService) Get(id, userID uuid.UUID) (*models.Campaign, error) {
+	campaign, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrCampaignNotFound) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+
+	if campaign.UserID != userID {
+		return nil, ErrCampaignNotFound
+	}
+
+	return campaign, nil
+}
+
+func (s *campaignService) List(userID uuid.UUID, limit, offset int) ([]models.Campaign, int64, error) {
+	return s.repo.FindByUserID(userID, limit, offset)
+}
+
+func (s *campaignService) Progress(campaignID uuid.UUID) (*CampaignProgress, error) {
+	recipients, err := s.repo.CountRecipients(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := s.logRepo.CountByCampaign(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &CampaignProgress{
+		Pending:   recipients[models.RecipientPending],
+		Rejected:  recipients[models.RecipientRejected],
+		Cancelled: recipients[models.RecipientCancelled],
+		Queued:    logs[models.StatusQueued],
+		Sending:   logs[models.StatusPending],
+		Sent:      logs[models.StatusSent],
+		Delivered: logs[models.StatusDelivered],
+		Failed:    logs[models.StatusFailed],
+		Expired:   logs[models.StatusExpired],
+	}
+	for _, count := range recipients {
+		progress.Total += count
+	}
+
+	return progress, nil
+}
+
+func (s *campaignService) Pause(id, userID uuid.UUID) (*models.Campaign, error) {
+	return s.changeStatus(id, userID, []models.CampaignStatus{models.CampaignRunning}, models.CampaignPaused)
+}
+
+func (s *campaignService) Resume(id, userID uuid.UUID) (*models.Campaign, error) {
+	return s.changeStatus(id, userID, []models.CampaignStatus{models.CampaignPaused}, models.CampaignRunning)
+}
+
+// Cancel stops the campaign for good: recipients not yet submitted are
+// dropped and submitted messages still waiting in the queue are failed.
+// Messages already on a device are left to finish.
+func (s *campaignService) Cancel(id, userID uuid.UUID) (*models.Campaign, error) {
+	campaign, err := s.changeStatus(id, userID,
+		[]models.CampaignStatus{models.CampaignRunning, models.CampaignPaused}, models.CampaignCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.CancelPendingRecipients(id); err != nil {
+		return nil, err
+	}
+	if _, err := s.outbound.CancelCampaign(id); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+func (s *campaignService) Results(id, userID uuid.UUID) ([]CampaignResult, error) {
+	if _, err := s.Get(id, userID); err != nil {
+		return nil, err
+	}
+
+	recipients, err := s.repo.FindRecipients(id)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := s.logRepo.FindByCampaign(id)
+	if err != nil {
+		return nil, err
+	}
+
+	byRequestID := make(map[string]*models.MessageLog, len(logs))
+	for i := range logs {
+		byRequestID[logs[i].RequestID] = &logs[i]
+	}
+
+	results := make([]CampaignResult, len(recipients))
+	for i, recipient := range recipients {
+		results[i] = CampaignResult{
+			Recipient: recipient,
+			Log:       byRequestID[recipient.RequestID],
+		}
+	}
+	return results, nil
+}
+
+// DispatchDue submits the next slice of every running campaign to the
+// outbound queue, as many recipients as the user's online devices still have
+// room for in the current rate window.
+func (s *campaignService) DispatchDue(ctx context.Context) (int, error) {
+	campaigns, err := s.repo.FindRunning()
+	if err != nil {
+		return 0, err
+	}
+
+	submitted := 0
+	for i := range campaigns {
+		count, err := s.dispatchCampaign(ctx, &campaigns[i])
+		submitted += count
+		if err != nil {
+			log.Printf("[campaign] failed to dispatch campaign %s: %v", campaigns[i].ID, err)
+		}
+	}
+	return submitted, nil
+}
+
+func (s *campaignService) changeStatus(id, userID uuid.UUID, from []models.CampaignStatus, to models.CampaignStatus) (*models.Campaign, error) {
+	if _, err := s.Get(id, userID); err != nil {
+		return nil, err
+	}
+
+	changed, err := s.repo.UpdateStatus(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, ErrCampaignStateConflict
+	}
+
+	return s.repo.FindByID(id)
+}
+
+func (s *campaignService) dispatchCampaign(ctx context.Context, campaign *models.Campaign) (int, error) {
+	devices := s.gateway.GetOnlineDevices(campaign.UserID)
+	if len(devices) == 0 {
+		return 0, nil
+	}
+
+	budget := make(map[uuid.UUID]int, len(devices))
+	capacity := 0
+	for _, deviceID := range devices {
+		available, err := s.limiter.Available(ctx, deviceID, campaign.RatePerMinute)
+		if err != nil {
+			return 0, err
+		}
+		if available > 0 {
+			budget[deviceID] = available
+			capacity += available
+		}
+	}
+	if capacity == 0 {
+		return 0, nil
+	}
+
+	recipients, err := s.repo.FindPendingRecipients(campaign.ID, capacity)
+	if err != nil {
+		return 0, err
+	}
+
+	submitted := 0
+	for _, recipient := range recipients {
+		deviceID, simSlot, ok := s.pickDevice(campaign, recipient.Phone, budget)
+		if !ok {
+			break
+		}
+
+		if err := s.submit(ctx, campaign, &recipient, deviceID, simSlot); err != nil {
+			return submitted, err
+		}
+
+		budget[deviceID]--
+		if budget[deviceID] == 0 {
+			delete(budget, deviceID)
+		}
+		submitted++
+	}
+
+	if len(recipients) < capacity {
+		completed, err := s.repo.UpdateStatus(campaign.ID, []models.CampaignStatus{models.CampaignRunning}, models.CampaignCompleted)
+		if err != nil {
+			return submitted, err
+		}
+		if completed {
+			log.Printf("[campaign] campaign %s fully submitted", campaign.ID)
+		}
+	}
+
+	return submitted, nil
+}
+
+// pickDevice routes the recipient among the devices that still have budget
+// left, using the campaign's strategy.
+func (s *campaignService) pickDevice(campaign *models.Campaign, phone string, budget map[uuid.UUID]int) (uuid.UUID, int, bool) {
+	candidates := make([]uuid.UUID, 0, len(budget))
+	for deviceID := range budget {
+		candidates = append(candidates, deviceID)
+	}
+	if len(candidates) == 0 {
+		return uuid.Nil, 0, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].String() < candidates[j].String()
+	})
+
+	strategy := campaign.Strategy
+	if strategy == "" {
+		strategy = models.RoutingRoundRobin
+	}
+
+	decision, err := s.router.Select(strategy, campaign.UserID, phone, candidates)
+	if err != nil {
+		return candidates[0], 0, true
+	}
+
+	simSlot := 0
+	if decision.SimSlot != nil {
+		simSlot = *decision.SimSlot
+	}
+	return decision.DeviceID, simSlot, true
+}
+
+// submit hands one recipient to the outbound queue pinned to the chosen
+// device, so the per-device rate budget holds even when it fails over.
+func (s *campaignService) submit(ctx context.Context, campaign *models.Campaign, recipient *models.CampaignRecipient, deviceID uuid.UUID, simSlot int) error {
+	content, err := RenderContent(campaign.Content, recipient.Variables)
+	if err != nil {
+		return s.repo.MarkRecipientRejected(recipient.ID, err.Error())
+	}
+	if campaign.Transliterate {
+		content = sms.Transliterate(content)
+	}
+
+	msgLog, err := s.outbound.Send(campaign.UserID, &SendRequest{
+		Phone:       recipient.Phone,
+		Content:     content,
+		DeviceID:    &deviceID,
+		SimSlot:     simSlot,
+		TTL:         time.Duration(campaign.TTLSeconds) * time.Second,
+		MaxAttempts: campaign.MaxAttempts,
+		CampaignID:  &campaign.ID,
+
+		StatusCallbackURL: campaign.StatusCallbackURL,
+	})
+	if errors.Is(err, ErrRecipientSuppressed) {
+		return s.repo.MarkRecipientRejected(recipient.ID, SuppressedErrorCode)
+	}
+	if err != nil {
+		return s.repo.MarkRecipientRejected(recipient.ID, err.Error())
+	}
+
+	if err := s.limiter.Record(ctx, deviceID); err != nil {
+		log.Printf("[campaign] failed to record send on device %s: %v", deviceID, err)
+	}
+	return s.repo.MarkRecipientSubmitted(recipient.ID, msgLog.RequestID)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+var (
+	ErrCapturePolicyNotFound     = errors.New("capture policy not found")
+	ErrCapturePolicyAccessDenied = errors.New("access denied to this capture policy")
+	ErrInvalidCaptureMode        = errors.New("invalid capture mode")
+)
+
+type SetCapturePolicyRequest struct {
+	DeviceID *string  `json:"device_id"`
+	Mode     string   `json:"mode"`
+	Packages []string `json:"packages"`
+}
+
+type CapturePolicyService interface {
+	ListByUser(userID uuid.UUID) ([]models.CapturePolicy, error)
+	Set(userID uuid.UUID, req *SetCapturePolicyRequest) (*models.CapturePolicy, error)
+	Delete(id uint, userID uuid.UUID) (*models.CapturePolicy, error)
+	Effective(userID, deviceID uuid.UUID) (*models.CapturePolicy, error)
+	Allows(userID, deviceID uuid.UUID, pkg string) (bool, error)
+}
+
+type capturePolicyService struct {
+	repo       repository.CapturePolicyRepository
+	deviceRepo repository.DeviceRepository
+}
+
+func NewCapturePolicyService(repo repository.CapturePolicyRepository, deviceRepo repository.DeviceRepository) CapturePolicyService {
+	return &capturePolicyService{
+		repo:       repo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+func (s *capturePolicyService) ListByUser(userID uuid.UUID) ([]models.CapturePolicy, error) {
+	return s.repo.FindByUserID(userID)
+}
+
+// Set creates or replaces the policy for the requested scope. Omitting
+// device_id targets the account-wide policy.
+func (s *capturePolicyService) Set(userID uuid.UUID, req *SetCapturePolicyRequest) (*models.CapturePolicy, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = models.CaptureModeBlocklist
+	}
+	if mode != models.CaptureModeAllowlist && mode != models.CaptureModeBlocklist {
+		return nil, ErrInvalidCaptureMode
+	}
+
+	var deviceID *uuid.UUID
+	if req.DeviceID != nil && *req.DeviceID != "" {
+		parsed, err := uuid.Parse(*req.DeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device_id: %w", err)
+		}
+
+		device, err := s.deviceRepo.FindByID(parsed)
+		if err != nil {
+			if errors.Is(err, repository.ErrDeviceNotFound) {
+				return nil, ErrDeviceNotFound
+			}
+			return nil, err
+		}
+		if device.UserID != userID {
+			return nil, ErrDeviceNotFound
+		}
+		deviceID = &parsed
+	}
+
+	policy, err := s.repo.FindByScope(userID, deviceID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrCapturePolicyNotFound) {
+			return nil, err
+		}
+		policy = &models.CapturePolicy{
+			UserID:   userID,
+			DeviceID: deviceID,
+		}
+	}
+
+	policy.Mode = mode
+	policy.Packages = normalizePackages(req.Packages)
+
+	if err := s.repo.Save(policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func (s *capturePolicyService) Delete(id uint, userID uuid.UUID) (*models.CapturePolicy, error) {
+	policy, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrCapturePolicyNotFound) {
+			return nil, ErrCapturePolicyNotFound
+		}
+		return nil, err
+	}
+
+	if policy.UserID != userID {
+		return nil, ErrCapturePolicyAccessDenied
+	}
+
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, repository.ErrCapturePolicyNotFound) {
+			return nil, ErrCapturePolicyNotFound
+		}
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Effective resolves the policy that applies to a device: its own policy if
+// one exists, otherwise the account-wide one. A nil policy means capture all.
+func (s *capturePolicyService) Effective(userID, deviceID uuid.UUID) (*models.CapturePolicy, error) {
+	policy, err := s.repo.FindByScope(userID, &deviceID)
+	if err == nil {
+		return policy, nil
+	}
+	if !errors.Is(err, repository.ErrCapturePolicyNotFound) {
+		return nil, err
+	}
+
+	policy, err = s.repo.FindByScope(userID, nil)
+	if err != nil {
+		if errors.Is(err, repository.ErrCapturePolicyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+func (s *capturePolicyService) Allows(userID, deviceID uuid.UUID, pkg string) (bool, error) {
+	policy, err := s.Effective(userID, deviceID)
+	if err != nil {
+		return false, err
+	}
+	if policy == nil {
+		return true, nil
+	}
+	return policy.Allows(pkg), nil
+}
+
+func normalizePackages(packages []string) []string {
+	seen := make(map[string]bool, len(packages))
+	result := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		pkg = strings.TrimSpace(pkg)
+		if pkg == "" || seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		result = append(result, pkg)
+	}
+	return result
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+const (
+	// DefaultCommandTTL is how long an unacknowledged command is kept for
+	// resending before it is reported failed
+	DefaultCommandTTL = 24 * time.Hour
+
+	// MaxCommandAttempts caps the deliveries of one command, counting the
+	// first send
+	MaxCommandAttempts = 5
+)
+
+var (
+	ErrCommandNotFound = errors.New("device command not found")
+)
+
+// runOnceCommands are never resent on reconnect. The device may have run one
+// before its ACK was lost, and running it twice does harm: a USSD code can
+// charge the SIM, and a restart would loop on every reconnect.
+var runOnceCommands = map[string]bool{
+	models.CommandRunUSSD:        true,
+	models.CommandRestartService: true,
+}
+
+// CommandResult is what a device reported for a remote control command.
+type CommandResult struct {
+	Success bool
+	Error   string
+	Data    []byte
+}
+
+// CommandListener is notified when a command fails without being
+// acknowledged.
+type CommandListener func(cmd *models.DeviceCommand)
+
+type CommandService interface {
+	// Record stores a command that has just been handed to its device
+	Record(cmd *models.DeviceCommand) error
+	// Discard forgets a command whose first send failed outright; the caller
+	// keeps ownership of the retry
+	Discard(id uuid.UUID) error
+	Ack(deviceID, id uuid.UUID) error
+	// AckRef acknowledges the device's commands for ref, for devices that
+	// report a result without sending an ACK first
+	AckRef(deviceID uuid.UUID, ref string) error
+	// CancelRef fails the pending commands for ref once what they act on
+	// was settled elsewhere, e.g. a SEND_SMS whose message expired. The
+	// OnFailed listeners are not notified.
+	CancelRef(ref, reason string) error
+	// Complete stores a device's result and returns the settled command, or
+	// ErrCommandNotFound for unknown and already settled commands
+	Complete(deviceID, id uuid.UUID, result *CommandResult) (*models.DeviceCommand, error)
+	// Resendable returns the device's unacknowledged commands that may be
+	// sent again, failing those that are expired or out of attempts
+	Resendable(deviceID uuid.UUID) ([]models.DeviceCommand, error)
+	MarkResent(id uuid.UUID) error
+	Get(userID, id uuid.UUID) (*models.DeviceCommand, error)
+	List(userID, deviceID uuid.UUID, status models.CommandStatus, limit, offset int) ([]models.DeviceCommand, int64, error)
+	// ExpireStale is run periodically by the worker; see
+	// workers.TypeCommandExpiry
+	ExpireStale() (int, error)
+	OnFailed(listener CommandListener)
+}
+
+type commandService struct {
+	repo       repository.DeviceCommandRepository
+	deviceRepo repository.DeviceRepository
+	mu         sync.RWMutex
+	listeners  []CommandListener
+}
+
+func NewCommandService(repo repository.DeviceCommandRepository, deviceRepo repository.DeviceRepository) CommandService {
+	return &commandService{
+		repo:       repo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+func (s *commandService) Record(cmd *models.DeviceCommand) error {
+	now := time.Now()
+	if cmd.SentAt.IsZero() {
+		cmd.SentAt = now
+	}
+	if cmd.ExpiresAt.IsZero() {
+		cmd.ExpiresAt = now.Add(DefaultCommandTTL)
+	}
+	if cmd.Attempts == 0 {
+		cmd.Attempts = 1
+	}
+	return s.repo.Create(cmd)
+}
+
+func (s *commandService) Discard(id uuid.UUID) error {
+	return s.repo.Delete(id)
+}
+
+func (s *commandService) Ack(deviceID, id uuid.UUID) error {
+	acked, err := s.repo.Ack(deviceID, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !acked {
+		log.Printf("[commands] ignored ACK for unknown or settled command %s from device %s", id, deviceID)
+	}
+	return nil
+}
+
+func (s *commandService) AckRef(deviceID uuid.UUID, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	_, err := s.repo.AckByRef(deviceID, ref, time.Now())
+	return err
+}
+
+func (s *commandService) CancelRef(ref, reason string) error {
+	if ref == "" {
+		return nil
+	}
+	_, err := s.repo.FailByRef(ref, reason)
+	return err
+}
+
+func (s *commandService) Complete(deviceID, id uuid.UUID, result *CommandResult) (*models.DeviceCommand, error) {
+	status := models.CommandCompleted
+	if !result.Success {
+		status = models.CommandFailed
+	}
+
+	completed, err := s.repo.Complete(deviceID, id, status, result.Error, result.Data, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !completed {
+		return nil, ErrCommandNotFound
+	}
+	return s.repo.FindByID(id)
+}
+
+func (s *commandService) Resendable(deviceID uuid.UUID) ([]models.DeviceCommand, error) {
+	cmds, err := s.repo.FindUnacked(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resendable := make([]models.DeviceCommand, 0, len(cmds))
+	for i := range cmds {
+		switch {
+		case !now.Before(cmds[i].ExpiresAt):
+			s.fail(&cmds[i], "expired without acknowledgement")
+		case cmds[i].Attempts >= MaxCommandAttempts:
+			s.fail(&cmds[i], "not acknowledged after maximum attempts")
+		case runOnceCommands[cmds[i].Type]:
+			s.fail(&cmds[i], "not acknowledged before the connection dropped; not resent")
+		default:
+			resendable = append(resendable, cmds[i])
+		}
+	}
+	return resendable, nil
+}
+
+func (s *commandService) MarkResent(id uuid.UUID) error {
+	return s.repo.MarkResent(id, time.Now())
+}
+
+func (s *commandService) Get(userID, id uuid.UUID) (*models.DeviceCommand, error) {
+	cmd, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrCommandNotFound) {
+			return nil, ErrCommandNotFound
+		}
+		return nil, err
+	}
+
+	if !s.ownsDevice(userID, cmd.DeviceID) {
+		return nil, ErrCommandNotFound
+	}
+	return cmd, nil
+}
+
+func (s *commandService) List(userID, deviceID uuid.UUID, status models.CommandStatus, limit, offset int) ([]models.DeviceCommand, int64, error) {
+	if !s.ownsDevice(userID, deviceID) {
+		return nil, 0, ErrDeviceNotFound
+	}
+	return s.repo.FindByDevice(deviceID, status, limit, offset)
+}
+
+func (s *commandService) ExpireStale() (int, error) {
+	cmds, err := s.repo.FindExpired(time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range cmds {
+		if s.fail(&cmds[i], "expired without acknowledgement") {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (s *commandService) OnFailed(listener CommandListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *commandService) fail(cmd *models.DeviceCommand, reason string) bool {
+	failed, err := s.repo.Fail(cmd.ID, reason)
+	if err != nil {
+		log.Printf("[commands] failed to mark command %s failed: %v", cmd.ID, err)
+		return false
+	}
+	if !failed {
+		return false
+	}
+
+	cmd.Status = models.CommandFailed
+	cmd.Error = reason
+
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(cmd)
+	}
+	return true
+}
+
+func (s *commandService) ownsDevice(userID, deviceID uuid.UUID) bool {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	return err == nil && device.UserID == userID
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+// fakeCommandRepository keeps commands in memory. Methods the tests do not
+// reach are left to the embedded nil interface and panic if called.
+type fakeCommandRepository struct {
+	repository.DeviceCommandRepository
+
+	mu   sync.Mutex
+	cmds []models.DeviceCommand
+}
+
+func (r *fakeCommandRepository) FindUnacked(deviceID uuid.UUID) ([]models.DeviceCommand, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []models.DeviceCommand
+	for _, cmd := range r.cmds {
+		if cmd.DeviceID == deviceID && cmd.Status == models.CommandPending {
+			found = append(found, cmd)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeCommandRepository) Fail(id uuid.UUID, reason string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.cmds {
+		if r.cmds[i].ID == id && r.cmds[i].Status == models.CommandPending {
+			r.cmds[i].Status = models.CommandFailed
+			r.cmds[i].Error = reason
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestResendable(t *testing.T) {
+	deviceID := uuid.New()
+	live := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		cmd        models.DeviceCommand
+		wantResend bool
+	}{
+		{"send sms", models.DeviceCommand{Type: "SEND_SMS", Attempts: 1, ExpiresAt: live}, true},
+		{"status request", models.DeviceCommand{Type: models.CommandRequestStatus, Attempts: 1, ExpiresAt: live}, true},
+		{"ussd is never resent", models.DeviceCommand{Type: models.CommandRunUSSD, Attempts: 1, ExpiresAt: live}, false},
+		{"restart is never resent", models.DeviceCommand{Type: models.CommandRestartService, Attempts: 1, ExpiresAt: live}, false},
+		{"expired", models.DeviceCommand{Type: models.CommandRequestStatus, Attempts: 1, ExpiresAt: time.Now()}, false},
+		{"out of attempts", models.DeviceCommand{Type: "SEND_SMS", Attempts: MaxCommandAttempts, ExpiresAt: live}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := tt.cmd
+			cmd.ID = uuid.New()
+			cmd.DeviceID = deviceID
+			cmd.Status = models.CommandPending
+			repo := &fakeCommandRepository{cmds: []models.DeviceCommand{cmd}}
+
+			svc := NewCommandService(repo, &fakeDeviceRepository{})
+			var failed []uuid.UUID
+			svc.OnFailed(func(cmd *models.DeviceCommand) { failed = append(failed, cmd.ID) })
+
+			resendable, err := svc.Resendable(deviceID)
+			if err != nil {
+				t.Fatalf("Resendable: %v", err)
+			}
+			if got := len(resendable) == 1; got != tt.wantResend {
+				t.Fatalf("resent = %v, want %v", got, tt.wantResend)
+			}
+			if wantFailed := !tt.wantResend; (len(failed) == 1) != wantFailed {
+				t.Errorf("failed listeners ran for %v, want failed = %v", failed, wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+// MaxSegments caps how many concatenated parts one outbound message may
+// take: 1530 GSM-7 characters or 670 UCS-2 ones.
+const MaxSegments = 10
+
+var ErrContentTooLong = errors.New("content exceeds maximum length")
+
+// CheckContent reports how content will be encoded and rejects messages
+// longer than MaxSegments.
+func CheckContent(content string) (sms.Info, error) {
+	info := sms.Analyze(content)
+	if info.Segments > MaxSegments {
+		return info, fmt.Errorf("%w: %d %s segments, at most %d allowed",
+			ErrContentTooLong, info.Segments, info.Encoding, MaxSegments)
+	}
+	return info, nil
+}
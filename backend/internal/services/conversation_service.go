@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+var (
+	ErrThreadNotFound  = errors.New("conversation not found")
+	ErrInvalidThreadID = errors.New("invalid conversation id")
+)
+
+// Thread is one conversation with a remote number over a single device SIM.
+// Its ID is "<device id>:<sim slot>:<remote number>".
+type Thread struct {
+	ID            string
+	DeviceID      uuid.UUID
+	SimSlot       int
+	RemoteNumber  string
+	LastMessage   *models.MessageLog
+	LastMessageAt time.Time
+	MessageCount  int64
+	UnreadCount   int64
+}
+
+type ThreadListParams struct {
+	DeviceID   *uuid.UUID
+	Search     string
+	UnreadOnly bool
+	Limit      int
+	Offset     int
+}
+
+type ReplyRequest struct {
+	Content string
+	TTL     time.Duration
+}
+
+type ConversationService interface {
+	List(userID uuid.UUID, params *ThreadListParams) ([]Thread, int64, error)
+	Get(userID uuid.UUID, threadID string) (*Thread, error)
+	// Messages returns a page of the thread in chronological order; page
+	// one holds the most recent messages
+	Messages(userID uuid.UUID, threadID string, limit, offset int) ([]models.MessageLog, error)
+	MarkRead(userID uuid.UUID, threadID string) (int64, error)
+	// Reply sends from the device and SIM the conversation runs over
+	Reply(userID uuid.UUID, threadID string, req *ReplyRequest) (*models.MessageLog, error)
+}
+
+type conversationService struct {
+	repo     repository.ConversationRepository
+	outbound OutboundService
+}
+
+func NewConversationService(repo repository.ConversationRepository, outbound OutboundService) ConversationService {
+	return &conversationService{
+		repo:     repo,
+		outbound: outbound,
+	}
+}
+
+func FormatThreadID(deviceID uuid.UUID, simSlot int, remoteNumber string) string {
+	return deviceID.String() + ":" + strconv.Itoa(simSlot) + ":" + remoteNumber
+}
+
+func ParseThreadID(threadID string) (repository.ThreadKey, error) {
+	parts := strings.SplitN(threadID, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return repository.ThreadKey{}, ErrInvalidThreadID
+	}
+
+	deviceID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return repository.ThreadKey{}, ErrInvalidThreadID
+	}
+	simSlot, err := strconv.Atoi(parts[1])
+	if err != nil || simSlot < 0 {
+		return repository.ThreadKey{}, ErrInvalidThreadID
+	}
+
+	return repository.ThreadKey{DeviceID: deviceID, SimSlot: simSlot, RemoteNumber: parts[2]}, nil
+}
+
+func (s *conversationService) List(userID uuid.UUID, params *ThreadListParams) ([]Thread, int64, error) {
+	summaries, total, err := s.repo.FindThreads(userID, &repository.ThreadFilter{
+		DeviceID:   params.DeviceID,
+		Search:     strings.TrimSpace(params.Search),
+		UnreadOnly: params.UnreadOnly,
+	}, params.Limit, params.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uint, len(summaries))
+	for i, summary := range summaries {
+		ids[i] = summary.LastMessageID
+	}
+	logs, err := s.repo.FindLogs(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]*models.MessageLog, len(logs))
+	for i := range logs {
+		byID[logs[i].ID] = &logs[i]
+	}
+
+	threads := make([]Thread, len(summaries))
+	for i := range summaries {
+		threads[i] = toThread(&summaries[i], byID[summaries[i].LastMessageID])
+	}
+	return threads, total, nil
+}
+
+func (s *conversationService) Get(userID uuid.UUID, threadID string) (*Thread, error) {
+	key, summary, err := s.find(userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := s.repo.FindMessages(userID, key, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *models.MessageLog
+	if len(logs) > 0 {
+		last = &logs[0]
+	}
+	thread := toThread(summary, last)
+	return &thread, nil
+}
+
+func (s *conversationService) Messages(userID uuid.UUID, threadID string, limit, offset int) ([]models.MessageLog, error) {
+	key, _, err := s.find(userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := s.repo.FindMessages(userID, key, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+		logs[i], logs[j] = logs[j], logs[i]
+	}
+	return logs, nil
+}
+
+func (s *conversationService) MarkRead(userID uuid.UUID, threadID string) (int64, error) {
+	key, _, err := s.find(userID, threadID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.MarkRead(userID, key, time.Now())
+}
+
+func (s *conversationService) Reply(userID uuid.UUID, threadID string, req *ReplyRequest) (*models.MessageLog, error) {
+	key, _, err := s.find(userID, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID := key.DeviceID
+	return s.outbound.Send(userID, &SendRequest{
+		Phone:    key.RemoteNumber,
+		Content:  req.Content,
+		DeviceID: &deviceID,
+		SimSlot:  key.SimSlot,
+		TTL:      req.TTL,
+	})
+}
+
+func (s *conversationService) find(userID uuid.UUID, threadID string) (repository.ThreadKey, *repository.ThreadSummary, error) {
+	key, err := ParseThreadID(threadID)
+	if err != nil {
+		return key, nil, err
+	}
+
+	summary, err := s.repo.FindThread(userID, key)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return key, nil, ErrThreadNotFound
+		}
+		return key, nil, err
+	}
+	return key, summary, nil
+}
+
+func toThread(summary *repository.ThreadSummary, last *models.MessageLog) Thread {
+	return Thread{
+		ID:            FormatThreadID(summary.DeviceID, summary.SimSlot, summary.RemoteNumber),
+		DeviceID:      summary.DeviceID,
+		SimSlot:       summary.SimSlot,
+		RemoteNumber:  summary.RemoteNumber,
+		LastMessage:   last,
+		LastMessageAt: summary.LastMessageAt,
+		MessageCount:  summary.MessageCount,
+		UnreadCount:   summary.UnreadCount,
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+var (
+	ErrDeviceOffline       = errors.New("device is not connected")
+	ErrCommandNotSupported = errors.New("device does not support this command")
+	ErrInvalidDeviceConfig = errors.New("invalid device configuration")
+	ErrInvalidUploadURL    = errors.New("upload_url must be an absolute https URL")
+	ErrInvalidUSSDCode     = errors.New("ussd code must start with * or #, end with # and contain only digits, * and #")
+	ErrInvalidSimSlot      = errors.New("sim_slot is out of range for this device")
+)
+
+const (
+	MinPingInterval = 15
+	MaxPingInterval = 600
+
+	// ControlCommandTTL is how long a remote control command may wait for
+	// the device; an operator expects it to run now or not at all
+	ControlCommandTTL = 5 * time.Minute
+)
+
+var (
+	ussdCodePattern = regexp.MustCompile(`^[*#][0-9*#]{1,30}#$`)
+
+	deviceLogLevels = map[string]bool{
+		"debug": true,
+		"info":  true,
+		"warn":  true,
+		"error": true,
+	}
+)
+
+// CommandGateway hands a tracked command to a connected device and returns
+// its ID. The command fails if the device has not acknowledged it by
+// expiresAt. It is satisfied by websockets.Hub.
+type CommandGateway interface {
+	DispatchCommand(deviceID uuid.UUID, cmdType string, data interface{}, expiresAt time.Time) (uuid.UUID, error)
+}
+
+// DeviceConfig is the UPDATE_CONFIG payload. Only the fields that are set
+// are changed on the device.
+type DeviceConfig struct {
+	// PingInterval is the heartbeat period in seconds
+	PingInterval        *int    `json:"ping_interval,omitempty"`
+	LogLevel            *string `json:"log_level,omitempty"`
+	SMSCapture          *bool   `json:"sms_capture,omitempty"`
+	NotificationCapture *bool   `json:"notification_capture,omitempty"`
+}
+
+// LogUploadData is the UPLOAD_LOGS payload: the device uploads its log
+// bundle to UploadURL with a PUT, e.g. to a presigned storage URL.
+type LogUploadData struct {
+	UploadURL string `json:"upload_url"`
+}
+
+type USSDData struct {
+	Code    string `json:"code"`
+	SimSlot int    `json:"sim_slot"`
+}
+
+// DeviceControlService sends remote control commands. Every command is
+// tracked in the outbox; its result arrives later and is read back through
+// CommandService.
+type DeviceControlService interface {
+	RequestStatus(userID, deviceID uuid.UUID) (*models.DeviceCommand, error)
+	Restart(userID, deviceID uuid.UUID) (*models.DeviceCommand, error)
+	UpdateConfig(userID, deviceID uuid.UUID, config *DeviceConfig) (*models.DeviceCommand, error)
+	UploadLogs(userID, deviceID uuid.UUID, uploadURL string) (*models.DeviceCommand, error)
+	RunUSSD(userID, deviceID uuid.UUID, code string, simSlot int) (*models.DeviceCommand, error)
+}
+
+type deviceControlService struct {
+	commands   repository.DeviceCommandRepository
+	deviceRepo repository.DeviceRepository
+	gateway    CommandGateway
+}
+
+func NewDeviceControlService(commands repository.DeviceCommandRepository, deviceRepo repository.DeviceRepository, gateway CommandGateway) DeviceControlService {
+	return &deviceControlService{
+		commands:   commands,
+		deviceRepo: deviceRepo,
+		gateway:    gateway,
+	}
+}
+
+func (s *deviceControlService) RequestStatus(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+	if _, err := s.device(userID, deviceID); err != nil {
+		return nil, err
+	}
+	return s.dispatch(deviceID, models.CommandRequestStatus, struct{}{})
+}
+
+func (s *deviceControlService) Restart(userID, deviceID uuid.UUID) (*models.DeviceCommand, error) {
+	if _, err := s.device(userID, deviceID); err != nil {
+		return nil, err
+	}
+	return s.dispatch(deviceID, models.CommandRestartService, struct{}{})
+}
+
+func (s *deviceControlService) UpdateConfig(userID, deviceID uuid.UUID, config *DeviceConfig) (*models.DeviceCommand, error) {
+	if err := validateDeviceConfig(config); err != nil {
+		return nil, err
+	}
+	if _, err := s.device(userID, deviceID); err != nil {
+		return nil, err
+	}
+	return s.dispatch(deviceID, models.CommandUpdateConfig, config)
+}
+
+func (s *deviceControlService) UploadLogs(userID, deviceID uuid.UUID, uploadURL string) (*models.DeviceCommand, error) {
+	parsed, err := url.Parse(uploadURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return nil, ErrInvalidUploadURL
+	}
+	if _, err := s.device(userID, deviceID); err != nil {
+		return nil, err
+	}
+	return s.dispatch(deviceID, models.CommandUploadLogs, &LogUploadData{UploadURL: uploadURL})
+}
+
+func (s *deviceControlService) RunUSSD(userID, deviceID uuid.UUID, code string, simSlot int) (*models.DeviceCommand, error) {
+	if !ussdCodePattern.MatchString(code) {
+		return nil, ErrInvalidUSSDCode
+	}
+
+	device, err := s.device(userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if simSlot < 0 || (device.SimCount > 0 && simSlot >= device.SimCount) {
+		return nil, ErrInvalidSimSlot
+	}
+	return s.dispatch(deviceID, models.CommandRunUSSD, &USSDData{Code: code, SimSlot: simSlot})
+}
+
+func (s *deviceControlService) dispatch(deviceID uuid.UUID, cmdType string, data interface{}) (*models.DeviceCommand, error) {
+	id, err := s.gateway.DispatchCommand(deviceID, cmdType, data, time.Now().Add(ControlCommandTTL))
+	if err != nil {
+		return nil, err
+	}
+	return s.commands.FindByID(id)
+}
+
+func (s *deviceControlService) device(userID, deviceID uuid.UUID) (*models.Device, error) {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	if device.UserID != userID {
+		return nil, ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+func validateDeviceConfig(config *DeviceConfig) error {
+	if config == nil || (config.PingInterval == nil && config.LogLevel == nil && config.SMSCapture == nil && config.NotificationCapture == nil) {
+		return ErrInvalidDeviceConfig
+	}
+	if config.PingInterval != nil && (*config.PingInterval < MinPingInterval || *config.PingInterval > MaxPingInterval) {
+		return ErrInvalidDeviceConfig
+	}
+	if config.LogLevel != nil && !deviceLogLevels[*config.LogLevel] {
+		return ErrInvalidDeviceConfig
+	}
+	return nil
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,9 +15,30 @@ import (
 	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
 )
 
+// DeviceTokenPrefix marks device credentials so they are easy to tell apart
+// from account API keys in logs and secret scanners.
+const DeviceTokenPrefix = "thd_"
+
+const (
+	// PairingTokenPrefix marks the one-time secrets shown in pairing QR codes
+	PairingTokenPrefix = "thp_"
+
+	// PairingTokenTTL is how long a QR code can be scanned
+	PairingTokenTTL = 10 * time.Minute
+
+	// pairingTokenRetention keeps used and expired tokens around so the
+	// dashboard can still read their status
+	pairingTokenRetention = 24 * time.Hour
+)
+
 var (
 	ErrDeviceNotFound     = errors.New("device not found")
 	ErrDuplicateDeviceUID = errors.New("device UID already exists")
+	ErrInvalidDeviceToken = errors.New("invalid device token")
+	ErrDeviceRevoked      = errors.New("device access has been revoked")
+
+	ErrPairingTokenNotFound = errors.New("pairing token not found")
+	ErrPairingTokenInvalid  = errors.New("pairing token is invalid, used or expired")
 )
 
 type DeviceService interface {
@@ -26,16 +51,38 @@ type DeviceService interface {
 	SetOnline(id uuid.UUID, battery int) error
 	SetOffline(id uuid.UUID) error
 	UpdateFCMToken(id uuid.UUID, token string) error
-	GeneratePairingToken(userID uuid.UUID) (string, error)
+	UpdateSimCount(id uuid.UUID, count int) error
+	// UpdateProtocol records the app build and protocol from a handshake
+	UpdateProtocol(id uuid.UUID, appVersion string, protocolVersion int) error
+	// Pair registers the device for the user, or takes back one the user
+	// already owns, and issues it a fresh socket token
+	Pair(userID uuid.UUID, name, deviceUID string) (*models.Device, string, error)
+	// IssueToken rotates the device's token; the old one stops working
+	IssueToken(userID, deviceID uuid.UUID) (string, error)
+	AuthenticateToken(token string) (*models.Device, error)
+	// RevokeToken locks a single device out without touching the account
+	// API key or the user's other devices
+	RevokeToken(userID, deviceID uuid.UUID) error
+	// GeneratePairingToken creates a single-use token the user's next phone
+	// pairs with; name, when set, becomes the device name
+	GeneratePairingToken(userID uuid.UUID, name string) (*models.PairingToken, string, error)
+	GetPairingStatus(userID uuid.UUID, token string) (*models.PairingToken, error)
+	// RedeemPairingToken consumes the token and pairs the device under the
+	// user who generated it, returning the device's socket token
+	RedeemPairingToken(token, deviceUID, name string) (*models.Device, string, error)
 	GenerateDeviceUID() (string, error)
 }
 
 type deviceService struct {
-	repo repository.DeviceRepository
+	repo        repository.DeviceRepository
+	pairingRepo repository.PairingTokenRepository
 }
 
-func NewDeviceService(repo repository.DeviceRepository) DeviceService {
-	return &deviceService{repo: repo}
+func NewDeviceService(repo repository.DeviceRepository, pairingRepo repository.PairingTokenRepository) DeviceService {
+	return &deviceService{
+		repo:        repo,
+		pairingRepo: pairingRepo,
+	}
 }
 
 func (s *deviceService) Register(userID uuid.UUID, name, deviceUID string) (*models.Device, error) {
@@ -132,15 +179,212 @@ func (s *deviceService) UpdateFCMToken(id uuid.UUID, token string) error {
 	return err
 }
 
-func (s *deviceService) GeneratePairingToken(userID uuid.UUID) (string, error) {
+func (s *deviceService) UpdateSimCount(id uuid.UUID, count int) error {
+	err := s.repo.UpdateSimCount(id, count)
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		return ErrDeviceNotFound
+	}
+	return err
+}
+
+func (s *deviceService) UpdateProtocol(id uuid.UUID, appVersion string, protocolVersion int) error {
+	err := s.repo.UpdateProtocol(id, appVersion, protocolVersion)
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		return ErrDeviceNotFound
+	}
+	return err
+}
+
+func (s *deviceService) Pair(userID uuid.UUID, name, deviceUID string) (*models.Device, string, error) {
+	if deviceUID == "" {
+		uid, err := s.GenerateDeviceUID()
+		if err != nil {
+			return nil, "", err
+		}
+		deviceUID = uid
+	}
+	if name == "" {
+		name = "Unknown Device"
+	}
+
+	device, err := s.repo.FindByDeviceUID(deviceUID)
+	switch {
+	case errors.Is(err, repository.ErrDeviceNotFound):
+		device, err = s.Register(userID, name, deviceUID)
+		if err != nil {
+			return nil, "", err
+		}
+	case err != nil:
+		return nil, "", err
+	case device.UserID != userID:
+		return nil, "", ErrDuplicateDeviceUID
+	}
+
+	token, err := s.issueToken(device.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	return device, token, nil
+}
+
+func (s *deviceService) IssueToken(userID, deviceID uuid.UUID) (string, error) {
+	if _, err := s.owned(userID, deviceID); err != nil {
+		return "", err
+	}
+	return s.issueToken(deviceID)
+}
+
+func (s *deviceService) AuthenticateToken(token string) (*models.Device, error) {
+	if !strings.HasPrefix(token, DeviceTokenPrefix) {
+		return nil, ErrInvalidDeviceToken
+	}
+
+	device, err := s.repo.FindByTokenHash(hashDeviceToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceNotFound) {
+			return nil, ErrInvalidDeviceToken
+		}
+		return nil, err
+	}
+	if device.RevokedAt != nil {
+		return nil, ErrDeviceRevoked
+	}
+	return device, nil
+}
+
+func (s *deviceService) RevokeToken(userID, deviceID uuid.UUID) error {
+	if _, err := s.owned(userID, deviceID); err != nil {
+		return err
+	}
+
+	err := s.repo.RevokeToken(deviceID, time.Now())
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		return ErrDeviceNotFound
+	}
+	return err
+}
+
+func (s *deviceService) issueToken(deviceID uuid.UUID) (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
-	token := base64.URLEncoding.EncodeToString(bytes)
+	token := DeviceTokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	err := s.repo.SetTokenHash(deviceID, hashDeviceToken(token), time.Now())
+	if errors.Is(err, repository.ErrDeviceNotFound) {
+		return "", ErrDeviceNotFound
+	}
+	if err != nil {
+		return "", err
+	}
 	return token, nil
 }
 
+func (s *deviceService) owned(userID, deviceID uuid.UUID) (*models.Device, error) {
+	device, err := s.GetByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.UserID != userID {
+		return nil, ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+// hashDeviceToken uses a plain SHA-256: unlike passwords, tokens carry 256
+// bits of entropy, and an unsalted hash keeps the lookup indexed.
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *deviceService) GeneratePairingToken(userID uuid.UUID, name string) (*models.PairingToken, string, error) {
+	now := time.Now()
+	if _, err := s.pairingRepo.DeleteExpired(now.Add(-pairingTokenRetention)); err != nil {
+		log.Printf("[pairing] failed to purge expired tokens: %v", err)
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", err
+	}
+	token := PairingTokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	pairing := &models.PairingToken{
+		UserID:     userID,
+		TokenHash:  hashDeviceToken(token),
+		DeviceName: strings.TrimSpace(name),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(PairingTokenTTL),
+	}
+	if err := s.pairingRepo.Create(pairing); err != nil {
+		return nil, "", err
+	}
+	return pairing, token, nil
+}
+
+func (s *deviceService) GetPairingStatus(userID uuid.UUID, token string) (*models.PairingToken, error) {
+	pairing, err := s.findPairingToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if pairing.UserID != userID {
+		return nil, ErrPairingTokenNotFound
+	}
+	return pairing, nil
+}
+
+func (s *deviceService) RedeemPairingToken(token, deviceUID, name string) (*models.Device, string, error) {
+	pairing, err := s.findPairingToken(token)
+	if err != nil {
+		if errors.Is(err, ErrPairingTokenNotFound) {
+			return nil, "", ErrPairingTokenInvalid
+		}
+		return nil, "", err
+	}
+
+	claimed, err := s.pairingRepo.Claim(pairing.ID, time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	if !claimed {
+		return nil, "", ErrPairingTokenInvalid
+	}
+
+	if pairing.DeviceName != "" {
+		name = pairing.DeviceName
+	}
+	device, deviceToken, err := s.Pair(pairing.UserID, name, deviceUID)
+	if err != nil {
+		// Let the phone retry with the same QR code while it is still valid
+		if releaseErr := s.pairingRepo.Release(pairing.ID); releaseErr != nil {
+			log.Printf("[pairing] failed to release token %s: %v", pairing.ID, releaseErr)
+		}
+		return nil, "", err
+	}
+
+	if err := s.pairingRepo.SetDevice(pairing.ID, device.ID); err != nil {
+		log.Printf("[pairing] failed to link token %s to device %s: %v", pairing.ID, device.ID, err)
+	}
+	return device, deviceToken, nil
+}
+
+func (s *deviceService) findPairingToken(token string) (*models.PairingToken, error) {
+	if !strings.HasPrefix(token, PairingTokenPrefix) {
+		return nil, ErrPairingTokenNotFound
+	}
+
+	pairing, err := s.pairingRepo.FindByHash(hashDeviceToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrPairingTokenNotFound) {
+			return nil, ErrPairingTokenNotFound
+		}
+		return nil, err
+	}
+	return pairing, nil
+}
+
 func (s *deviceService) GenerateDeviceUID() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+// Events streamed to a user's dashboard.
+const (
+	// EventLogCreated carries an inbound SMS or notification once stored
+	EventLogCreated EventType = "log.created"
+	// EventLogStatus carries an outbound message when it is queued and on
+	// every status transition after that
+	EventLogStatus EventType = "log.status"
+	// EventWebhookResult reports one forwarding webhook delivery attempt
+	EventWebhookResult EventType = "webhook.result"
+	EventDeviceOnline  EventType = "device.online"
+	EventDeviceOffline EventType = "device.offline"
+	// EventDeviceBattery is raised when a heartbeat reports a new level
+	EventDeviceBattery EventType = "device.battery"
+	// EventDevicePaired fires when a phone redeems a pairing token
+	EventDevicePaired EventType = "device.paired"
+	// EventCommandResult carries a remote control command once the device
+	// answered it
+	EventCommandResult EventType = "command.result"
+)
+
+const (
+	// RecentEventLimit is how many events per user are kept for backfill
+	RecentEventLimit = 200
+	// recentEventTTL drops the backfill of users who stopped generating events
+	recentEventTTL = 24 * time.Hour
+)
+
+// UserEvent is one entry of a user's live event stream.
+type UserEvent struct {
+	ID        string          `json:"id"`
+	Type      EventType       `json:"type"`
+	UserID    uuid.UUID       `json:"user_id"`
+	DeviceID  *uuid.UUID      `json:"device_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func NewUserEvent(userID uuid.UUID, eventType EventType, deviceID *uuid.UUID, data interface{}) (*UserEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &UserEvent{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		UserID:    userID,
+		DeviceID:  deviceID,
+		Data:      payload,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// EventFilter narrows a stream to some event types and devices. Empty lists
+// match everything.
+type EventFilter struct {
+	Types     []EventType
+	DeviceIDs []uuid.UUID
+}
+
+func (f *EventFilter) Matches(event *UserEvent) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !containsEventType(f.Types, event.Type) {
+		return false
+	}
+	if len(f.DeviceIDs) > 0 {
+		if event.DeviceID == nil {
+			return false
+		}
+		for _, id := range f.DeviceIDs {
+			if id == *event.DeviceID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsEventType(types []EventType, eventType EventType) bool {
+	for _, t := range types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EventBus fans user events out to every instance serving dashboard sockets
+// and keeps the latest RecentEventLimit events of each user for backfill.
+type EventBus interface {
+	Publish(ctx context.Context, event *UserEvent) error
+	// Recent returns up to limit of the user's latest events, oldest first
+	Recent(ctx context.Context, userID uuid.UUID, limit int) ([]UserEvent, error)
+	// Subscribe calls handler for every published event until ctx is
+	// cancelled
+	Subscribe(ctx context.Context, handler func(event *UserEvent)) error
+}
+
+// PublishEvent builds and publishes an event on bus, which may be nil. The
+// stream is best effort: failures are logged and never fail the operation
+// that raised the event.
+func PublishEvent(bus EventBus, userID uuid.UUID, eventType EventType, deviceID *uuid.UUID, data interface{}) {
+	if bus == nil {
+		return
+	}
+
+	event, err := NewUserEvent(userID, eventType, deviceID, data)
+	if err != nil {
+		log.Printf("[events] failed to build %s event: %v", eventType, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := bus.Publish(ctx, event); err != nil {
+		log.Printf("[events] failed to publish %s event for user %s: %v", eventType, userID, err)
+	}
+}
+
+type memoryEventBus struct {
+	mu          sync.RWMutex
+	recent      map[uuid.UUID][]UserEvent
+	subscribers map[int]func(event *UserEvent)
+	nextID      int
+}
+
+// NewMemoryEventBus keeps events in process, for single-instance
+// deployments.
+func NewMemoryEventBus() EventBus {
+	return &memoryEventBus{
+		recent:      make(map[uuid.UUID][]UserEvent),
+		subscribers: make(map[int]func(event *UserEvent)),
+	}
+}
+
+func (b *memoryEventBus) Publish(ctx context.Context, event *UserEvent) error {
+	b.mu.Lock()
+	events := append(b.recent[event.UserID], *event)
+	if len(events) > RecentEventLimit {
+		events = append([]UserEvent(nil), events[len(events)-RecentEventLimit:]...)
+	}
+	b.recent[event.UserID] = events
+
+	handlers := make([]func(event *UserEvent), 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (b *memoryEventBus) Recent(ctx context.Context, userID uuid.UUID, limit int) ([]UserEvent, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	events := b.recent[userID]
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return append([]UserEvent(nil), events...), nil
+}
+
+func (b *memoryEventBus) Subscribe(ctx context.Context, handler func(event *UserEvent)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.subscribers, id)
+	b.mu.Unlock()
+	return nil
+}
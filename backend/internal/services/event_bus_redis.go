@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisEventPrefix  = "tinghook:events:"
+	redisEventChannel = redisEventPrefix + "stream"
+)
+
+type redisEventBus struct {
+	client *redis.Client
+}
+
+// NewRedisEventBus keeps each user's recent events in a capped list and
+// fans new ones out over pub/sub, so a dashboard sees events raised on any
+// instance or worker.
+func NewRedisEventBus(client *redis.Client) EventBus {
+	return &redisEventBus{client: client}
+}
+
+func recentEventsKey(userID uuid.UUID) string {
+	return redisEventPrefix + "user:" + userID.String()
+}
+
+func (b *redisEventBus) Publish(ctx context.Context, event *UserEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	key := recentEventsKey(event.UserID)
+	_, err = b.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, payload)
+		pipe.LTrim(ctx, key, 0, RecentEventLimit-1)
+		pipe.Expire(ctx, key, recentEventTTL)
+		pipe.Publish(ctx, redisEventChannel, payload)
+		return nil
+	})
+	return err
+}
+
+func (b *redisEventBus) Recent(ctx context.Context, userID uuid.UUID, limit int) ([]UserEvent, error) {
+	if limit <= 0 || limit > RecentEventLimit {
+		limit = RecentEventLimit
+	}
+
+	values, err := b.client.LRange(ctx, recentEventsKey(userID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// the list is newest first
+	events := make([]UserEvent, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		var event UserEvent
+		if err := json.Unmarshal([]byte(values[i]), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (b *redisEventBus) Subscribe(ctx context.Context, handler func(event *UserEvent)) error {
+	pubsub := b.client.Subscribe(ctx, redisEventChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var event UserEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("[events] failed to decode event: %v", err)
+				continue
+			}
+			handler(&event)
+		}
+	}
+}
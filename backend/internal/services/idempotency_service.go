@@ -0,0 +1,203 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+const (
+	IdempotencyRetention = 24 * time.Hour
+	// IdempotencyLease is how long a request holds its key before a retry
+	// may assume it died and take the key over. KeepAlive renews it every
+	// idempotencyHeartbeat while the request runs.
+	IdempotencyLease     = time.Minute
+	MaxIdempotencyKeyLen = 255
+
+	idempotencyHeartbeat = IdempotencyLease / 3
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is still in progress")
+	// ErrIdempotencyLeaseLost means another request took the key over, so
+	// the caller's outcome is not stored
+	ErrIdempotencyLeaseLost = errors.New("idempotency key was taken over by another request")
+)
+
+type IdempotencyService interface {
+	Begin(userID uuid.UUID, key, fingerprint string) (record *models.IdempotencyKey, replay bool, err error)
+	// KeepAlive renews the record's lease until the returned stop is called;
+	// stop waits for the renewal to finish, so the record may be completed
+	// or abandoned right after it
+	KeepAlive(record *models.IdempotencyKey) (stop func())
+	Complete(record *models.IdempotencyKey, statusCode int, body []byte) error
+	Abandon(record *models.IdempotencyKey) error
+	// PurgeExpired is run periodically by the worker; see
+	// workers.TypeIdempotencyPurge
+	PurgeExpired() (int64, error)
+}
+
+type idempotencyService struct {
+	repo repository.IdempotencyRepository
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository) IdempotencyService {
+	return &idempotencyService{repo: repo}
+}
+
+// Begin reserves the key for a new request, or returns the stored record with
+// replay set when the same request already completed within the retention
+// window. A record still in progress after its lease ran out is taken over,
+// so a request that crashed mid-way does not block retries for a day.
+func (s *idempotencyService) Begin(userID uuid.UUID, key, fingerprint string) (*models.IdempotencyKey, bool, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, err := s.repo.Find(userID, key)
+		if err != nil && !errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+			return nil, false, err
+		}
+
+		if existing != nil {
+			if time.Now().After(existing.ExpiresAt) {
+				if err := s.repo.Delete(existing.ID); err != nil {
+					return nil, false, err
+				}
+			} else {
+				if existing.Fingerprint != fingerprint {
+					return nil, false, ErrIdempotencyKeyReused
+				}
+				if existing.StatusCode != 0 {
+					return existing, true, nil
+				}
+
+				now := time.Now()
+				if existing.LockedUntil != nil && now.Before(*existing.LockedUntil) {
+					return nil, false, ErrIdempotencyKeyInProgress
+				}
+
+				lockedUntil := leaseFrom(now)
+				taken, err := s.repo.TakeOver(existing.ID, now, lockedUntil)
+				if err != nil {
+					return nil, false, err
+				}
+				if !taken {
+					// Another retry took it over or the request completed;
+					// re-read the record.
+					continue
+				}
+				existing.LockedUntil = &lockedUntil
+				return existing, false, nil
+			}
+		}
+
+		now := time.Now()
+		lockedUntil := leaseFrom(now)
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(IdempotencyRetention),
+			LockedUntil: &lockedUntil,
+		}
+
+		err = s.repo.Create(record)
+		if err == nil {
+			return record, false, nil
+		}
+		if !errors.Is(err, repository.ErrDuplicateIdempotencyKey) {
+			return nil, false, err
+		}
+		// A concurrent request won the insert; re-read its record.
+	}
+
+	return nil, false, ErrIdempotencyKeyInProgress
+}
+
+func (s *idempotencyService) KeepAlive(record *models.IdempotencyKey) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(idempotencyHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				renewed, err := s.renew(record)
+				if err != nil {
+					log.Printf("[idempotency] failed to renew lease of key %d: %v", record.ID, err)
+					continue
+				}
+				if !renewed {
+					log.Printf("[idempotency] lease of key %d was taken over", record.ID)
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// renew extends the lease the record holds, reporting false once another
+// request took the key over.
+func (s *idempotencyService) renew(record *models.IdempotencyKey) (bool, error) {
+	lockedUntil := leaseFrom(time.Now())
+	renewed, err := s.repo.Renew(record.ID, *record.LockedUntil, lockedUntil)
+	if err != nil || !renewed {
+		return false, err
+	}
+	record.LockedUntil = &lockedUntil
+	return true, nil
+}
+
+// Complete stores the response, provided the caller still holds the lease it
+// took in Begin.
+func (s *idempotencyService) Complete(record *models.IdempotencyKey, statusCode int, body []byte) error {
+	completed, err := s.repo.Complete(record.ID, *record.LockedUntil, statusCode, body)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		return ErrIdempotencyLeaseLost
+	}
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	return nil
+}
+
+// Abandon releases the key so that a retry after a server error is processed
+// again instead of replaying the failure. A key another request took over is
+// left to it.
+func (s *idempotencyService) Abandon(record *models.IdempotencyKey) error {
+	released, err := s.repo.Release(record.ID, *record.LockedUntil)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrIdempotencyLeaseLost
+	}
+	return nil
+}
+
+// leaseFrom returns the end of a lease starting at now, truncated to the
+// microsecond precision Postgres stores so it compares equal when read back.
+func leaseFrom(now time.Time) time.Time {
+	return now.Add(IdempotencyLease).Truncate(time.Microsecond)
+}
+
+func (s *idempotencyService) PurgeExpired() (int64, error) {
+	return s.repo.DeleteExpired(time.Now())
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+// fakeIdempotencyRepository mirrors the unique (user_id, key) index and the
+// conditional updates of the Postgres repository.
+type fakeIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[uint]models.IdempotencyKey
+	nextID  uint
+}
+
+func newFakeIdempotencyRepository() *fakeIdempotencyRepository {
+	return &fakeIdempotencyRepository{records: make(map[uint]models.IdempotencyKey)}
+}
+
+func (r *fakeIdempotencyRepository) Create(record *models.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.records {
+		if existing.UserID == record.UserID && existing.Key == record.Key {
+			return repository.ErrDuplicateIdempotencyKey
+		}
+	}
+	r.nextID++
+	record.ID = r.nextID
+	r.records[record.ID] = *record
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) Find(userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.records {
+		if existing.UserID == userID && existing.Key == key {
+			return &existing, nil
+		}
+	}
+	return nil, repository.ErrIdempotencyKeyNotFound
+}
+
+func (r *fakeIdempotencyRepository) TakeOver(id uint, now, lockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.records[id]
+	if !ok || record.StatusCode != 0 || (record.LockedUntil != nil && record.LockedUntil.After(now)) {
+		return false, nil
+	}
+	record.LockedUntil = &lockedUntil
+	r.records[id] = record
+	return true, nil
+}
+
+// holds reports whether the record is in progress under the lease held.
+// Callers hold r.mu.
+func (r *fakeIdempotencyRepository) holds(id uint, held time.Time) bool {
+	record, ok := r.records[id]
+	return ok && record.StatusCode == 0 && record.LockedUntil != nil && record.LockedUntil.Equal(held)
+}
+
+func (r *fakeIdempotencyRepository) Renew(id uint, held, lockedUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.holds(id, held) {
+		return false, nil
+	}
+	record := r.records[id]
+	record.LockedUntil = &lockedUntil
+	r.records[id] = record
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepository) Complete(id uint, held time.Time, statusCode int, body []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.holds(id, held) {
+		return false, nil
+	}
+	record := r.records[id]
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	r.records[id] = record
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepository) Release(id uint, held time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.holds(id, held) {
+		return false, nil
+	}
+	delete(r.records, id)
+	return true, nil
+}
+
+func (r *fakeIdempotencyRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.records, id)
+	return nil
+}
+
+func (r *fakeIdempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// expireLease moves the record's lease into the past, as if its request
+// died a while ago.
+func (r *fakeIdempotencyRepository) expireLease(id uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := r.records[id]
+	past := time.Now().Add(-time.Second)
+	record.LockedUntil = &past
+	r.records[id] = record
+}
+
+func TestIdempotencyBegin(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("in progress within the lease", func(t *testing.T) {
+		svc := NewIdempotencyService(newFakeIdempotencyRepository())
+		if _, replay, err := svc.Begin(userID, "k", "fp"); err != nil || replay {
+			t.Fatalf("first Begin = replay %v, err %v", replay, err)
+		}
+		if _, _, err := svc.Begin(userID, "k", "fp"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+			t.Fatalf("second Begin error = %v, want %v", err, ErrIdempotencyKeyInProgress)
+		}
+	})
+
+	t.Run("expired lease is taken over once", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo)
+		first, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(first.ID)
+
+		second, replay, err := svc.Begin(userID, "k", "fp")
+		if err != nil || replay {
+			t.Fatalf("Begin after lease expiry = replay %v, err %v", replay, err)
+		}
+		if second.ID != first.ID {
+			t.Errorf("took over record %d, want %d", second.ID, first.ID)
+		}
+		if second.LockedUntil == nil || !second.LockedUntil.After(time.Now()) {
+			t.Errorf("lease not renewed: %v", second.LockedUntil)
+		}
+		if _, _, err := svc.Begin(userID, "k", "fp"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+			t.Fatalf("Begin during renewed lease error = %v, want %v", err, ErrIdempotencyKeyInProgress)
+		}
+	})
+
+	t.Run("expired lease with a different request", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo)
+		first, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(first.ID)
+
+		if _, _, err := svc.Begin(userID, "k", "other"); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Fatalf("Begin error = %v, want %v", err, ErrIdempotencyKeyReused)
+		}
+	})
+
+	t.Run("completed request replays", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo)
+		record, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.Complete(record, 202, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(record.ID)
+
+		replayed, replay, err := svc.Begin(userID, "k", "fp")
+		if err != nil || !replay {
+			t.Fatalf("Begin = replay %v, err %v", replay, err)
+		}
+		if replayed.StatusCode != 202 {
+			t.Errorf("replayed status = %d, want 202", replayed.StatusCode)
+		}
+	})
+}
+
+func TestIdempotencyLease(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("request that lost its lease cannot complete", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo)
+		first, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(first.ID)
+
+		second, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.Complete(second, 202, []byte(`"second"`)); err != nil {
+			t.Fatalf("Complete of the holder: %v", err)
+		}
+		if err := svc.Complete(first, 202, []byte(`"first"`)); !errors.Is(err, ErrIdempotencyLeaseLost) {
+			t.Fatalf("Complete of the superseded request error = %v, want %v", err, ErrIdempotencyLeaseLost)
+		}
+		if err := svc.Abandon(first); !errors.Is(err, ErrIdempotencyLeaseLost) {
+			t.Fatalf("Abandon of the superseded request error = %v, want %v", err, ErrIdempotencyLeaseLost)
+		}
+
+		stored := repo.records[first.ID]
+		if string(stored.ResponseBody) != `"second"` {
+			t.Errorf("stored response = %s, want the holder's", stored.ResponseBody)
+		}
+	})
+
+	t.Run("renewed lease is not taken over", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo).(*idempotencyService)
+		record, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(record.ID)
+		past := *repo.records[record.ID].LockedUntil
+		record.LockedUntil = &past
+
+		if renewed, err := svc.renew(record); err != nil || !renewed {
+			t.Fatalf("renew = %v, %v", renewed, err)
+		}
+		if _, _, err := svc.Begin(userID, "k", "fp"); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+			t.Fatalf("Begin after renewal error = %v, want %v", err, ErrIdempotencyKeyInProgress)
+		}
+		if err := svc.Complete(record, 202, nil); err != nil {
+			t.Fatalf("Complete after renewal: %v", err)
+		}
+	})
+
+	t.Run("renewal stops after a takeover", func(t *testing.T) {
+		repo := newFakeIdempotencyRepository()
+		svc := NewIdempotencyService(repo).(*idempotencyService)
+		first, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expireLease(first.ID)
+		if _, _, err := svc.Begin(userID, "k", "fp"); err != nil {
+			t.Fatal(err)
+		}
+
+		if renewed, err := svc.renew(first); err != nil || renewed {
+			t.Fatalf("renew of the superseded request = %v, %v; want false", renewed, err)
+		}
+	})
+
+	t.Run("stop returns once the renewal ends", func(t *testing.T) {
+		svc := NewIdempotencyService(newFakeIdempotencyRepository())
+		record, _, err := svc.Begin(userID, "k", "fp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := svc.KeepAlive(record)
+		stop()
+		if err := svc.Complete(record, 202, nil); err != nil {
+			t.Fatalf("Complete after stop: %v", err)
+		}
+	})
+}
+
+func TestIdempotencyPurgeExpired(t *testing.T) {
+	repo := newFakeIdempotencyRepository()
+	svc := NewIdempotencyService(repo)
+
+	now := time.Now()
+	repo.Create(&models.IdempotencyKey{UserID: uuid.New(), Key: "old", ExpiresAt: now.Add(-time.Minute)})
+	repo.Create(&models.IdempotencyKey{UserID: uuid.New(), Key: "live", ExpiresAt: now.Add(time.Hour)})
+
+	purged, err := svc.PurgeExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 || len(repo.records) != 1 {
+		t.Errorf("purged %d, %d left; want 1 purged, 1 left", purged, len(repo.records))
+	}
+}
@@ -12,10 +12,33 @@ import (
 
 var (
 	ErrLogNotFound = errors.New("message log not found")
+	// ErrDuplicateEvent is returned together with the log already stored for
+	// a device event that was replayed
+	ErrDuplicateEvent = errors.New("device event already recorded")
 )
 
+type InboundSMSInput struct {
+	EventID string
+	Sender  string
+	Content string
+	SimSlot int
+}
+
+type NotificationLogInput struct {
+	EventID    string
+	AppPackage string
+	AppName    string
+	Title      string
+	Text       string
+	Category   string
+}
+
 type LogService interface {
 	Create(userID uuid.UUID, deviceID *uuid.UUID, direction models.MessageDirection, sender, receiver, content string, simSlot int) (*models.MessageLog, error)
+	// CreateInboundSMS and CreateNotification are idempotent on the event
+	// ID: a replay returns the stored log with ErrDuplicateEvent
+	CreateInboundSMS(userID, deviceID uuid.UUID, input *InboundSMSInput) (*models.MessageLog, error)
+	CreateNotification(userID uuid.UUID, deviceID *uuid.UUID, input *NotificationLogInput) (*models.MessageLog, error)
 	GetByID(id uint, userID uuid.UUID) (*models.MessageLog, error)
 	List(userID uuid.UUID, params *dto.LogQueryParams) (*dto.PaginatedLogs, error)
 	GetStats(userID uuid.UUID, params *dto.StatsQueryParams) (*dto.LogStats, error)
@@ -35,6 +58,7 @@ func (s *logService) Create(userID uuid.UUID, deviceID *uuid.UUID, direction mod
 	log := &models.MessageLog{
 		UserID:    userID,
 		DeviceID:  deviceID,
+		Type:      models.MessageTypeSMS,
 		Direction: direction,
 		Sender:    sender,
 		Receiver:  receiver,
@@ -50,6 +74,64 @@ func (s *logService) Create(userID uuid.UUID, deviceID *uuid.UUID, direction mod
 	return log, nil
 }
 
+func (s *logService) CreateInboundSMS(userID, deviceID uuid.UUID, input *InboundSMSInput) (*models.MessageLog, error) {
+	log := &models.MessageLog{
+		UserID:    userID,
+		DeviceID:  &deviceID,
+		Type:      models.MessageTypeSMS,
+		Direction: models.DirectionInbound,
+		Sender:    input.Sender,
+		Content:   input.Content,
+		SimSlot:   input.SimSlot,
+		Status:    models.StatusPending,
+		EventID:   eventID(input.EventID),
+	}
+
+	return s.createEvent(log)
+}
+
+func (s *logService) CreateNotification(userID uuid.UUID, deviceID *uuid.UUID, input *NotificationLogInput) (*models.MessageLog, error) {
+	log := &models.MessageLog{
+		UserID:     userID,
+		DeviceID:   deviceID,
+		Type:       models.MessageTypeNotification,
+		Direction:  models.DirectionInbound,
+		Sender:     truncate(input.AppName, 50),
+		Content:    input.Text,
+		Status:     models.StatusPending,
+		AppPackage: input.AppPackage,
+		AppName:    input.AppName,
+		Title:      input.Title,
+		Category:   input.Category,
+		EventID:    eventID(input.EventID),
+	}
+
+	return s.createEvent(log)
+}
+
+func (s *logService) createEvent(log *models.MessageLog) (*models.MessageLog, error) {
+	err := s.repo.Create(log)
+	if errors.Is(err, repository.ErrDuplicateEvent) {
+		existing, findErr := s.repo.FindByEventID(*log.DeviceID, *log.EventID)
+		if findErr != nil {
+			return nil, findErr
+		}
+		return existing, ErrDuplicateEvent
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+func eventID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
 func (s *logService) GetByID(id uint, userID uuid.UUID) (*models.MessageLog, error) {
 	log, err := s.repo.FindByID(id)
 	if err != nil {
@@ -104,3 +186,11 @@ func (s *logService) IncrementRetry(id uint) error {
 	}
 	return err
 }
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
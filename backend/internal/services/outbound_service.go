@@ -0,0 +1,637 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+const (
+	DefaultOutboundTTL = 24 * time.Hour
+	MaxOutboundTTL     = 72 * time.Hour
+
+	MaxFailoverAttempts = 5
+
+	// SMSAckTimeout is how long a dispatched SEND_SMS waits for the device's
+	// acknowledgement, across reconnects, before the hop fails over
+	SMSAckTimeout = 5 * time.Minute
+
+	// failoverCooldown keeps a device that just failed a send out of the
+	// failover candidates for a while
+	failoverCooldown = 10 * time.Minute
+)
+
+var (
+	ErrInvalidTTL         = errors.New("invalid ttl")
+	ErrInvalidMaxAttempts = errors.New("invalid max attempts")
+
+	errNotClaimed = errors.New("queued message already claimed")
+)
+
+// SMSGateway is the slice of the device hub the outbound queue needs. It is
+// declared here so services does not depend on the websockets package.
+type SMSGateway interface {
+	GetDeviceStatus(deviceID uuid.UUID) bool
+	GetOnlineDevices(userID uuid.UUID) []uuid.UUID
+	// DispatchSMS sends the message; the device must acknowledge it before
+	// expiresAt or the send is reported unacknowledged
+	DispatchSMS(deviceID uuid.UUID, requestID, phone, content string, simSlot int, expiresAt time.Time) error
+}
+
+// StatusListener is notified after every status transition of an outbound
+// message, including the final sent/failed/expired state.
+type StatusListener func(log *models.MessageLog)
+
+type SendRequest struct {
+	Phone    string
+	Content  string
+	DeviceID *uuid.UUID
+	SimSlot  int
+	TTL      time.Duration
+	Strategy string
+
+	// StatusCallbackURL overrides the user's default callback destination
+	StatusCallbackURL string
+
+	// CampaignID is set for messages produced by a bulk send
+	CampaignID *uuid.UUID
+
+	// MaxAttempts is the number of device/SIM hops allowed before the
+	// message is reported failed; 0 means a single attempt
+	MaxAttempts int
+}
+
+type OutboundService interface {
+	Send(userID uuid.UUID, req *SendRequest) (*models.MessageLog, error)
+	FlushDevice(userID, deviceID uuid.UUID) (int, error)
+	GetByRequestID(userID uuid.UUID, requestID string) (*models.MessageLog, error)
+	// MarkSent, MarkDelivered and MarkFailed apply a status reported by
+	// deviceID. Only the device the message is currently assigned to can
+	// report on it.
+	MarkSent(userID, deviceID uuid.UUID, requestID string) error
+	MarkDelivered(userID, deviceID uuid.UUID, requestID string, deliveredAt time.Time) error
+	MarkFailed(userID, deviceID uuid.UUID, requestID string, simSlot *int, reason string) error
+	MarkUnacknowledged(requestID string, deviceID uuid.UUID, reason string) error
+	// ExpireStale is run periodically by the worker; see
+	// workers.TypeOutboundExpiry
+	ExpireStale() (int, error)
+	CancelCampaign(campaignID uuid.UUID) (int, error)
+	AddStatusListener(listener StatusListener)
+}
+
+type outboundService struct {
+	repo       repository.LogRepository
+	attempts   repository.DeliveryAttemptRepository
+	deviceRepo repository.DeviceRepository
+	suppressed repository.SuppressionRepository
+	gateway    SMSGateway
+	router     RoutingService
+	mu         sync.RWMutex
+	listeners  []StatusListener
+	cooldown   map[uuid.UUID]time.Time
+}
+
+func NewOutboundService(
+	repo repository.LogRepository,
+	attempts repository.DeliveryAttemptRepository,
+	deviceRepo repository.DeviceRepository,
+	suppressed repository.SuppressionRepository,
+	gateway SMSGateway,
+	router RoutingService,
+) OutboundService {
+	return &outboundService{
+		repo:       repo,
+		attempts:   attempts,
+		deviceRepo: deviceRepo,
+		suppressed: suppressed,
+		gateway:    gateway,
+		router:     router,
+		cooldown:   make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Send persists the message as a queued job and hands it to a device right
+// away when one is online. Otherwise it stays queued until FlushDevice runs
+// for a matching device or its TTL elapses. Recipients on the user's
+// suppression list are refused with ErrRecipientSuppressed.
+func (s *outboundService) Send(userID uuid.UUID, req *SendRequest) (*models.MessageLog, error) {
+	suppressed, err := s.suppressed.IsSuppressed(userID, req.Phone)
+	if err != nil {
+		return nil, err
+	}
+	if suppressed {
+		return nil, ErrRecipientSuppressed
+	}
+
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = DefaultOutboundTTL
+	}
+	if ttl < 0 || ttl > MaxOutboundTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	maxAttempts := req.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = 1
+	}
+	if maxAttempts < 1 || maxAttempts > MaxFailoverAttempts {
+		return nil, ErrInvalidMaxAttempts
+	}
+
+	target, simSlot, online, err := s.route(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	info := sms.Analyze(req.Content)
+	expiresAt := time.Now().Add(ttl)
+	msgLog := &models.MessageLog{
+		UserID:    userID,
+		DeviceID:  req.DeviceID,
+		RequestID: uuid.New().String(),
+		Type:      models.MessageTypeSMS,
+		Direction: models.DirectionOutbound,
+		Receiver:  req.Phone,
+		Content:   req.Content,
+		Encoding:  string(info.Encoding),
+		Segments:  info.Segments,
+		SimSlot:   simSlot,
+		Status:    models.StatusQueued,
+		ExpiresAt: &expiresAt,
+
+		MaxAttempts: maxAttempts,
+		Pinned:      req.DeviceID != nil,
+		CampaignID:  req.CampaignID,
+
+		StatusCallbackURL: req.StatusCallbackURL,
+	}
+
+	if err := s.repo.Create(msgLog); err != nil {
+		return nil, err
+	}
+	s.notify(msgLog)
+
+	if !online {
+		return msgLog, nil
+	}
+
+	if err := s.dispatch(msgLog, target); err != nil && !errors.Is(err, errNotClaimed) {
+		log.Printf("[outbound] log_id=%d stays queued: %v", msgLog.ID, err)
+	}
+
+	return msgLog, nil
+}
+
+// FlushDevice dispatches every queued message the device may send, oldest
+// first. It is called once a device has registered with the hub.
+func (s *outboundService) FlushDevice(userID, deviceID uuid.UUID) (int, error) {
+	logs, err := s.repo.FindQueued(userID, deviceID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	flushed := 0
+	for i := range logs {
+		err := s.dispatch(&logs[i], deviceID)
+		if errors.Is(err, errNotClaimed) {
+			continue
+		}
+		if err != nil {
+			log.Printf("[outbound] stopped flushing device %s: %v", deviceID, err)
+			break
+		}
+		flushed++
+	}
+
+	if flushed > 0 {
+		log.Printf("[outbound] flushed %d queued messages to device %s", flushed, deviceID)
+	}
+	return flushed, nil
+}
+
+func (s *outboundService) GetByRequestID(userID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	msgLog, err := s.repo.FindOutboundByRequestID(userID, requestID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+
+	attempts, err := s.attempts.FindByLogID(msgLog.ID)
+	if err != nil {
+		return nil, err
+	}
+	msgLog.Attempts = attempts
+
+	return msgLog, nil
+}
+
+func (s *outboundService) MarkSent(userID, deviceID uuid.UUID, requestID string) error {
+	msgLog, err := s.findOnDevice(userID, deviceID, requestID)
+	if err != nil {
+		return err
+	}
+	return s.transition(msgLog.ID, models.StatusSent, "", time.Now())
+}
+
+// MarkDelivered records the carrier delivery report for a sent message at the
+// time the device reported, or now when it did not report one.
+func (s *outboundService) MarkDelivered(userID, deviceID uuid.UUID, requestID string, deliveredAt time.Time) error {
+	msgLog, err := s.findOnDevice(userID, deviceID, requestID)
+	if err != nil {
+		return err
+	}
+	if deliveredAt.IsZero() {
+		deliveredAt = time.Now()
+	}
+	return s.transition(msgLog.ID, models.StatusDelivered, "", deliveredAt)
+}
+
+// MarkFailed records a failed hop. While the message has attempts left it is
+// handed to another online device or SIM under the same request ID; only the
+// last failure is reported as final. Reports from a hop the message already
+// left, such as one failure per part of a multipart SMS or a late report
+// after a failover, are ignored. simSlot is nil when the device did not
+// report which SIM failed.
+func (s *outboundService) MarkFailed(userID, deviceID uuid.UUID, requestID string, simSlot *int, reason string) error {
+	msgLog, err := s.findOnDevice(userID, deviceID, requestID)
+	if err != nil {
+		return err
+	}
+
+	if !isCurrentHop(msgLog, deviceID, simSlot) {
+		return nil
+	}
+	return s.failHop(msgLog, reason)
+}
+
+// findOnDevice loads an outbound message of the user while deviceID holds it.
+func (s *outboundService) findOnDevice(userID, deviceID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	msgLog, err := s.repo.FindOutboundOnDevice(userID, deviceID, requestID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return nil, ErrLogNotFound
+		}
+		return nil, err
+	}
+	return msgLog, nil
+}
+
+// isCurrentHop reports whether the message is still assigned to the device
+// and SIM a report came from.
+func isCurrentHop(msgLog *models.MessageLog, deviceID uuid.UUID, simSlot *int) bool {
+	if msgLog.DeviceID == nil || *msgLog.DeviceID != deviceID {
+		return false
+	}
+	return simSlot == nil || *simSlot == msgLog.SimSlot
+}
+
+// failHop fails the message's current hop and fails over while attempts are
+// left.
+func (s *outboundService) failHop(msgLog *models.MessageLog, reason string) error {
+	if msgLog.Status == models.StatusPending && pastExpiry(msgLog, time.Now()) {
+		return s.transition(msgLog.ID, models.StatusExpired, "expired before it was sent", time.Now())
+	}
+	if msgLog.Status != models.StatusPending || msgLog.RetryCount+1 >= msgLog.MaxAttempts {
+		return s.transition(msgLog.ID, models.StatusFailed, reason, time.Now())
+	}
+
+	if msgLog.DeviceID != nil {
+		s.coolDown(*msgLog.DeviceID)
+	}
+	if err := s.attempts.CompleteLatest(msgLog.ID, models.StatusFailed, reason); err != nil {
+		log.Printf("[outbound] failed to record attempt for log_id=%d: %v", msgLog.ID, err)
+	}
+
+	return s.failOver(msgLog, reason)
+}
+
+// failOver hands a message whose hop failed to the next device or SIM. A hop
+// the gateway cannot reach counts as a failed attempt too, and the next
+// candidate is tried until the attempts or the candidates run out.
+func (s *outboundService) failOver(msgLog *models.MessageLog, reason string) error {
+	for msgLog.RetryCount+1 < msgLog.MaxAttempts {
+		deviceID, simSlot, ok := s.nextHop(msgLog)
+		if !ok {
+			break
+		}
+
+		if err := s.repo.Reassign(msgLog.ID, deviceID, simSlot); err != nil {
+			if errors.Is(err, repository.ErrLogNotFound) {
+				// A late report already moved the message on
+				return nil
+			}
+			return err
+		}
+
+		msgLog.RetryCount++
+		msgLog.DeviceID = &deviceID
+		msgLog.SimSlot = simSlot
+		s.recordAttempt(msgLog)
+
+		log.Printf("[outbound] log_id=%d failing over to device %s sim %d (attempt %d/%d): %s",
+			msgLog.ID, deviceID, simSlot, msgLog.RetryCount+1, msgLog.MaxAttempts, reason)
+
+		err := s.gateway.DispatchSMS(deviceID, msgLog.RequestID, msgLog.Receiver, msgLog.Content, simSlot, ackDeadline(msgLog))
+		if err == nil {
+			msgLog, err = s.repo.FindByID(msgLog.ID)
+			if err != nil {
+				return err
+			}
+			s.notify(msgLog)
+			return nil
+		}
+
+		reason = err.Error()
+		s.coolDown(deviceID)
+		if err := s.attempts.CompleteLatest(msgLog.ID, models.StatusFailed, reason); err != nil {
+			log.Printf("[outbound] failed to record attempt for log_id=%d: %v", msgLog.ID, err)
+		}
+	}
+
+	return s.transition(msgLog.ID, models.StatusFailed, reason, time.Now())
+}
+
+// MarkUnacknowledged fails the hop of a message whose SEND_SMS the device
+// never acknowledged. Messages that already moved on, through a status
+// report or a failover to another device, are left alone.
+func (s *outboundService) MarkUnacknowledged(requestID string, deviceID uuid.UUID, reason string) error {
+	msgLog, err := s.repo.FindByRequestID(requestID)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return ErrLogNotFound
+		}
+		return err
+	}
+
+	if msgLog.Status != models.StatusPending || !isCurrentHop(msgLog, deviceID, nil) {
+		return nil
+	}
+	return s.failHop(msgLog, reason)
+}
+
+func (s *outboundService) ExpireStale() (int, error) {
+	expired, err := s.repo.ExpireQueued(time.Now())
+	for i := range expired {
+		s.notify(&expired[i])
+	}
+	return len(expired), err
+}
+
+// CancelCampaign fails every message of the campaign still waiting in the
+// queue and reports it to the status listeners.
+func (s *outboundService) CancelCampaign(campaignID uuid.UUID) (int, error) {
+	cancelled, err := s.repo.CancelQueuedByCampaign(campaignID, "campaign cancelled")
+	for i := range cancelled {
+		s.notify(&cancelled[i])
+	}
+	return len(cancelled), err
+}
+
+func (s *outboundService) AddStatusListener(listener StatusListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// route decides which device and SIM should send the message. online is false
+// when the message has to wait in the queue for a device to connect.
+func (s *outboundService) route(userID uuid.UUID, req *SendRequest) (uuid.UUID, int, bool, error) {
+	if req.DeviceID != nil {
+		return *req.DeviceID, req.SimSlot, s.gateway.GetDeviceStatus(*req.DeviceID), nil
+	}
+
+	candidates := s.gateway.GetOnlineDevices(userID)
+	if len(candidates) == 0 {
+		return uuid.Nil, req.SimSlot, false, nil
+	}
+
+	decision, err := s.router.Select(req.Strategy, userID, req.Phone, candidates)
+	if err != nil {
+		return uuid.Nil, 0, false, err
+	}
+
+	simSlot := req.SimSlot
+	if decision.SimSlot != nil {
+		simSlot = *decision.SimSlot
+	}
+	return decision.DeviceID, simSlot, true, nil
+}
+
+// dispatch claims a queued message for the device and sends it. A message the
+// device could not accept is put back in the queue.
+func (s *outboundService) dispatch(msgLog *models.MessageLog, deviceID uuid.UUID) error {
+	claimed, err := s.repo.ClaimQueued(msgLog.ID, deviceID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errNotClaimed
+	}
+
+	if err := s.gateway.DispatchSMS(deviceID, msgLog.RequestID, msgLog.Receiver, msgLog.Content, msgLog.SimSlot, ackDeadline(msgLog)); err != nil {
+		if requeueErr := s.repo.Requeue(msgLog.ID, msgLog.DeviceID); requeueErr != nil {
+			log.Printf("[outbound] failed to requeue log_id=%d: %v", msgLog.ID, requeueErr)
+		}
+		return err
+	}
+
+	now := time.Now()
+	msgLog.Status = models.StatusPending
+	msgLog.DeviceID = &deviceID
+	msgLog.DispatchedAt = &now
+	s.recordAttempt(msgLog)
+	s.notify(msgLog)
+	return nil
+}
+
+// ackDeadline bounds how long the device may take to acknowledge a SEND_SMS:
+// SMSAckTimeout, but never past the message's own expiry, so a reconnecting
+// device is not handed a message that already expired.
+func ackDeadline(msgLog *models.MessageLog) time.Time {
+	deadline := time.Now().Add(SMSAckTimeout)
+	if msgLog.ExpiresAt != nil && msgLog.ExpiresAt.Before(deadline) {
+		return *msgLog.ExpiresAt
+	}
+	return deadline
+}
+
+func pastExpiry(msgLog *models.MessageLog, now time.Time) bool {
+	return msgLog.ExpiresAt != nil && !now.Before(*msgLog.ExpiresAt)
+}
+
+func (s *outboundService) recordAttempt(msgLog *models.MessageLog) {
+	err := s.attempts.Create(&models.DeliveryAttempt{
+		LogID:    msgLog.ID,
+		Attempt:  msgLog.RetryCount + 1,
+		DeviceID: *msgLog.DeviceID,
+		SimSlot:  msgLog.SimSlot,
+		Status:   models.StatusPending,
+	})
+	if err != nil {
+		log.Printf("[outbound] failed to record attempt for log_id=%d: %v", msgLog.ID, err)
+	}
+}
+
+// nextHop picks where a failed message goes next. The other SIM of the same
+// device is preferred for messages pinned to a device; otherwise any online
+// device that has not failed recently and has not been tried for this message.
+func (s *outboundService) nextHop(msgLog *models.MessageLog) (uuid.UUID, int, bool) {
+	tried, err := s.attempts.FindByLogID(msgLog.ID)
+	if err != nil {
+		log.Printf("[outbound] failed to load attempts for log_id=%d: %v", msgLog.ID, err)
+		return uuid.Nil, 0, false
+	}
+
+	triedDevices := make(map[uuid.UUID]bool)
+	triedSims := make(map[uuid.UUID]map[int]bool)
+	for _, attempt := range tried {
+		triedDevices[attempt.DeviceID] = true
+		if triedSims[attempt.DeviceID] == nil {
+			triedSims[attempt.DeviceID] = make(map[int]bool)
+		}
+		triedSims[attempt.DeviceID][attempt.SimSlot] = true
+	}
+
+	if msgLog.DeviceID != nil {
+		if slot, ok := s.untriedSim(*msgLog.DeviceID, triedSims[*msgLog.DeviceID]); ok {
+			return *msgLog.DeviceID, slot, true
+		}
+	}
+
+	// Messages pinned to a device by the caller never leave it
+	if msgLog.Pinned {
+		return uuid.Nil, 0, false
+	}
+
+	var candidates []uuid.UUID
+	for _, id := range s.gateway.GetOnlineDevices(msgLog.UserID) {
+		if triedDevices[id] || s.coolingDown(id) {
+			continue
+		}
+		candidates = append(candidates, id)
+	}
+	if len(candidates) == 0 {
+		return uuid.Nil, 0, false
+	}
+
+	decision, err := s.router.Select(models.RoutingLeastLoaded, msgLog.UserID, msgLog.Receiver, candidates)
+	if err != nil {
+		log.Printf("[outbound] failover routing for log_id=%d failed: %v", msgLog.ID, err)
+		return uuid.Nil, 0, false
+	}
+
+	simSlot := msgLog.SimSlot
+	if decision.SimSlot != nil {
+		simSlot = *decision.SimSlot
+	}
+	return decision.DeviceID, simSlot, true
+}
+
+// untriedSim returns a SIM slot of the device the message has not been sent
+// from yet, for dual-SIM phones that are still online.
+func (s *outboundService) untriedSim(deviceID uuid.UUID, tried map[int]bool) (int, bool) {
+	if !s.gateway.GetDeviceStatus(deviceID) {
+		return 0, false
+	}
+
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err != nil {
+		return 0, false
+	}
+
+	for slot := 0; slot < device.SimCount; slot++ {
+		if !tried[slot] {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+func (s *outboundService) coolDown(deviceID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cooldown[deviceID] = now.Add(failoverCooldown)
+	for id, until := range s.cooldown {
+		if now.After(until) {
+			delete(s.cooldown, id)
+		}
+	}
+}
+
+func (s *outboundService) coolingDown(deviceID uuid.UUID) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	until, ok := s.cooldown[deviceID]
+	return ok && time.Now().Before(until)
+}
+
+// transition applies a device-reported status reached at the given time.
+// Reports can arrive out of order (a delivery receipt racing SMS_SENT), so a
+// message never moves back to an earlier stage.
+func (s *outboundService) transition(id uint, status models.MessageStatus, errorMsg string, at time.Time) error {
+	msgLog, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return ErrLogNotFound
+		}
+		return err
+	}
+
+	if statusRank(status) <= statusRank(msgLog.Status) {
+		return nil
+	}
+
+	if err := s.repo.UpdateStatusAt(msgLog.ID, status, errorMsg, at); err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return ErrLogNotFound
+		}
+		return err
+	}
+	if err := s.attempts.CompleteLatest(msgLog.ID, status, errorMsg); err != nil {
+		log.Printf("[outbound] failed to record attempt for log_id=%d: %v", msgLog.ID, err)
+	}
+
+	msgLog, err = s.repo.FindByID(msgLog.ID)
+	if err != nil {
+		return err
+	}
+	s.notify(msgLog)
+	return nil
+}
+
+func statusRank(status models.MessageStatus) int {
+	switch status {
+	case models.StatusQueued:
+		return 0
+	case models.StatusPending:
+		return 1
+	case models.StatusSent:
+		return 2
+	case models.StatusDelivered, models.StatusFailed, models.StatusExpired:
+		return 3
+	default:
+		return -1
+	}
+}
+
+func (s *outboundService) notify(msgLog *models.MessageLog) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(msgLog)
+	}
+}
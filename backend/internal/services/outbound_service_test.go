@@ -0,0 +1,556 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+// fakeLogRepository keeps message logs in memory. Methods the tests do not
+// reach are left to the embedded nil interface and panic if called.
+type fakeLogRepository struct {
+	repository.LogRepository
+
+	mu   sync.Mutex
+	logs map[uint]*models.MessageLog
+}
+
+func newFakeLogRepository(logs ...*models.MessageLog) *fakeLogRepository {
+	r := &fakeLogRepository{logs: make(map[uint]*models.MessageLog)}
+	for _, msgLog := range logs {
+		r.logs[msgLog.ID] = msgLog
+	}
+	return r
+}
+
+func (r *fakeLogRepository) FindByID(id uint) (*models.MessageLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msgLog, ok := r.logs[id]
+	if !ok {
+		return nil, repository.ErrLogNotFound
+	}
+	copied := *msgLog
+	return &copied, nil
+}
+
+func (r *fakeLogRepository) FindByRequestID(requestID string) (*models.MessageLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msgLog := range r.logs {
+		if msgLog.RequestID == requestID {
+			copied := *msgLog
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrLogNotFound
+}
+
+func (r *fakeLogRepository) FindOutboundByRequestID(userID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msgLog := range r.logs {
+		if msgLog.UserID == userID && msgLog.RequestID == requestID && msgLog.Direction == models.DirectionOutbound {
+			copied := *msgLog
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrLogNotFound
+}
+
+func (r *fakeLogRepository) FindOutboundOnDevice(userID, deviceID uuid.UUID, requestID string) (*models.MessageLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msgLog := range r.logs {
+		if msgLog.UserID == userID && msgLog.DeviceID != nil && *msgLog.DeviceID == deviceID &&
+			msgLog.RequestID == requestID && msgLog.Direction == models.DirectionOutbound {
+			copied := *msgLog
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrLogNotFound
+}
+
+func (r *fakeLogRepository) UpdateStatusAt(id uint, status models.MessageStatus, errorMsg string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msgLog, ok := r.logs[id]
+	if !ok {
+		return repository.ErrLogNotFound
+	}
+	msgLog.Status = status
+	if errorMsg != "" {
+		msgLog.ErrorMessage = errorMsg
+	}
+	switch status {
+	case models.StatusSent:
+		msgLog.SentAt = &at
+	case models.StatusDelivered:
+		msgLog.DeliveredAt = &at
+	}
+	return nil
+}
+
+func (r *fakeLogRepository) Reassign(id uint, deviceID uuid.UUID, simSlot int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msgLog, ok := r.logs[id]
+	if !ok || msgLog.Status != models.StatusPending {
+		return repository.ErrLogNotFound
+	}
+	msgLog.DeviceID = &deviceID
+	msgLog.SimSlot = simSlot
+	msgLog.RetryCount++
+	return nil
+}
+
+type fakeAttemptRepository struct {
+	mu       sync.Mutex
+	attempts []models.DeliveryAttempt
+}
+
+func (r *fakeAttemptRepository) Create(attempt *models.DeliveryAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *fakeAttemptRepository) FindByLogID(logID uint) ([]models.DeliveryAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []models.DeliveryAttempt
+	for _, attempt := range r.attempts {
+		if attempt.LogID == logID {
+			found = append(found, attempt)
+		}
+	}
+	return found, nil
+}
+
+func (r *fakeAttemptRepository) CompleteLatest(logID uint, status models.MessageStatus, errorMsg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest := -1
+	for i, attempt := range r.attempts {
+		if attempt.LogID == logID && (latest < 0 || attempt.Attempt > r.attempts[latest].Attempt) {
+			latest = i
+		}
+	}
+	if latest >= 0 {
+		r.attempts[latest].Status = status
+		r.attempts[latest].Error = errorMsg
+	}
+	return nil
+}
+
+// fakeDeviceRepository reports every device as a single-SIM phone.
+type fakeDeviceRepository struct {
+	repository.DeviceRepository
+}
+
+func (r *fakeDeviceRepository) FindByID(id uuid.UUID) (*models.Device, error) {
+	return &models.Device{ID: id, SimCount: 1}, nil
+}
+
+// fakeGateway lists online devices in order and refuses dispatches to the
+// unreachable ones.
+type fakeGateway struct {
+	mu          sync.Mutex
+	online      []uuid.UUID
+	unreachable map[uuid.UUID]bool
+	dispatched  []uuid.UUID
+	deadlines   []time.Time
+}
+
+func (g *fakeGateway) GetDeviceStatus(deviceID uuid.UUID) bool {
+	for _, id := range g.online {
+		if id == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *fakeGateway) GetOnlineDevices(userID uuid.UUID) []uuid.UUID {
+	return g.online
+}
+
+func (g *fakeGateway) DispatchSMS(deviceID uuid.UUID, requestID, phone, content string, simSlot int, expiresAt time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dispatched = append(g.dispatched, deviceID)
+	g.deadlines = append(g.deadlines, expiresAt)
+	if g.unreachable[deviceID] {
+		return errors.New("device not connected")
+	}
+	return nil
+}
+
+// fakeRouter picks the first candidate.
+type fakeRouter struct {
+	RoutingService
+}
+
+func (r *fakeRouter) Select(strategy string, userID uuid.UUID, phone string, candidates []uuid.UUID) (*RouteDecision, error) {
+	return &RouteDecision{DeviceID: candidates[0]}, nil
+}
+
+func TestMarkFailedFailover(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		unreachable  []uuid.UUID
+		wantStatus   models.MessageStatus
+		wantDevice   uuid.UUID
+		wantDispatch []uuid.UUID
+		wantAttempts []models.MessageStatus
+	}{
+		{
+			name:         "next device takes over",
+			maxAttempts:  3,
+			wantStatus:   models.StatusPending,
+			wantDevice:   b,
+			wantDispatch: []uuid.UUID{b},
+			wantAttempts: []models.MessageStatus{models.StatusFailed, models.StatusPending},
+		},
+		{
+			name:         "unreachable failover device is skipped",
+			maxAttempts:  3,
+			unreachable:  []uuid.UUID{b},
+			wantStatus:   models.StatusPending,
+			wantDevice:   c,
+			wantDispatch: []uuid.UUID{b, c},
+			wantAttempts: []models.MessageStatus{models.StatusFailed, models.StatusFailed, models.StatusPending},
+		},
+		{
+			name:         "unreachable failover device uses up the last attempt",
+			maxAttempts:  2,
+			unreachable:  []uuid.UUID{b},
+			wantStatus:   models.StatusFailed,
+			wantDevice:   b,
+			wantDispatch: []uuid.UUID{b},
+			wantAttempts: []models.MessageStatus{models.StatusFailed, models.StatusFailed},
+		},
+		{
+			name:         "every failover device unreachable",
+			maxAttempts:  5,
+			unreachable:  []uuid.UUID{b, c},
+			wantStatus:   models.StatusFailed,
+			wantDevice:   c,
+			wantDispatch: []uuid.UUID{b, c},
+			wantAttempts: []models.MessageStatus{models.StatusFailed, models.StatusFailed, models.StatusFailed},
+		},
+		{
+			name:         "single attempt fails at once",
+			maxAttempts:  1,
+			wantStatus:   models.StatusFailed,
+			wantDevice:   a,
+			wantAttempts: []models.MessageStatus{models.StatusFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgLog := &models.MessageLog{
+				ID:          1,
+				UserID:      uuid.New(),
+				RequestID:   "req-1",
+				Direction:   models.DirectionOutbound,
+				Receiver:    "+84912345678",
+				Status:      models.StatusPending,
+				DeviceID:    &a,
+				MaxAttempts: tt.maxAttempts,
+			}
+			logs := newFakeLogRepository(msgLog)
+			attempts := &fakeAttemptRepository{}
+			attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, DeviceID: a, Status: models.StatusPending})
+
+			gateway := &fakeGateway{online: []uuid.UUID{b, c}, unreachable: make(map[uuid.UUID]bool)}
+			for _, id := range tt.unreachable {
+				gateway.unreachable[id] = true
+			}
+
+			svc := NewOutboundService(logs, attempts, &fakeDeviceRepository{}, nil, gateway, &fakeRouter{})
+			if err := svc.MarkFailed(msgLog.UserID, a, "req-1", nil, "radio off"); err != nil {
+				t.Fatalf("MarkFailed: %v", err)
+			}
+
+			got, _ := logs.FindByID(1)
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			if *got.DeviceID != tt.wantDevice {
+				t.Errorf("device = %s, want %s", *got.DeviceID, tt.wantDevice)
+			}
+			if got.RetryCount >= got.MaxAttempts {
+				t.Errorf("retry count %d exceeds %d attempts", got.RetryCount, got.MaxAttempts)
+			}
+			if !equalIDs(gateway.dispatched, tt.wantDispatch) {
+				t.Errorf("dispatched to %v, want %v", gateway.dispatched, tt.wantDispatch)
+			}
+
+			recorded, _ := attempts.FindByLogID(1)
+			statuses := make([]models.MessageStatus, len(recorded))
+			for i, attempt := range recorded {
+				statuses[i] = attempt.Status
+			}
+			if len(statuses) != len(tt.wantAttempts) {
+				t.Fatalf("attempts = %v, want %v", statuses, tt.wantAttempts)
+			}
+			for i := range statuses {
+				if statuses[i] != tt.wantAttempts[i] {
+					t.Fatalf("attempts = %v, want %v", statuses, tt.wantAttempts)
+				}
+			}
+		})
+	}
+}
+
+// TestMarkFailedIgnoresStaleHop covers failure reports from a hop the message
+// already left: one report per part of a multipart SMS from the old device,
+// and a report naming a SIM the message is no longer on.
+func TestMarkFailedIgnoresStaleHop(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	msgLog := &models.MessageLog{
+		ID:          1,
+		UserID:      uuid.New(),
+		RequestID:   "req-1",
+		Direction:   models.DirectionOutbound,
+		Receiver:    "+84912345678",
+		Status:      models.StatusPending,
+		DeviceID:    &a,
+		MaxAttempts: 3,
+	}
+	logs := newFakeLogRepository(msgLog)
+	attempts := &fakeAttemptRepository{}
+	attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, DeviceID: a, Status: models.StatusPending})
+	gateway := &fakeGateway{online: []uuid.UUID{b}}
+	svc := NewOutboundService(logs, attempts, &fakeDeviceRepository{}, nil, gateway, &fakeRouter{})
+
+	if err := svc.MarkFailed(msgLog.UserID, a, "req-1", nil, "part 1 failed"); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	if err := svc.MarkFailed(msgLog.UserID, a, "req-1", nil, "part 2 failed"); !errors.Is(err, ErrLogNotFound) {
+		t.Fatalf("MarkFailed from the old device error = %v, want %v", err, ErrLogNotFound)
+	}
+	otherSim := 1
+	if err := svc.MarkFailed(msgLog.UserID, b, "req-1", &otherSim, "wrong sim"); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+
+	got, _ := logs.FindByID(1)
+	if got.Status != models.StatusPending || *got.DeviceID != b {
+		t.Errorf("message is %s on %s, want pending on %s", got.Status, *got.DeviceID, b)
+	}
+	if got.RetryCount != 1 {
+		t.Errorf("retry count = %d, want 1", got.RetryCount)
+	}
+	if !equalIDs(gateway.dispatched, []uuid.UUID{b}) {
+		t.Errorf("dispatched to %v, want [%s]", gateway.dispatched, b)
+	}
+	recorded, _ := attempts.FindByLogID(1)
+	if len(recorded) != 2 || recorded[1].Status != models.StatusPending {
+		t.Errorf("attempts = %+v, want the failed hop and a pending one", recorded)
+	}
+}
+
+func TestFailoverAckDeadline(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	soon := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+	}{
+		{"no message expiry", nil},
+		{"capped by the message expiry", &soon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgLog := &models.MessageLog{
+				ID:          1,
+				UserID:      uuid.New(),
+				RequestID:   "req-1",
+				Direction:   models.DirectionOutbound,
+				Status:      models.StatusPending,
+				DeviceID:    &a,
+				MaxAttempts: 2,
+				ExpiresAt:   tt.expiresAt,
+			}
+			attempts := &fakeAttemptRepository{}
+			attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, DeviceID: a, Status: models.StatusPending})
+			gateway := &fakeGateway{online: []uuid.UUID{b}}
+			svc := NewOutboundService(newFakeLogRepository(msgLog), attempts, &fakeDeviceRepository{}, nil, gateway, &fakeRouter{})
+
+			start := time.Now()
+			if err := svc.MarkFailed(msgLog.UserID, a, "req-1", nil, "radio off"); err != nil {
+				t.Fatalf("MarkFailed: %v", err)
+			}
+			if len(gateway.deadlines) != 1 {
+				t.Fatalf("dispatched %d times, want 1", len(gateway.deadlines))
+			}
+			deadline := gateway.deadlines[0]
+			if tt.expiresAt != nil {
+				if !deadline.Equal(*tt.expiresAt) {
+					t.Errorf("ack deadline = %v, want the message expiry %v", deadline, *tt.expiresAt)
+				}
+				return
+			}
+			if deadline.Before(start.Add(SMSAckTimeout)) || deadline.After(time.Now().Add(SMSAckTimeout)) {
+				t.Errorf("ack deadline in %s, want %s", deadline.Sub(start), SMSAckTimeout)
+			}
+		})
+	}
+}
+
+func TestUnacknowledgedExpiredMessageIsNotFailedOver(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	past := time.Now().Add(-time.Second)
+	msgLog := &models.MessageLog{
+		ID:          1,
+		UserID:      uuid.New(),
+		RequestID:   "req-1",
+		Direction:   models.DirectionOutbound,
+		Status:      models.StatusPending,
+		DeviceID:    &a,
+		MaxAttempts: 3,
+		ExpiresAt:   &past,
+	}
+	logs := newFakeLogRepository(msgLog)
+	attempts := &fakeAttemptRepository{}
+	attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, DeviceID: a, Status: models.StatusPending})
+	gateway := &fakeGateway{online: []uuid.UUID{b}}
+	svc := NewOutboundService(logs, attempts, &fakeDeviceRepository{}, nil, gateway, &fakeRouter{})
+
+	if err := svc.MarkUnacknowledged("req-1", a, "expired without acknowledgement"); err != nil {
+		t.Fatalf("MarkUnacknowledged: %v", err)
+	}
+
+	got, _ := logs.FindByID(1)
+	if got.Status != models.StatusExpired {
+		t.Errorf("status = %s, want %s", got.Status, models.StatusExpired)
+	}
+	if len(gateway.dispatched) != 0 {
+		t.Errorf("expired message dispatched to %v", gateway.dispatched)
+	}
+}
+
+func equalIDs(got, want []uuid.UUID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetByRequestIDIsScopedToUser(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	logs := newFakeLogRepository(
+		&models.MessageLog{ID: 1, UserID: owner, RequestID: "req-out", Direction: models.DirectionOutbound},
+		&models.MessageLog{ID: 2, UserID: owner, RequestID: "req-in", Direction: models.DirectionInbound},
+	)
+	attempts := &fakeAttemptRepository{}
+	attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, Status: models.StatusPending})
+	svc := NewOutboundService(logs, attempts, &fakeDeviceRepository{}, nil, &fakeGateway{}, &fakeRouter{})
+
+	tests := []struct {
+		name      string
+		userID    uuid.UUID
+		requestID string
+		wantErr   error
+	}{
+		{"owner reads its message", owner, "req-out", nil},
+		{"other user gets not found", other, "req-out", ErrLogNotFound},
+		{"inbound messages are not exposed", owner, "req-in", ErrLogNotFound},
+		{"unknown request id", owner, "req-missing", ErrLogNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgLog, err := svc.GetByRequestID(tt.userID, tt.requestID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetByRequestID() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if msgLog.UserID != tt.userID {
+				t.Errorf("returned message of user %s", msgLog.UserID)
+			}
+			if len(msgLog.Attempts) != 1 {
+				t.Errorf("attempts = %d, want 1", len(msgLog.Attempts))
+			}
+		})
+	}
+}
+
+func TestStatusReportsAreScopedToCurrentDevice(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	current, previous := uuid.New(), uuid.New()
+	deliveredAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newService := func() (OutboundService, *fakeLogRepository) {
+		logs := newFakeLogRepository(&models.MessageLog{
+			ID:          1,
+			UserID:      owner,
+			RequestID:   "req-1",
+			Direction:   models.DirectionOutbound,
+			Status:      models.StatusPending,
+			DeviceID:    &current,
+			MaxAttempts: 1,
+		})
+		attempts := &fakeAttemptRepository{}
+		attempts.Create(&models.DeliveryAttempt{LogID: 1, Attempt: 1, DeviceID: current, Status: models.StatusPending})
+		return NewOutboundService(logs, attempts, &fakeDeviceRepository{}, nil, &fakeGateway{}, &fakeRouter{}), logs
+	}
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		deviceID uuid.UUID
+		wantErr  error
+	}{
+		{"current device", owner, current, nil},
+		{"device the message failed over from", owner, previous, ErrLogNotFound},
+		{"device of another user", other, current, ErrLogNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, logs := newService()
+			if err := svc.MarkSent(tt.userID, tt.deviceID, "req-1"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MarkSent() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := svc.MarkDelivered(tt.userID, tt.deviceID, "req-1", deliveredAt); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MarkDelivered() error = %v, want %v", err, tt.wantErr)
+			}
+
+			got, _ := logs.FindByID(1)
+			if tt.wantErr != nil {
+				if got.Status != models.StatusPending {
+					t.Errorf("status = %s, want %s", got.Status, models.StatusPending)
+				}
+				return
+			}
+			if got.Status != models.StatusDelivered {
+				t.Errorf("status = %s, want %s", got.Status, models.StatusDelivered)
+			}
+			if got.DeliveredAt == nil || !got.DeliveredAt.Equal(deliveredAt) {
+				t.Errorf("delivered at = %v, want the reported %v", got.DeliveredAt, deliveredAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/phone"
+)
+
+// DefaultPhoneCountry is used for accounts that never picked a region.
+const DefaultPhoneCountry = "VN"
+
+var (
+	ErrInvalidPhone       = errors.New("invalid phone number")
+	ErrUnsupportedCountry = errors.New("unsupported default country")
+)
+
+// NormalizePhone parses raw with the account's default country and returns
+// it in E.164. The error wraps ErrInvalidPhone and names the reason.
+func NormalizePhone(raw, defaultCountry string) (*phone.Number, error) {
+	if defaultCountry == "" {
+		defaultCountry = DefaultPhoneCountry
+	}
+	number, err := phone.Parse(raw, defaultCountry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPhone, err)
+	}
+	return number, nil
+}
+
+// NormalizeSender converts numeric inbound senders to E.164 and leaves
+// alphanumeric sender IDs ("Vietcombank") and short codes as reported.
+func NormalizeSender(raw, defaultCountry string) string {
+	number, err := NormalizePhone(raw, defaultCountry)
+	if err != nil {
+		return raw
+	}
+	return number.E164
+}
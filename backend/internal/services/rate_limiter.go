@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	rateWindow = time.Minute
+
+	redisRatePrefix = "tinghook:rate:device:"
+)
+
+// DeviceRateLimiter tracks messages handed to each device over a sliding
+// one-minute window. It is shared by all campaigns so concurrent campaigns
+// of one user do not add up past the device's rate.
+type DeviceRateLimiter interface {
+	// Available returns how many more messages the device may take in the
+	// current window
+	Available(ctx context.Context, deviceID uuid.UUID, limit int) (int, error)
+	Record(ctx context.Context, deviceID uuid.UUID) error
+}
+
+type memoryRateLimiter struct {
+	mu   sync.Mutex
+	sent map[uuid.UUID][]time.Time
+}
+
+// NewMemoryRateLimiter keeps the window in process, for single-instance
+// deployments.
+func NewMemoryRateLimiter() DeviceRateLimiter {
+	return &memoryRateLimiter{sent: make(map[uuid.UUID][]time.Time)}
+}
+
+func (l *memoryRateLimiter) Available(ctx context.Context, deviceID uuid.UUID, limit int) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(deviceID, time.Now())
+	return limit - len(l.sent[deviceID]), nil
+}
+
+func (l *memoryRateLimiter) Record(ctx context.Context, deviceID uuid.UUID) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sent[deviceID] = append(l.sent[deviceID], time.Now())
+	return nil
+}
+
+func (l *memoryRateLimiter) prune(deviceID uuid.UUID, now time.Time) {
+	times := l.sent[deviceID]
+	cutoff := now.Add(-rateWindow)
+
+	kept := 0
+	for kept < len(times) && times[kept].Before(cutoff) {
+		kept++
+	}
+
+	if kept == len(times) {
+		delete(l.sent, deviceID)
+		return
+	}
+	l.sent[deviceID] = times[kept:]
+}
+
+type redisRateLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimiter keeps each device's window in a sorted set scored by
+// send time, so every replica and worker draws from the same budget.
+func NewRedisRateLimiter(client *redis.Client) DeviceRateLimiter {
+	return &redisRateLimiter{client: client}
+}
+
+func deviceRateKey(deviceID uuid.UUID) string {
+	return redisRatePrefix + deviceID.String()
+}
+
+func (l *redisRateLimiter) Available(ctx context.Context, deviceID uuid.UUID, limit int) (int, error) {
+	key := deviceRateKey(deviceID)
+	cutoff := time.Now().Add(-rateWindow).UnixMicro()
+
+	var count *redis.IntCmd
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(cutoff, 10))
+		count = pipe.ZCard(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return limit - int(count.Val()), nil
+}
+
+func (l *redisRateLimiter) Record(ctx context.Context, deviceID uuid.UUID) error {
+	key := deviceRateKey(deviceID)
+	now := time.Now()
+
+	_, err := l.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixMicro()), Member: uuid.NewString()})
+		pipe.Expire(ctx, key, rateWindow)
+		return nil
+	})
+	return err
+}
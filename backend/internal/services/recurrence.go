@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+const maxRecurrenceInterval = 366
+
+// Recurrence is the subset of RFC 5545 RRULE supported for scheduled
+// messages: FREQ (DAILY, WEEKLY, MONTHLY), INTERVAL, COUNT and UNTIL.
+type Recurrence struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    *time.Time
+}
+
+// ParseRecurrence parses rules such as "FREQ=DAILY;INTERVAL=2;COUNT=10".
+// A leading "RRULE:" is accepted.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, ErrInvalidRecurrence
+	}
+
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, ErrInvalidRecurrence
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRecurrenceInterval {
+				return nil, ErrInvalidRecurrence
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, ErrInvalidRecurrence
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, ErrInvalidRecurrence
+			}
+			r.Until = &until
+		default:
+			return nil, ErrInvalidRecurrence
+		}
+	}
+
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY":
+	default:
+		return nil, ErrInvalidRecurrence
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, ErrInvalidRecurrence
+	}
+
+	return r, nil
+}
+
+// Next returns the run after prev, the occurrences-th one, or nil once the
+// rule is exhausted. Steps are taken on the wall clock of loc so a daily
+// 08:00 stays at 08:00 across DST changes. Monthly runs are counted from
+// anchor, the series' first run, and clamped to the end of shorter months:
+// a schedule anchored on Jan 31 runs Feb 28, Mar 31, Apr 30.
+func (r *Recurrence) Next(anchor, prev time.Time, occurrences int, loc *time.Location) *time.Time {
+	if r.Count > 0 && occurrences >= r.Count {
+		return nil
+	}
+
+	local := prev.In(loc)
+	var next time.Time
+	switch r.Freq {
+	case "DAILY":
+		next = local.AddDate(0, 0, r.Interval)
+	case "WEEKLY":
+		next = local.AddDate(0, 0, 7*r.Interval)
+	case "MONTHLY":
+		start := anchor.In(loc)
+		elapsed := (local.Year()-start.Year())*12 + int(local.Month()-start.Month())
+		next = addMonthsClamped(start, elapsed+r.Interval)
+	default:
+		return nil
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return nil
+	}
+
+	next = next.UTC()
+	return &next
+}
+
+// addMonthsClamped moves t by months, keeping its day of month unless the
+// target month is shorter, in which case it lands on that month's last day.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidRecurrence
+}
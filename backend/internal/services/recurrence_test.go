@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNextMonthEnd(t *testing.T) {
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		rule   string
+		anchor time.Time
+		want   []string
+	}{
+		{
+			name:   "31st keeps to month ends",
+			rule:   "FREQ=MONTHLY",
+			anchor: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:   []string{"2025-02-28", "2025-03-31", "2025-04-30", "2025-05-31"},
+		},
+		{
+			name:   "29th in a leap year",
+			rule:   "FREQ=MONTHLY",
+			anchor: time.Date(2024, 1, 29, 9, 0, 0, 0, time.UTC),
+			want:   []string{"2024-02-29", "2024-03-29", "2024-04-29"},
+		},
+		{
+			name:   "interval crosses the year",
+			rule:   "FREQ=MONTHLY;INTERVAL=3",
+			anchor: time.Date(2025, 8, 31, 9, 0, 0, 0, time.UTC),
+			want:   []string{"2025-11-30", "2026-02-28", "2026-05-31"},
+		},
+		{
+			name:   "day of month in the schedule's timezone",
+			rule:   "FREQ=MONTHLY",
+			anchor: time.Date(2025, 1, 30, 20, 0, 0, 0, time.UTC), // Jan 31 03:00 in Saigon
+			want:   []string{"2025-02-28", "2025-03-31", "2025-04-30"},
+		},
+		{
+			name:   "mid-month days are unchanged",
+			rule:   "FREQ=MONTHLY",
+			anchor: time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC),
+			want:   []string{"2025-02-15", "2025-03-15"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			anchorLocal := tt.anchor.In(saigon)
+			prev := tt.anchor
+			for i, want := range tt.want {
+				next := rule.Next(tt.anchor, prev, i+1, saigon)
+				if next == nil {
+					t.Fatalf("run %d: rule ended early", i+2)
+				}
+				local := next.In(saigon)
+				if got := local.Format("2006-01-02"); got != want {
+					t.Fatalf("run %d = %s, want %s", i+2, got, want)
+				}
+				if local.Hour() != anchorLocal.Hour() || local.Minute() != anchorLocal.Minute() {
+					t.Errorf("run %d at %s, want the anchor's time of day %s", i+2, local.Format("15:04"), anchorLocal.Format("15:04"))
+				}
+				prev = *next
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	phonenum "github.com/octopuslowtech/tinghook-project/backend/pkg/phone"
+)
+
+const (
+	// lowBatteryThreshold is the level below which battery_aware routing
+	// avoids a device unless every candidate is that low.
+	lowBatteryThreshold = 15
+)
+
+var (
+	ErrInvalidRoutingStrategy = errors.New("invalid routing strategy")
+	ErrRoutingPrefixNotFound  = errors.New("routing prefix not found")
+	ErrRoutingPrefixDenied    = errors.New("access denied to this routing prefix")
+	ErrInvalidRoutingPrefix   = errors.New("invalid routing prefix")
+	ErrNoCandidateDevices     = errors.New("no candidate devices")
+)
+
+// RouteDecision is the device chosen for an outbound message. SimSlot is only
+// set by strategies that also pick the SIM (sticky, carrier_prefix).
+type RouteDecision struct {
+	DeviceID uuid.UUID
+	SimSlot  *int
+}
+
+type CreateRoutingPrefixRequest struct {
+	Prefix   string
+	DeviceID string
+	SimSlot  int
+}
+
+type RoutingService interface {
+	Select(strategy string, userID uuid.UUID, phone string, candidates []uuid.UUID) (*RouteDecision, error)
+	ListPrefixes(userID uuid.UUID) ([]models.RoutingPrefix, error)
+	CreatePrefix(userID uuid.UUID, req *CreateRoutingPrefixRequest) (*models.RoutingPrefix, error)
+	DeletePrefix(id uint, userID uuid.UUID) error
+}
+
+type routingService struct {
+	logRepo    repository.LogRepository
+	deviceRepo repository.DeviceRepository
+	prefixRepo repository.RoutingPrefixRepository
+
+	mu      sync.Mutex
+	cursors map[uuid.UUID]int
+}
+
+func NewRoutingService(
+	logRepo repository.LogRepository,
+	deviceRepo repository.DeviceRepository,
+	prefixRepo repository.RoutingPrefixRepository,
+) RoutingService {
+	return &routingService{
+		logRepo:    logRepo,
+		deviceRepo: deviceRepo,
+		prefixRepo: prefixRepo,
+		cursors:    make(map[uuid.UUID]int),
+	}
+}
+
+func ValidateRoutingStrategy(strategy string) error {
+	switch strategy {
+	case models.RoutingRoundRobin, models.RoutingLeastLoaded, models.RoutingBatteryAware,
+		models.RoutingSticky, models.RoutingCarrier:
+		return nil
+	default:
+		return ErrInvalidRoutingStrategy
+	}
+}
+
+// Select picks one of the online candidate devices according to strategy.
+// Strategies that cannot decide (no history, no matching prefix) fall back to
+// round robin.
+func (s *routingService) Select(strategy string, userID uuid.UUID, phone string, candidates []uuid.UUID) (*RouteDecision, error) {
+	if len(candidates) == 0 {
+		return nil, ErrNoCandidateDevices
+	}
+	if strategy == "" {
+		strategy = models.RoutingRoundRobin
+	}
+	if err := ValidateRoutingStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	candidates = sortedCandidates(candidates)
+
+	var decision *RouteDecision
+	var err error
+
+	switch strategy {
+	case models.RoutingLeastLoaded:
+		decision, err = s.leastLoaded(candidates)
+	case models.RoutingBatteryAware:
+		decision, err = s.batteryAware(candidates)
+	case models.RoutingSticky:
+		decision, err = s.sticky(userID, phone, candidates)
+	case models.RoutingCarrier:
+		decision, err = s.carrierPrefix(userID, phone, candidates)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if decision == nil {
+		decision = s.roundRobin(userID, candidates)
+	}
+	return decision, nil
+}
+
+func (s *routingService) roundRobin(userID uuid.UUID, candidates []uuid.UUID) *RouteDecision {
+	s.mu.Lock()
+	cursor := s.cursors[userID]
+	s.cursors[userID] = cursor + 1
+	s.mu.Unlock()
+
+	return &RouteDecision{DeviceID: candidates[cursor%len(candidates)]}
+}
+
+func (s *routingService) leastLoaded(candidates []uuid.UUID) (*RouteDecision, error) {
+	counts, err := s.logRepo.CountInFlight(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if counts[candidate] < counts[best] {
+			best = candidate
+		}
+	}
+	return &RouteDecision{DeviceID: best}, nil
+}
+
+func (s *routingService) batteryAware(candidates []uuid.UUID) (*RouteDecision, error) {
+	devices, err := s.deviceRepo.FindByIDs(candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].BatteryLevel > devices[j].BatteryLevel
+	})
+
+	// Every device is low: still send, from the fullest one.
+	best := devices[0]
+	if best.BatteryLevel < lowBatteryThreshold {
+		return &RouteDecision{DeviceID: best.ID}, nil
+	}
+
+	// Otherwise spread load across the healthy devices by in-flight count.
+	healthy := make([]uuid.UUID, 0, len(devices))
+	for _, device := range devices {
+		if device.BatteryLevel >= lowBatteryThreshold {
+			healthy = append(healthy, device.ID)
+		}
+	}
+	return s.leastLoaded(sortedCandidates(healthy))
+}
+
+func (s *routingService) sticky(userID uuid.UUID, phone string, candidates []uuid.UUID) (*RouteDecision, error) {
+	last, err := s.logRepo.FindLastOutboundTo(userID, phone)
+	if err != nil {
+		if errors.Is(err, repository.ErrLogNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if last.DeviceID == nil || !containsDevice(candidates, *last.DeviceID) {
+		return nil, nil
+	}
+
+	simSlot := last.SimSlot
+	return &RouteDecision{DeviceID: *last.DeviceID, SimSlot: &simSlot}, nil
+}
+
+func (s *routingService) carrierPrefix(userID uuid.UUID, phone string, candidates []uuid.UUID) (*RouteDecision, error) {
+	prefixes, err := s.prefixRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Receivers arrive in E.164 but prefixes are often written the way
+	// carriers advertise them ("091"), so match both forms
+	forms := []string{phone}
+	if number, err := phonenum.Parse(phone, ""); err == nil {
+		forms = append(forms, number.NationalFormat())
+	}
+
+	for _, prefix := range prefixes {
+		if !hasPrefixInAny(forms, prefix.Prefix) || !containsDevice(candidates, prefix.DeviceID) {
+			continue
+		}
+		simSlot := prefix.SimSlot
+		return &RouteDecision{DeviceID: prefix.DeviceID, SimSlot: &simSlot}, nil
+	}
+	return nil, nil
+}
+
+func hasPrefixInAny(forms []string, prefix string) bool {
+	for _, form := range forms {
+		if strings.HasPrefix(form, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *routingService) ListPrefixes(userID uuid.UUID) ([]models.RoutingPrefix, error) {
+	return s.prefixRepo.FindByUserID(userID)
+}
+
+func (s *routingService) CreatePrefix(userID uuid.UUID, req *CreateRoutingPrefixRequest) (*models.RoutingPrefix, error) {
+	prefix := strings.TrimSpace(req.Prefix)
+	if prefix == "" || len(prefix) > 20 {
+		return nil, ErrInvalidRoutingPrefix
+	}
+
+	deviceID, err := uuid.Parse(req.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device_id: %w", err)
+	}
+
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	if device.UserID != userID {
+		return nil, ErrDeviceNotFound
+	}
+
+	routingPrefix := &models.RoutingPrefix{
+		UserID:    userID,
+		Prefix:    prefix,
+		DeviceID:  deviceID,
+		SimSlot:   req.SimSlot,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.prefixRepo.Create(routingPrefix); err != nil {
+		return nil, err
+	}
+	return routingPrefix, nil
+}
+
+func (s *routingService) DeletePrefix(id uint, userID uuid.UUID) error {
+	prefix, err := s.prefixRepo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRoutingPrefixNotFound) {
+			return ErrRoutingPrefixNotFound
+		}
+		return err
+	}
+
+	if prefix.UserID != userID {
+		return ErrRoutingPrefixDenied
+	}
+
+	return s.prefixRepo.Delete(id)
+}
+
+// sortedCandidates gives strategies a stable order, since online device lists
+// come from map iteration.
+func sortedCandidates(candidates []uuid.UUID) []uuid.UUID {
+	sorted := append([]uuid.UUID(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	return sorted
+}
+
+func containsDevice(candidates []uuid.UUID, deviceID uuid.UUID) bool {
+	for _, candidate := range candidates {
+		if candidate == deviceID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+const (
+	// scheduleGrace tolerates clock skew for send_at values just in the past
+	scheduleGrace = time.Minute
+
+	MaxScheduleHorizon = 365 * 24 * time.Hour
+)
+
+var (
+	ErrScheduleNotFound  = errors.New("scheduled message not found")
+	ErrScheduleNotActive = errors.New("scheduled message is no longer pending")
+	ErrInvalidSendAt     = errors.New("invalid send_at")
+	ErrInvalidTimezone   = errors.New("invalid timezone")
+)
+
+// MessageScheduler queues a schedule's run for the worker. It is declared
+// here so services does not depend on the workers package.
+type MessageScheduler interface {
+	Schedule(scheduleID uuid.UUID, runAt time.Time) error
+	Unschedule(scheduleID uuid.UUID, runAt time.Time) error
+}
+
+type ScheduleMessageRequest struct {
+	Send       SendRequest
+	SendAt     time.Time
+	Timezone   string
+	Recurrence string
+}
+
+type UpdateScheduleRequest struct {
+	Phone      *string
+	Content    *string
+	SendAt     *time.Time
+	Timezone   *string
+	Recurrence *string
+}
+
+type ScheduleService interface {
+	Create(userID uuid.UUID, req *ScheduleMessageRequest) (*models.ScheduledMessage, error)
+	Get(id, userID uuid.UUID) (*models.ScheduledMessage, error)
+	List(userID uuid.UUID, status string, limit, offset int) ([]models.ScheduledMessage, int64, error)
+	Update(id, userID uuid.UUID, req *UpdateScheduleRequest) (*models.ScheduledMessage, error)
+	Cancel(id, userID uuid.UUID) (*models.ScheduledMessage, error)
+	Run(id uuid.UUID, runAt time.Time) error
+}
+
+type scheduleService struct {
+	repo      repository.ScheduleRepository
+	outbound  OutboundService
+	scheduler MessageScheduler
+}
+
+func NewScheduleService(repo repository.ScheduleRepository, outbound OutboundService, scheduler MessageScheduler) ScheduleService {
+	return &scheduleService{
+		repo:      repo,
+		outbound:  outbound,
+		scheduler: scheduler,
+	}
+}
+
+// LoadTimezone resolves an IANA zone name, defaulting to UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+func (s *scheduleService) Create(userID uuid.UUID, req *ScheduleMessageRequest) (*models.ScheduledMessage, error) {
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := LoadTimezone(timezone); err != nil {
+		return nil, err
+	}
+
+	if req.Recurrence != "" {
+		if _, err := ParseRecurrence(req.Recurrence); err != nil {
+			return nil, err
+		}
+	}
+
+	sendAt, err := validateSendAt(req.SendAt)
+	if err != nil {
+		return nil, err
+	}
+
+	send := req.Send
+	if send.TTL < 0 || send.TTL > MaxOutboundTTL {
+		return nil, ErrInvalidTTL
+	}
+	if send.MaxAttempts == 0 {
+		send.MaxAttempts = 1
+	}
+	if send.MaxAttempts < 1 || send.MaxAttempts > MaxFailoverAttempts {
+		return nil, ErrInvalidMaxAttempts
+	}
+
+	schedule := &models.ScheduledMessage{
+		UserID:      userID,
+		Phone:       send.Phone,
+		Content:     send.Content,
+		DeviceID:    send.DeviceID,
+		SimSlot:     send.SimSlot,
+		Strategy:    send.Strategy,
+		TTLSeconds:  int(send.TTL / time.Second),
+		MaxAttempts: send.MaxAttempts,
+		Timezone:    timezone,
+		Recurrence:  req.Recurrence,
+		Status:      models.ScheduleActive,
+		NextRunAt:   &sendAt,
+		AnchorAt:    &sendAt,
+
+		StatusCallbackURL: send.StatusCallbackURL,
+	}
+
+	if err := s.repo.Create(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.scheduler.Schedule(schedule.ID, sendAt); err != nil {
+		if _, cancelErr := s.repo.Cancel(schedule.ID); cancelErr != nil {
+			log.Printf("[schedule] failed to roll back schedule %s: %v", schedule.ID, cancelErr)
+		}
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *scheduleService) Get(id, userID uuid.UUID) (*models.ScheduledMessage, error) {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+
+	if schedule.UserID != userID {
+		return nil, ErrScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+func (s *scheduleService) List(userID uuid.UUID, status string, limit, offset int) ([]models.ScheduledMessage, int64, error) {
+	return s.repo.FindByUserID(userID, status, limit, offset)
+}
+
+// Update edits a pending schedule. Moving send_at re-queues the run; the old
+// task is removed and would be ignored by Run anyway.
+func (s *scheduleService) Update(id, userID uuid.UUID, req *UpdateScheduleRequest) (*models.ScheduledMessage, error) {
+	schedule, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != models.ScheduleActive || schedule.NextRunAt == nil {
+		return nil, ErrScheduleNotActive
+	}
+
+	previousRun := *schedule.NextRunAt
+
+	if req.Phone != nil {
+		schedule.Phone = *req.Phone
+	}
+	if req.Content != nil {
+		schedule.Content = *req.Content
+	}
+	if req.Timezone != nil {
+		if _, err := LoadTimezone(*req.Timezone); err != nil {
+			return nil, err
+		}
+		schedule.Timezone = *req.Timezone
+	}
+	if req.Recurrence != nil {
+		if *req.Recurrence != "" {
+			if _, err := ParseRecurrence(*req.Recurrence); err != nil {
+				return nil, err
+			}
+		}
+		schedule.Recurrence = *req.Recurrence
+	}
+	if req.SendAt != nil {
+		sendAt, err := validateSendAt(*req.SendAt)
+		if err != nil {
+			return nil, err
+		}
+		schedule.NextRunAt = &sendAt
+		schedule.AnchorAt = &sendAt
+	}
+
+	schedule.UpdatedAt = time.Now()
+	if err := s.repo.Update(schedule); err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			return nil, ErrScheduleNotActive
+		}
+		return nil, err
+	}
+
+	if !schedule.NextRunAt.Equal(previousRun) {
+		if err := s.scheduler.Unschedule(schedule.ID, previousRun); err != nil {
+			log.Printf("[schedule] failed to remove task for schedule %s: %v", schedule.ID, err)
+		}
+		if err := s.scheduler.Schedule(schedule.ID, *schedule.NextRunAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return schedule, nil
+}
+
+func (s *scheduleService) Cancel(id, userID uuid.UUID) (*models.ScheduledMessage, error) {
+	schedule, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := s.repo.Cancel(id)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, ErrScheduleNotActive
+	}
+
+	if schedule.NextRunAt != nil {
+		if err := s.scheduler.Unschedule(schedule.ID, *schedule.NextRunAt); err != nil {
+			log.Printf("[schedule] failed to remove task for schedule %s: %v", schedule.ID, err)
+		}
+	}
+
+	return s.repo.FindByID(id)
+}
+
+// Run executes the occurrence due at runAt. The schedule is advanced before
+// the message is sent so a retried task never sends the same occurrence
+// twice; runs that no longer match the schedule are dropped.
+func (s *scheduleService) Run(id uuid.UUID, runAt time.Time) error {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if schedule.Status != models.ScheduleActive || schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(runAt) {
+		return nil
+	}
+
+	next, err := s.nextRun(schedule, runAt)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := s.repo.Advance(id, runAt, next)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	if next != nil {
+		if err := s.scheduler.Schedule(id, *next); err != nil {
+			log.Printf("[schedule] failed to queue next run of schedule %s: %v", id, err)
+		}
+	}
+
+	msgLog, err := s.outbound.Send(schedule.UserID, &SendRequest{
+		Phone:       schedule.Phone,
+		Content:     schedule.Content,
+		DeviceID:    schedule.DeviceID,
+		SimSlot:     schedule.SimSlot,
+		TTL:         time.Duration(schedule.TTLSeconds) * time.Second,
+		Strategy:    schedule.Strategy,
+		MaxAttempts: schedule.MaxAttempts,
+
+		StatusCallbackURL: schedule.StatusCallbackURL,
+	})
+	if errors.Is(err, ErrRecipientSuppressed) {
+		return s.repo.RecordRun(id, "", SuppressedErrorCode)
+	}
+	if err != nil {
+		log.Printf("[schedule] run of schedule %s failed: %v", id, err)
+		return s.repo.RecordRun(id, "", err.Error())
+	}
+
+	return s.repo.RecordRun(id, msgLog.RequestID, "")
+}
+
+func (s *scheduleService) nextRun(schedule *models.ScheduledMessage, runAt time.Time) (*time.Time, error) {
+	if schedule.Recurrence == "" {
+		return nil, nil
+	}
+
+	rule, err := ParseRecurrence(schedule.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := LoadTimezone(schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := runAt
+	if schedule.AnchorAt != nil {
+		anchor = *schedule.AnchorAt
+	}
+
+	// Occurrences counts completed runs, this one included
+	return rule.Next(anchor, runAt, schedule.Occurrences+1, loc), nil
+}
+
+func validateSendAt(sendAt time.Time) (time.Time, error) {
+	now := time.Now()
+	if sendAt.IsZero() || sendAt.Before(now.Add(-scheduleGrace)) || sendAt.After(now.Add(MaxScheduleHorizon)) {
+		return time.Time{}, ErrInvalidSendAt
+	}
+	if sendAt.Before(now) {
+		sendAt = now
+	}
+	return sendAt.UTC().Truncate(time.Second), nil
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+// SuppressedErrorCode is returned to API clients whose send is refused
+// because the recipient opted out.
+const SuppressedErrorCode = "recipient_suppressed"
+
+const (
+	MaxSuppressionImport = 10000
+	maxKeywords          = 20
+	maxKeywordLength     = 50
+)
+
+var (
+	ErrRecipientSuppressed = errors.New("recipient has opted out of messages")
+	ErrSuppressionNotFound = errors.New("suppressed number not found")
+	ErrInvalidKeywords     = errors.New("invalid keywords")
+)
+
+// Replies are compared after transliteration, so "HỦY" matches HUY.
+var (
+	DefaultOptOutKeywords = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT", "HUY", "TUCHOI"}
+	DefaultOptInKeywords  = []string{"START", "UNSTOP", "SUBSCRIBE", "DANGKY"}
+)
+
+type KeywordAction string
+
+const (
+	KeywordNone   KeywordAction = ""
+	KeywordOptOut KeywordAction = "opt_out"
+	KeywordOptIn  KeywordAction = "opt_in"
+)
+
+type SuppressionImportResult struct {
+	Added   int64    `json:"added"`
+	Skipped int      `json:"skipped"`
+	Invalid []string `json:"invalid"`
+}
+
+type SuppressionKeywords struct {
+	OptOut []string `json:"opt_out"`
+	OptIn  []string `json:"opt_in"`
+}
+
+type SuppressionService interface {
+	List(userID uuid.UUID, search string, limit, offset int) ([]models.SuppressedNumber, int64, error)
+	Import(userID uuid.UUID, phones []string) (*SuppressionImportResult, error)
+	Remove(userID uuid.UUID, phone string) error
+	IsSuppressed(userID uuid.UUID, phone string) (bool, error)
+	Keywords(userID uuid.UUID) (*SuppressionKeywords, error)
+	UpdateKeywords(userID uuid.UUID, keywords *SuppressionKeywords) (*SuppressionKeywords, error)
+	// HandleInbound applies an inbound SMS from sender that is nothing but an
+	// opt-out or opt-in keyword; sender must already be normalized
+	HandleInbound(userID, deviceID uuid.UUID, sender, content string) (KeywordAction, error)
+}
+
+type suppressionService struct {
+	repo     repository.SuppressionRepository
+	userRepo repository.UserRepository
+}
+
+func NewSuppressionService(repo repository.SuppressionRepository, userRepo repository.UserRepository) SuppressionService {
+	return &suppressionService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *suppressionService) List(userID uuid.UUID, search string, limit, offset int) ([]models.SuppressedNumber, int64, error) {
+	return s.repo.FindByUserID(userID, strings.TrimSpace(search), limit, offset)
+}
+
+// Import normalizes phones with the user's default country and suppresses
+// the valid ones. Numbers that do not parse are returned, not fatal.
+func (s *suppressionService) Import(userID uuid.UUID, phones []string) (*SuppressionImportResult, error) {
+	if len(phones) > MaxSuppressionImport {
+		return nil, fmt.Errorf("at most %d numbers can be imported at once", MaxSuppressionImport)
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SuppressionImportResult{Invalid: []string{}}
+	seen := make(map[string]bool, len(phones))
+	entries := make([]models.SuppressedNumber, 0, len(phones))
+	for _, raw := range phones {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		number, err := NormalizePhone(raw, user.DefaultCountry)
+		if err != nil {
+			result.Invalid = append(result.Invalid, raw)
+			continue
+		}
+		if seen[number.E164] {
+			continue
+		}
+		seen[number.E164] = true
+		entries = append(entries, models.SuppressedNumber{
+			UserID: userID,
+			Phone:  number.E164,
+			Source: models.SuppressionSourceImport,
+		})
+	}
+
+	added, err := s.repo.Add(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Added = added
+	result.Skipped = len(entries) - int(added)
+	return result, nil
+}
+
+func (s *suppressionService) Remove(userID uuid.UUID, phone string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	number, err := NormalizePhone(phone, user.DefaultCountry)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Remove(userID, number.E164); err != nil {
+		if errors.Is(err, repository.ErrSuppressionNotFound) {
+			return ErrSuppressionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *suppressionService) IsSuppressed(userID uuid.UUID, phone string) (bool, error) {
+	return s.repo.IsSuppressed(userID, phone)
+}
+
+func (s *suppressionService) Keywords(userID uuid.UUID) (*SuppressionKeywords, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return userKeywords(user), nil
+}
+
+// UpdateKeywords replaces the user's keyword lists. A nil list restores the
+// defaults; an empty one disables that action.
+func (s *suppressionService) UpdateKeywords(userID uuid.UUID, keywords *SuppressionKeywords) (*SuppressionKeywords, error) {
+	optOut, err := normalizeKeywords(keywords.OptOut)
+	if err != nil {
+		return nil, err
+	}
+	optIn, err := normalizeKeywords(keywords.OptIn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, keyword := range optOut {
+		if containsString(optIn, keyword) {
+			return nil, fmt.Errorf("%w: %q is both an opt-out and an opt-in keyword", ErrInvalidKeywords, keyword)
+		}
+	}
+
+	if err := s.userRepo.UpdateKeywords(userID, optOut, optIn); err != nil {
+		return nil, err
+	}
+
+	return userKeywords(&models.User{OptOutKeywords: optOut, OptInKeywords: optIn}), nil
+}
+
+func (s *suppressionService) HandleInbound(userID, deviceID uuid.UUID, sender, content string) (KeywordAction, error) {
+	word := keywordReply(content)
+	if word == "" {
+		return KeywordNone, nil
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return KeywordNone, err
+	}
+	keywords := userKeywords(user)
+
+	switch {
+	case containsString(keywords.OptOut, word):
+		_, err := s.repo.Add([]models.SuppressedNumber{{
+			UserID:   userID,
+			Phone:    sender,
+			Source:   models.SuppressionSourceKeyword,
+			Keyword:  word,
+			DeviceID: &deviceID,
+		}})
+		return KeywordOptOut, err
+	case containsString(keywords.OptIn, word):
+		err := s.repo.Remove(userID, sender)
+		if errors.Is(err, repository.ErrSuppressionNotFound) {
+			err = nil
+		}
+		return KeywordOptIn, err
+	}
+	return KeywordNone, nil
+}
+
+func userKeywords(user *models.User) *SuppressionKeywords {
+	keywords := &SuppressionKeywords{
+		OptOut: user.OptOutKeywords,
+		OptIn:  user.OptInKeywords,
+	}
+	if keywords.OptOut == nil {
+		keywords.OptOut = DefaultOptOutKeywords
+	}
+	if keywords.OptIn == nil {
+		keywords.OptIn = DefaultOptInKeywords
+	}
+	return keywords
+}
+
+func normalizeKeywords(keywords []string) ([]string, error) {
+	if keywords == nil {
+		return nil, nil
+	}
+	if len(keywords) > maxKeywords {
+		return nil, fmt.Errorf("%w: at most %d keywords per list", ErrInvalidKeywords, maxKeywords)
+	}
+
+	result := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		normalized := keywordReply(keyword)
+		if normalized == "" || len(normalized) > maxKeywordLength || normalized != foldKeyword(keyword) {
+			return nil, fmt.Errorf("%w: %q must be a single word", ErrInvalidKeywords, keyword)
+		}
+		if !containsString(result, normalized) {
+			result = append(result, normalized)
+		}
+	}
+	return result, nil
+}
+
+// keywordReply returns an inbound reply folded for keyword comparison when it
+// is a single word, so "Stop." and "hủy" read as STOP and HUY. Longer replies
+// such as "Stop by later" return "": only a reply that is nothing but the
+// keyword opts in or out.
+func keywordReply(content string) string {
+	word := strings.TrimFunc(foldKeyword(content), func(r rune) bool {
+		return !isKeywordRune(r)
+	})
+	if strings.IndexFunc(word, func(r rune) bool { return !isKeywordRune(r) }) >= 0 {
+		return ""
+	}
+	return word
+}
+
+func isKeywordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func foldKeyword(s string) string {
+	return strings.ToUpper(strings.TrimSpace(sms.Transliterate(s)))
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
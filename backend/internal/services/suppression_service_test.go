@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+// fakeSuppressionRepository records suppressed numbers by phone.
+type fakeSuppressionRepository struct {
+	repository.SuppressionRepository
+
+	suppressed map[string]string
+}
+
+func (r *fakeSuppressionRepository) Add(entries []models.SuppressedNumber) (int64, error) {
+	for _, entry := range entries {
+		r.suppressed[entry.Phone] = entry.Keyword
+	}
+	return int64(len(entries)), nil
+}
+
+func (r *fakeSuppressionRepository) Remove(userID uuid.UUID, phone string) error {
+	if _, ok := r.suppressed[phone]; !ok {
+		return repository.ErrSuppressionNotFound
+	}
+	delete(r.suppressed, phone)
+	return nil
+}
+
+// fakeUserRepository returns a user with the default keyword lists.
+type fakeUserRepository struct {
+	repository.UserRepository
+}
+
+func (r *fakeUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	return &models.User{ID: id}, nil
+}
+
+func TestHandleInbound(t *testing.T) {
+	const sender = "+84912345678"
+
+	tests := []struct {
+		content    string
+		wantAction KeywordAction
+	}{
+		{"STOP", KeywordOptOut},
+		{"stop", KeywordOptOut},
+		{"  Stop. ", KeywordOptOut},
+		{"CANCEL!", KeywordOptOut},
+		{"hủy", KeywordOptOut},
+		{"start", KeywordOptIn},
+		{"Cancel my order", KeywordNone},
+		{"End of day?", KeywordNone},
+		{"Stop by later", KeywordNone},
+		{"quit it", KeywordNone},
+		{"please stop", KeywordNone},
+		{"stop-start", KeywordNone},
+		{"", KeywordNone},
+		{"?!", KeywordNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			repo := &fakeSuppressionRepository{suppressed: make(map[string]string)}
+			if tt.wantAction == KeywordOptIn {
+				repo.suppressed[sender] = "STOP"
+			}
+			svc := NewSuppressionService(repo, &fakeUserRepository{})
+
+			action, err := svc.HandleInbound(uuid.New(), uuid.New(), sender, tt.content)
+			if err != nil {
+				t.Fatalf("HandleInbound: %v", err)
+			}
+			if action != tt.wantAction {
+				t.Errorf("action = %q, want %q", action, tt.wantAction)
+			}
+
+			_, suppressed := repo.suppressed[sender]
+			if want := tt.wantAction == KeywordOptOut; suppressed != want {
+				t.Errorf("suppressed = %v, want %v", suppressed, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+)
+
+// Chart buckets, named after the Postgres date_trunc units they map to.
+const (
+	TelemetryBucketMinute = "minute"
+	TelemetryBucketHour   = "hour"
+	TelemetryBucketDay    = "day"
+)
+
+const (
+	// TelemetryRawRetention is how long every heartbeat is kept before it
+	// is folded into an hourly row
+	TelemetryRawRetention = 48 * time.Hour
+	// TelemetryHourlyRetention is how far back device history goes
+	TelemetryHourlyRetention = 90 * 24 * time.Hour
+
+	// maxTelemetryPoints bounds the points of one series so a wide range
+	// needs a coarser bucket
+	maxTelemetryPoints = 2000
+)
+
+var (
+	ErrInvalidTelemetryRange  = errors.New("from must be before to and within the last 90 days")
+	ErrInvalidTelemetryBucket = errors.New("bucket must be minute, hour or day and yield at most 2000 points")
+)
+
+var telemetryBucketSizes = map[string]time.Duration{
+	TelemetryBucketMinute: time.Minute,
+	TelemetryBucketHour:   time.Hour,
+	TelemetryBucketDay:    24 * time.Hour,
+}
+
+// TelemetrySeries is a device's chart data for one range. UptimePercent is
+// the share of the range, from the device's creation on, in which it sent
+// heartbeats.
+type TelemetrySeries struct {
+	DeviceID      uuid.UUID
+	From          time.Time
+	To            time.Time
+	Bucket        string
+	UptimePercent float64
+	Points        []models.TelemetryPoint
+}
+
+// DeviceHealth sums up one device for the fleet overview. Latest is nil for
+// a device that never sent a heartbeat.
+type DeviceHealth struct {
+	Device        models.Device
+	Latest        *models.DeviceTelemetry
+	UptimePercent float64
+}
+
+type TelemetryService interface {
+	// Record stores one heartbeat of a connected device
+	Record(deviceID uuid.UUID, point *models.DeviceTelemetry) error
+	// Series returns chart points for [from, to); an empty bucket picks one
+	// that suits the range
+	Series(userID, deviceID uuid.UUID, from, to time.Time, bucket string) (*TelemetrySeries, error)
+	// Health reports every device of the user with its uptime over the
+	// last period
+	Health(userID uuid.UUID, period time.Duration) ([]DeviceHealth, error)
+	// Compact downsamples raw rows past TelemetryRawRetention and drops
+	// hourly rows past TelemetryHourlyRetention. It is run periodically by
+	// the worker; see workers.TypeTelemetryCompact
+	Compact() error
+}
+
+type telemetryService struct {
+	repo       repository.TelemetryRepository
+	deviceRepo repository.DeviceRepository
+}
+
+func NewTelemetryService(repo repository.TelemetryRepository, deviceRepo repository.DeviceRepository) TelemetryService {
+	return &telemetryService{
+		repo:       repo,
+		deviceRepo: deviceRepo,
+	}
+}
+
+func (s *telemetryService) Record(deviceID uuid.UUID, point *models.DeviceTelemetry) error {
+	point.DeviceID = deviceID
+	point.Resolution = models.TelemetryRaw
+	return s.repo.Create(point)
+}
+
+func (s *telemetryService) Series(userID, deviceID uuid.UUID, from, to time.Time, bucket string) (*TelemetrySeries, error) {
+	now := time.Now()
+	if !from.Before(to) || from.Before(now.Add(-TelemetryHourlyRetention)) {
+		return nil, ErrInvalidTelemetryRange
+	}
+
+	span := to.Sub(from)
+	if bucket == "" {
+		bucket = defaultTelemetryBucket(span)
+	}
+	size, ok := telemetryBucketSizes[bucket]
+	if !ok || span/size > maxTelemetryPoints {
+		return nil, ErrInvalidTelemetryBucket
+	}
+
+	device, err := s.device(userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := s.repo.Series(deviceID, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	minutes, err := s.repo.OnlineMinutes([]uuid.UUID{deviceID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TelemetrySeries{
+		DeviceID:      deviceID,
+		From:          from,
+		To:            to,
+		Bucket:        bucket,
+		UptimePercent: uptimePercent(device, from, to, now, minutes[deviceID]),
+		Points:        points,
+	}, nil
+}
+
+func (s *telemetryService) Health(userID uuid.UUID, period time.Duration) ([]DeviceHealth, error) {
+	devices, err := s.deviceRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return []DeviceHealth{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(devices))
+	for i := range devices {
+		ids[i] = devices[i].ID
+	}
+
+	latest, err := s.repo.Latest(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from := now.Add(-period)
+	minutes, err := s.repo.OnlineMinutes(ids, from, now)
+	if err != nil {
+		return nil, err
+	}
+
+	health := make([]DeviceHealth, len(devices))
+	for i := range devices {
+		health[i] = DeviceHealth{
+			Device:        devices[i],
+			Latest:        latest[devices[i].ID],
+			UptimePercent: uptimePercent(&devices[i], from, now, now, minutes[devices[i].ID]),
+		}
+	}
+	return health, nil
+}
+
+func (s *telemetryService) Compact() error {
+	now := time.Now()
+
+	compacted, err := s.repo.Downsample(now.Add(-TelemetryRawRetention).Truncate(time.Hour))
+	if err != nil {
+		return err
+	}
+	if compacted > 0 {
+		log.Printf("[telemetry] downsampled %d heartbeats", compacted)
+	}
+
+	deleted, err := s.repo.DeleteBefore(models.TelemetryHourly, now.Add(-TelemetryHourlyRetention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("[telemetry] deleted %d hourly rows past retention", deleted)
+	}
+	return nil
+}
+
+func (s *telemetryService) device(userID, deviceID uuid.UUID) (*models.Device, error) {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err != nil {
+		if errors.Is(err, repository.ErrDeviceNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+	if device.UserID != userID {
+		return nil, ErrDeviceNotFound
+	}
+	return device, nil
+}
+
+func defaultTelemetryBucket(span time.Duration) string {
+	switch {
+	case span <= 6*time.Hour:
+		return TelemetryBucketMinute
+	case span <= 14*24*time.Hour:
+		return TelemetryBucketHour
+	default:
+		return TelemetryBucketDay
+	}
+}
+
+// uptimePercent compares the minutes with a heartbeat to the minutes of the
+// range the device could have been online: not before it was created and
+// not in the future.
+func uptimePercent(device *models.Device, from, to, now time.Time, onlineMinutes int64) float64 {
+	if device.CreatedAt.After(from) {
+		from = device.CreatedAt
+	}
+	if to.After(now) {
+		to = now
+	}
+
+	window := to.Sub(from).Minutes()
+	if window < 1 {
+		return 0
+	}
+
+	percent := float64(onlineMinutes) / window * 100
+	if percent > 100 {
+		percent = 100
+	}
+	return math.Round(percent*100) / 100
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/sms"
+)
+
+const (
+	maxTemplateNameLength = 100
+	maxLocaleTagLength    = 20
+)
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrDuplicateTemplateName = errors.New("template name already exists")
+	ErrInvalidTemplate       = errors.New("invalid template")
+	ErrMissingVariable       = errors.New("missing template variable")
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+type TemplateRequest struct {
+	Name     string
+	Body     string
+	Locales  map[string]string
+	Defaults map[string]string
+}
+
+// ResolvedTemplate is a template narrowed to one locale, ready to render.
+type ResolvedTemplate struct {
+	TemplateID uint
+	Locale     string
+	Body       string
+	Defaults   map[string]string
+}
+
+// RenderedMessage is the final text of a template plus how it goes over the
+// air.
+type RenderedMessage struct {
+	Content string
+	Locale  string
+	sms.Info
+}
+
+type TemplateService interface {
+	List(userID uuid.UUID) ([]models.MessageTemplate, error)
+	Get(id uint, userID uuid.UUID) (*models.MessageTemplate, error)
+	Create(userID uuid.UUID, req *TemplateRequest) (*models.MessageTemplate, error)
+	Update(id uint, userID uuid.UUID, req *TemplateRequest) (*models.MessageTemplate, error)
+	Delete(id uint, userID uuid.UUID) error
+	Resolve(id uint, userID uuid.UUID, locale string) (*ResolvedTemplate, error)
+	Render(id uint, userID uuid.UUID, locale string, vars map[string]string) (*RenderedMessage, error)
+}
+
+type templateService struct {
+	repo repository.TemplateRepository
+}
+
+func NewTemplateService(repo repository.TemplateRepository) TemplateService {
+	return &templateService{repo: repo}
+}
+
+func (s *templateService) List(userID uuid.UUID) ([]models.MessageTemplate, error) {
+	return s.repo.FindByUserID(userID)
+}
+
+func (s *templateService) Get(id uint, userID uuid.UUID) (*models.MessageTemplate, error) {
+	template, err := s.repo.FindByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+
+	if template.UserID != userID {
+		return nil, ErrTemplateNotFound
+	}
+
+	return template, nil
+}
+
+func (s *templateService) Create(userID uuid.UUID, req *TemplateRequest) (*models.MessageTemplate, error) {
+	if err := validateTemplate(req); err != nil {
+		return nil, err
+	}
+
+	template := &models.MessageTemplate{
+		UserID:   userID,
+		Name:     strings.TrimSpace(req.Name),
+		Body:     req.Body,
+		Locales:  normalizeLocales(req.Locales),
+		Defaults: req.Defaults,
+	}
+
+	if err := s.repo.Create(template); err != nil {
+		if errors.Is(err, repository.ErrDuplicateTemplateName) {
+			return nil, ErrDuplicateTemplateName
+		}
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (s *templateService) Update(id uint, userID uuid.UUID, req *TemplateRequest) (*models.MessageTemplate, error) {
+	template, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTemplate(req); err != nil {
+		return nil, err
+	}
+
+	template.Name = strings.TrimSpace(req.Name)
+	template.Body = req.Body
+	template.Locales = normalizeLocales(req.Locales)
+	template.Defaults = req.Defaults
+
+	if err := s.repo.Update(template); err != nil {
+		if errors.Is(err, repository.ErrDuplicateTemplateName) {
+			return nil, ErrDuplicateTemplateName
+		}
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (s *templateService) Delete(id uint, userID uuid.UUID) error {
+	if _, err := s.Get(id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// Resolve picks the body for locale: an exact variant first, then one for
+// the bare language ("vi" for "vi-VN"), then the template's default body.
+func (s *templateService) Resolve(id uint, userID uuid.UUID, locale string) (*ResolvedTemplate, error) {
+	template, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := &ResolvedTemplate{
+		TemplateID: template.ID,
+		Body:       template.Body,
+		Defaults:   template.Defaults,
+	}
+
+	tag := strings.ToLower(strings.TrimSpace(locale))
+	if tag == "" {
+		return resolved, nil
+	}
+
+	if body, ok := template.Locales[tag]; ok {
+		resolved.Locale, resolved.Body = tag, body
+		return resolved, nil
+	}
+
+	if lang, _, found := strings.Cut(tag, "-"); found {
+		if body, ok := template.Locales[lang]; ok {
+			resolved.Locale, resolved.Body = lang, body
+		}
+	}
+
+	return resolved, nil
+}
+
+func (s *templateService) Render(id uint, userID uuid.UUID, locale string, vars map[string]string) (*RenderedMessage, error) {
+	resolved, err := s.Resolve(id, userID, locale)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.Render(vars)
+}
+
+// Variables returns vars layered over the template's default values.
+func (t *ResolvedTemplate) Variables(vars map[string]string) map[string]string {
+	merged := make(map[string]string, len(t.Defaults)+len(vars))
+	for name, value := range t.Defaults {
+		merged[name] = value
+	}
+	for name, value := range vars {
+		merged[name] = value
+	}
+	return merged
+}
+
+func (t *ResolvedTemplate) Render(vars map[string]string) (*RenderedMessage, error) {
+	content, err := RenderContent(t.Body, t.Variables(vars))
+	if err != nil {
+		return nil, err
+	}
+
+	return &RenderedMessage{
+		Content: content,
+		Locale:  t.Locale,
+		Info:    sms.Analyze(content),
+	}, nil
+}
+
+// Placeholders lists the distinct variable names used in body, sorted.
+func Placeholders(body string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderContent fills {{name}} placeholders from vars. Every placeholder must
+// have a value; the error names all that are missing.
+func RenderContent(content string, vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range Placeholders(content) {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(missing, ", "))
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		return vars[placeholderPattern.FindStringSubmatch(match)[1]]
+	}), nil
+}
+
+func validateTemplate(req *TemplateRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxTemplateNameLength {
+		return fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidTemplate)
+	}
+
+	if err := validateTemplateBody(req.Body); err != nil {
+		return err
+	}
+
+	for tag, body := range req.Locales {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > maxLocaleTagLength {
+			return fmt.Errorf("%w: invalid locale %q", ErrInvalidTemplate, tag)
+		}
+		if err := validateTemplateBody(body); err != nil {
+			return fmt.Errorf("locale %s: %w", tag, err)
+		}
+	}
+
+	return nil
+}
+
+// validateTemplateBody rejects empty bodies and "{{" sequences that do not
+// form a valid placeholder, which would otherwise be sent verbatim.
+func validateTemplateBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidTemplate)
+	}
+	if _, err := CheckContent(body); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	stripped := placeholderPattern.ReplaceAllString(body, "")
+	if strings.Contains(stripped, "{{") || strings.Contains(stripped, "}}") {
+		return fmt.Errorf("%w: malformed placeholder", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+func normalizeLocales(locales map[string]string) map[string]string {
+	normalized := make(map[string]string, len(locales))
+	for tag, body := range locales {
+		normalized[strings.ToLower(strings.TrimSpace(tag))] = body
+	}
+	return normalized
+}
@@ -4,10 +4,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/repository"
+	"github.com/octopuslowtech/tinghook-project/backend/pkg/phone"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -27,6 +29,11 @@ type UserService interface {
 	GetByID(id uuid.UUID) (*models.User, error)
 	GetByAPIKey(apiKey string) (*models.User, error)
 	RegenerateAPIKey(id uuid.UUID) (string, error)
+	UpdateStatusCallback(id uuid.UUID, url string) (*models.User, error)
+	EnsureCallbackSecret(id uuid.UUID) (string, error)
+	UpdateRoutingStrategy(id uuid.UUID, strategy string) error
+	UpdateFailoverMaxAttempts(id uuid.UUID, maxAttempts int) error
+	UpdateDefaultCountry(id uuid.UUID, country string) (string, error)
 }
 
 type userService struct {
@@ -55,6 +62,10 @@ func (s *userService) Register(email, password string) (*models.User, error) {
 		APIKey:           apiKey,
 		SubscriptionPlan: "free",
 		Credits:          0,
+		RoutingStrategy:  models.RoutingRoundRobin,
+
+		FailoverMaxAttempts: 1,
+		DefaultCountry:      DefaultPhoneCountry,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
@@ -104,6 +115,75 @@ func (s *userService) RegenerateAPIKey(id uuid.UUID) (string, error) {
 	return newKey, nil
 }
 
+// UpdateStatusCallback sets the default status callback URL, generating the
+// signing secret the first time one is needed. An empty url clears it.
+func (s *userService) UpdateStatusCallback(id uuid.UUID, url string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := user.CallbackSecret
+	if secret == "" {
+		if secret, err = generateAPIKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.UpdateStatusCallback(id, url, secret); err != nil {
+		return nil, err
+	}
+
+	user.StatusCallbackURL = url
+	user.CallbackSecret = secret
+	return user, nil
+}
+
+// EnsureCallbackSecret returns the user's callback signing secret, creating it
+// for accounts that have only ever used per-request callback URLs.
+func (s *userService) EnsureCallbackSecret(id uuid.UUID) (string, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return "", err
+	}
+	if user.CallbackSecret != "" {
+		return user.CallbackSecret, nil
+	}
+
+	user, err = s.UpdateStatusCallback(id, user.StatusCallbackURL)
+	if err != nil {
+		return "", err
+	}
+	return user.CallbackSecret, nil
+}
+
+func (s *userService) UpdateRoutingStrategy(id uuid.UUID, strategy string) error {
+	if err := ValidateRoutingStrategy(strategy); err != nil {
+		return err
+	}
+	return s.userRepo.UpdateRoutingStrategy(id, strategy)
+}
+
+func (s *userService) UpdateFailoverMaxAttempts(id uuid.UUID, maxAttempts int) error {
+	if maxAttempts < 1 || maxAttempts > MaxFailoverAttempts {
+		return ErrInvalidMaxAttempts
+	}
+	return s.userRepo.UpdateFailoverMaxAttempts(id, maxAttempts)
+}
+
+// UpdateDefaultCountry stores the region used to read national-format phone
+// numbers and returns it upper-cased.
+func (s *userService) UpdateDefaultCountry(id uuid.UUID, country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if !phone.SupportedRegion(country) {
+		return "", ErrUnsupportedCountry
+	}
+	if err := s.userRepo.UpdateDefaultCountry(id, country); err != nil {
+		return "", err
+	}
+	return country, nil
+}
+
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
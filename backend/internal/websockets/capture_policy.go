@@ -0,0 +1,57 @@
+package websockets
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+func NewCapturePolicyMessage(policy *models.CapturePolicy) (*Message, error) {
+	data := &CapturePolicyData{
+		Mode:     CaptureModeAll,
+		Packages: []string{},
+	}
+	if policy != nil {
+		data.Mode = policy.Mode
+		data.Packages = policy.Packages
+	}
+	return NewMessage(MsgTypeCapturePolicy, data)
+}
+
+// PushCapturePolicy sends the effective capture policy to the online devices
+// affected by a change. A nil deviceID means the account-wide policy changed,
+// so every online device of the user is refreshed.
+func PushCapturePolicy(hub *Hub, policyService services.CapturePolicyService, userID uuid.UUID, deviceID *uuid.UUID) {
+	targets := hub.GetOnlineDevices(userID)
+	if deviceID != nil {
+		targets = nil
+		if hub.GetDeviceStatus(*deviceID) {
+			targets = []uuid.UUID{*deviceID}
+		}
+	}
+
+	for _, target := range targets {
+		policy, err := policyService.Effective(userID, target)
+		if err != nil {
+			log.Printf("failed to resolve capture policy for device %s: %v", target, err)
+			continue
+		}
+
+		msg, err := NewCapturePolicyMessage(policy)
+		if err != nil {
+			log.Printf("failed to create capture policy message: %v", err)
+			continue
+		}
+
+		err = hub.SendToDevice(target, msg)
+		if errors.Is(err, ErrUnsupportedCommand) {
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to push capture policy to device %s: %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package websockets
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// presenceTTL bounds how long a crashed instance keeps its devices marked
+	// online; live instances refresh presence every presenceRefresh
+	presenceTTL     = 90 * time.Second
+	presenceRefresh = 30 * time.Second
+)
+
+// Envelope is a frame routed between hub instances. A nil DeviceID is a
+// broadcast to every device on the receiving instance.
+type Envelope struct {
+	DeviceID *uuid.UUID `json:"device_id,omitempty"`
+	// Type lets the receiving instance gate the frame on the device's
+	// negotiated protocol without decoding Payload
+	Type    string `json:"type"`
+	Payload []byte `json:"payload"`
+}
+
+// Cluster shares device presence between hub instances and carries frames to
+// the instance holding a device's connection.
+type Cluster interface {
+	// InstanceID identifies this hub within the cluster
+	InstanceID() string
+
+	// Join marks the device as connected to this instance under sessionID
+	// with the capabilities it negotiated; calling it again refreshes the
+	// presence TTL
+	Join(ctx context.Context, deviceID, userID, sessionID uuid.UUID, capabilities []string) error
+	// Leave clears the device's presence unless another session, on this
+	// instance or another, took it over
+	Leave(ctx context.Context, deviceID, userID, sessionID uuid.UUID) error
+	// Locate returns the instance the device is connected to
+	Locate(ctx context.Context, deviceID uuid.UUID) (string, bool, error)
+	// Capabilities returns what the device negotiated with the instance
+	// holding it, so other instances can gate frames before routing them
+	Capabilities(ctx context.Context, deviceID uuid.UUID) ([]string, bool, error)
+	OnlineDevices(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+
+	// Publish delivers an envelope to one instance, or to all instances when
+	// instanceID is empty
+	Publish(ctx context.Context, instanceID string, env *Envelope) error
+	// Subscribe calls handler for every envelope addressed to this instance
+	// until ctx is cancelled
+	Subscribe(ctx context.Context, handler func(env *Envelope)) error
+}
+
+// NewInstanceID returns an ID that stays unique across restarts of the same
+// host.
+func NewInstanceID(hostname string) string {
+	if hostname == "" {
+		return uuid.NewString()
+	}
+	return hostname + "-" + uuid.NewString()[:8]
+}
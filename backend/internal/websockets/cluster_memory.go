@@ -0,0 +1,123 @@
+package websockets
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type memoryPresence struct {
+	instanceID string
+	userID     uuid.UUID
+	sessionID  uuid.UUID
+
+	capabilities []string
+}
+
+// MemoryBroker is an in-process stand-in for Redis. Hubs created from the
+// same broker behave like replicas sharing one cluster, which is what tests
+// and single-binary deployments need.
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	presence    map[uuid.UUID]memoryPresence
+	subscribers map[string][]func(env *Envelope)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		presence:    make(map[uuid.UUID]memoryPresence),
+		subscribers: make(map[string][]func(env *Envelope)),
+	}
+}
+
+// Node returns the Cluster view of one instance.
+func (b *MemoryBroker) Node(instanceID string) Cluster {
+	return &memoryCluster{broker: b, instanceID: instanceID}
+}
+
+type memoryCluster struct {
+	broker     *MemoryBroker
+	instanceID string
+}
+
+func (c *memoryCluster) InstanceID() string {
+	return c.instanceID
+}
+
+func (c *memoryCluster) Join(ctx context.Context, deviceID, userID, sessionID uuid.UUID, capabilities []string) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.broker.presence[deviceID] = memoryPresence{
+		instanceID:   c.instanceID,
+		userID:       userID,
+		sessionID:    sessionID,
+		capabilities: capabilities,
+	}
+	return nil
+}
+
+func (c *memoryCluster) Leave(ctx context.Context, deviceID, userID, sessionID uuid.UUID) error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	if p, ok := c.broker.presence[deviceID]; ok && p.instanceID == c.instanceID && p.sessionID == sessionID {
+		delete(c.broker.presence, deviceID)
+	}
+	return nil
+}
+
+func (c *memoryCluster) Locate(ctx context.Context, deviceID uuid.UUID) (string, bool, error) {
+	c.broker.mu.RLock()
+	defer c.broker.mu.RUnlock()
+	p, ok := c.broker.presence[deviceID]
+	return p.instanceID, ok, nil
+}
+
+func (c *memoryCluster) Capabilities(ctx context.Context, deviceID uuid.UUID) ([]string, bool, error) {
+	c.broker.mu.RLock()
+	defer c.broker.mu.RUnlock()
+	p, ok := c.broker.presence[deviceID]
+	return p.capabilities, ok, nil
+}
+
+func (c *memoryCluster) OnlineDevices(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	c.broker.mu.RLock()
+	defer c.broker.mu.RUnlock()
+
+	var devices []uuid.UUID
+	for deviceID, p := range c.broker.presence {
+		if p.userID == userID {
+			devices = append(devices, deviceID)
+		}
+	}
+	return devices, nil
+}
+
+func (c *memoryCluster) Publish(ctx context.Context, instanceID string, env *Envelope) error {
+	c.broker.mu.RLock()
+	var handlers []func(env *Envelope)
+	for id, subs := range c.broker.subscribers {
+		if instanceID == "" || id == instanceID {
+			handlers = append(handlers, subs...)
+		}
+	}
+	c.broker.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(env)
+	}
+	return nil
+}
+
+func (c *memoryCluster) Subscribe(ctx context.Context, handler func(env *Envelope)) error {
+	c.broker.mu.Lock()
+	c.broker.subscribers[c.instanceID] = append(c.broker.subscribers[c.instanceID], handler)
+	c.broker.mu.Unlock()
+
+	<-ctx.Done()
+
+	c.broker.mu.Lock()
+	delete(c.broker.subscribers, c.instanceID)
+	c.broker.mu.Unlock()
+	return nil
+}
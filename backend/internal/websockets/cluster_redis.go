@@ -0,0 +1,200 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKeyPrefix        = "tinghook:ws:"
+	redisBroadcastChannel = redisKeyPrefix + "broadcast"
+)
+
+// leaveScript drops the device's presence only while it still points at the
+// calling session, so a reconnect to this or another replica is not undone.
+var leaveScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1], KEYS[3])
+	redis.call("SREM", KEYS[2], ARGV[2])
+end
+return 0
+`)
+
+type redisCluster struct {
+	client     *redis.Client
+	instanceID string
+}
+
+// NewRedisCluster shares presence through Redis keys with a TTL and routes
+// frames over pub/sub, one channel per instance plus a broadcast channel.
+func NewRedisCluster(client *redis.Client, instanceID string) Cluster {
+	return &redisCluster{client: client, instanceID: instanceID}
+}
+
+func deviceKey(deviceID uuid.UUID) string {
+	return redisKeyPrefix + "device:" + deviceID.String()
+}
+
+// capabilitiesKey holds the device's negotiated capabilities, comma
+// separated, next to its presence key and with the same TTL.
+func capabilitiesKey(deviceID uuid.UUID) string {
+	return redisKeyPrefix + "caps:" + deviceID.String()
+}
+
+func userKey(userID uuid.UUID) string {
+	return redisKeyPrefix + "user:" + userID.String()
+}
+
+func instanceChannel(instanceID string) string {
+	return redisKeyPrefix + "instance:" + instanceID
+}
+
+// presenceValue is what a device key holds: the instance and the session
+// that owns the device's socket.
+func presenceValue(instanceID string, sessionID uuid.UUID) string {
+	return instanceID + "|" + sessionID.String()
+}
+
+func presenceInstance(value string) string {
+	if i := strings.LastIndexByte(value, '|'); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
+func (c *redisCluster) InstanceID() string {
+	return c.instanceID
+}
+
+func (c *redisCluster) Join(ctx context.Context, deviceID, userID, sessionID uuid.UUID, capabilities []string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, deviceKey(deviceID), presenceValue(c.instanceID, sessionID), presenceTTL)
+		pipe.Set(ctx, capabilitiesKey(deviceID), strings.Join(capabilities, ","), presenceTTL)
+		pipe.SAdd(ctx, userKey(userID), deviceID.String())
+		pipe.Expire(ctx, userKey(userID), presenceTTL)
+		return nil
+	})
+	return err
+}
+
+func (c *redisCluster) Leave(ctx context.Context, deviceID, userID, sessionID uuid.UUID) error {
+	return leaveScript.Run(ctx, c.client,
+		[]string{deviceKey(deviceID), userKey(userID), capabilitiesKey(deviceID)},
+		presenceValue(c.instanceID, sessionID), deviceID.String(),
+	).Err()
+}
+
+func (c *redisCluster) Locate(ctx context.Context, deviceID uuid.UUID) (string, bool, error) {
+	value, err := c.client.Get(ctx, deviceKey(deviceID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return presenceInstance(value), true, nil
+}
+
+func (c *redisCluster) Capabilities(ctx context.Context, deviceID uuid.UUID) ([]string, bool, error) {
+	value, err := c.client.Get(ctx, capabilitiesKey(deviceID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if value == "" {
+		return nil, true, nil
+	}
+	return strings.Split(value, ","), true, nil
+}
+
+// OnlineDevices reads the user's device set and keeps the members whose
+// presence key has not expired, pruning the rest.
+func (c *redisCluster) OnlineDevices(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	members, err := c.client.SMembers(ctx, userKey(userID)).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	keys := make([]string, 0, len(members))
+	for _, member := range members {
+		id, err := uuid.Parse(member)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		keys = append(keys, deviceKey(id))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []uuid.UUID
+	var stale []interface{}
+	for i, value := range values {
+		if value == nil {
+			stale = append(stale, ids[i].String())
+			continue
+		}
+		devices = append(devices, ids[i])
+	}
+	if len(stale) > 0 {
+		if err := c.client.SRem(ctx, userKey(userID), stale...).Err(); err != nil {
+			log.Printf("[cluster] failed to prune stale devices of user %s: %v", userID, err)
+		}
+	}
+	return devices, nil
+}
+
+func (c *redisCluster) Publish(ctx context.Context, instanceID string, env *Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	channel := redisBroadcastChannel
+	if instanceID != "" {
+		channel = instanceChannel(instanceID)
+	}
+	return c.client.Publish(ctx, channel, payload).Err()
+}
+
+func (c *redisCluster) Subscribe(ctx context.Context, handler func(env *Envelope)) error {
+	pubsub := c.client.Subscribe(ctx, instanceChannel(c.instanceID), redisBroadcastChannel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var env Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Printf("[cluster] failed to decode envelope: %v", err)
+				continue
+			}
+			handler(&env)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+// startClusterHubs runs one hub per instance ID on a shared broker and waits
+// until each has subscribed, so envelopes published afterwards arrive.
+func startClusterHubs(t *testing.T, instanceIDs ...string) (*MemoryBroker, []*Hub) {
+	t.Helper()
+	broker := NewMemoryBroker()
+	hubs := make([]*Hub, len(instanceIDs))
+	for i, id := range instanceIDs {
+		hubs[i] = startHub(t, NewClusterHub(broker.Node(id)))
+	}
+
+	eventually(t, "hubs to subscribe", func() bool {
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+		for _, id := range instanceIDs {
+			if len(broker.subscribers[id]) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	return broker, hubs
+}
+
+func presenceOf(broker *MemoryBroker, deviceID uuid.UUID) (memoryPresence, bool) {
+	broker.mu.RLock()
+	defer broker.mu.RUnlock()
+	p, ok := broker.presence[deviceID]
+	return p, ok
+}
+
+// receive waits for a frame of msgType on conn, skipping other frames such
+// as broadcasts.
+func receive(t *testing.T, conn *DeviceConnection, msgType string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case frame := <-conn.Send:
+			var msg Message
+			if err := json.Unmarshal(frame, &msg); err != nil {
+				t.Fatalf("invalid frame: %v", err)
+			}
+			if msg.Type == msgType {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no %s frame reached device %s", msgType, conn.DeviceID)
+		}
+	}
+}
+
+func TestClusterSendReachesDeviceOnOtherInstance(t *testing.T) {
+	_, hubs := startClusterHubs(t, "a", "b")
+	a, b := hubs[0], hubs[1]
+
+	deviceID, userID := uuid.New(), uuid.New()
+	conn := newTestConn(t, a, deviceID, userID)
+	a.RegisterDevice(conn)
+
+	eventually(t, "device to be visible from b", func() bool { return b.GetDeviceStatus(deviceID) })
+
+	if online := b.GetOnlineDevices(userID); len(online) != 1 || online[0] != deviceID {
+		t.Errorf("GetOnlineDevices on b = %v, want [%s]", online, deviceID)
+	}
+	if _, ok := b.GetConnection(deviceID); ok {
+		t.Error("b holds a local connection for a device connected to a")
+	}
+
+	if err := b.DispatchSMS(deviceID, "req-1", "+84912345678", "hello", 0, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DispatchSMS from b: %v", err)
+	}
+
+	receive(t, conn, MsgTypeSendSMS)
+
+	// a frame the device did not negotiate is dropped on a, not delivered
+	if err := b.SendToDevice(deviceID, &Message{Type: MsgTypeRequestStatus}); err != nil {
+		t.Fatalf("SendToDevice from b: %v", err)
+	}
+	select {
+	case frame := <-conn.Send:
+		t.Fatalf("ungated delivery of %s", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClusterSendToUnknownDevice(t *testing.T) {
+	_, hubs := startClusterHubs(t, "a", "b")
+
+	if err := hubs[1].SendToDevice(uuid.New(), &Message{Type: MsgTypeSendSMS}); err != ErrDeviceNotConnected {
+		t.Errorf("SendToDevice = %v, want %v", err, ErrDeviceNotConnected)
+	}
+}
+
+func TestClusterReconnectSupersedesAcrossInstances(t *testing.T) {
+	broker, hubs := startClusterHubs(t, "a", "b")
+	a, b := hubs[0], hubs[1]
+
+	var unregisteredOnA sessionRecorder
+	a.OnUnregister(unregisteredOnA.record)
+
+	deviceID, userID := uuid.New(), uuid.New()
+	old := newTestConn(t, a, deviceID, userID)
+	a.RegisterDevice(old)
+	eventually(t, "presence on a", func() bool {
+		p, ok := presenceOf(broker, deviceID)
+		return ok && p.instanceID == "a"
+	})
+
+	time.Sleep(time.Millisecond)
+	successor := newTestConn(t, b, deviceID, userID)
+	b.RegisterDevice(successor)
+
+	eventually(t, "old session on a to be closed", func() bool { return isClosed(old) })
+	if code := closeCode(old); code != CloseSessionReplaced {
+		t.Errorf("old session close code = %d, want %d", code, CloseSessionReplaced)
+	}
+	if _, ok := a.GetConnection(deviceID); ok {
+		t.Error("a still holds the superseded session")
+	}
+
+	eventually(t, "presence to move to b", func() bool {
+		p, ok := presenceOf(broker, deviceID)
+		return ok && p.instanceID == "b" && p.sessionID == successor.SessionID
+	})
+
+	// the old socket's read pump ends late and unregisters it on a
+	a.unregister <- old
+	a.Broadcast(&Message{Type: MsgTypePong})
+
+	time.Sleep(50 * time.Millisecond)
+	if p, ok := presenceOf(broker, deviceID); !ok || p.sessionID != successor.SessionID {
+		t.Fatal("late unregister on a cleared the presence held by b")
+	}
+	if n := unregisteredOnA.count(old.SessionID); n != 0 {
+		t.Errorf("OnUnregister ran %d times on a for the superseded session, want 0", n)
+	}
+	if isClosed(successor) {
+		t.Fatal("successor on b was closed")
+	}
+
+	// sends from a now reach the device through b
+	if err := a.DispatchSMS(deviceID, "req-2", "+84912345678", "hello", 0, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DispatchSMS from a: %v", err)
+	}
+	receive(t, successor, MsgTypeSendSMS)
+}
+
+// TestClusterStaleSupersedeKeepsNewerSession covers a supersede frame that
+// arrives after the device already came back to the same instance.
+func TestClusterStaleSupersedeKeepsNewerSession(t *testing.T) {
+	_, hubs := startClusterHubs(t, "a", "b")
+	a := hubs[0]
+
+	deviceID, userID := uuid.New(), uuid.New()
+	since, err := time.Now().MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond)
+	conn := newTestConn(t, a, deviceID, userID)
+	a.RegisterDevice(conn)
+	eventually(t, "registration on a", func() bool { return a.GetDeviceStatus(deviceID) })
+
+	cluster := hubs[1].cluster
+	env := &Envelope{DeviceID: &deviceID, Type: envelopeSupersede, Payload: since}
+	if err := cluster.Publish(context.Background(), "a", env); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	if isClosed(conn) {
+		t.Fatal("newer session was closed by a stale supersede")
+	}
+	if current, ok := a.GetConnection(deviceID); !ok || current != conn {
+		t.Fatal("newer session is no longer current")
+	}
+}
+
+// recordingOutbox keeps recorded commands in memory.
+type recordingOutbox struct {
+	mu       sync.Mutex
+	recorded []models.DeviceCommand
+}
+
+func (o *recordingOutbox) Record(cmd *models.DeviceCommand) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.recorded = append(o.recorded, *cmd)
+	return nil
+}
+
+func (o *recordingOutbox) Discard(id uuid.UUID) error { return nil }
+
+func (o *recordingOutbox) Resendable(deviceID uuid.UUID) ([]models.DeviceCommand, error) {
+	return nil, nil
+}
+
+func (o *recordingOutbox) MarkResent(id uuid.UUID) error { return nil }
+
+func (o *recordingOutbox) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.recorded)
+}
+
+func TestClusterDispatchCommandChecksRemoteCapabilities(t *testing.T) {
+	_, hubs := startClusterHubs(t, "a", "b")
+	a, b := hubs[0], hubs[1]
+	outbox := &recordingOutbox{}
+	b.UseOutbox(outbox)
+
+	deviceID, userID := uuid.New(), uuid.New()
+	protocol, err := Negotiate(ProtocolV2, []string{CapCommandAck, CapStatusReport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := newTestConn(t, a, deviceID, userID)
+	conn.Protocol = protocol
+	a.RegisterDevice(conn)
+	eventually(t, "device to be visible from b", func() bool { return b.GetDeviceStatus(deviceID) })
+
+	expiresAt := time.Now().Add(time.Minute)
+	if _, err := b.DispatchCommand(deviceID, MsgTypeRunUSSD, struct{}{}, expiresAt); !errors.Is(err, services.ErrCommandNotSupported) {
+		t.Fatalf("DispatchCommand(RUN_USSD) error = %v, want %v", err, services.ErrCommandNotSupported)
+	}
+	if n := outbox.count(); n != 0 {
+		t.Fatalf("unsupported command was recorded %d times", n)
+	}
+
+	if _, err := b.DispatchCommand(deviceID, MsgTypeRequestStatus, struct{}{}, expiresAt); err != nil {
+		t.Fatalf("DispatchCommand(REQUEST_STATUS): %v", err)
+	}
+	receive(t, conn, MsgTypeRequestStatus)
+
+	if _, err := b.DispatchCommand(uuid.New(), MsgTypeRequestStatus, struct{}{}, expiresAt); !errors.Is(err, services.ErrDeviceOffline) {
+		t.Errorf("DispatchCommand to an unknown device error = %v, want %v", err, services.ErrDeviceOffline)
+	}
+}
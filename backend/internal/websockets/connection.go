@@ -16,6 +16,14 @@ const (
 	maxMessageSize = 8192
 )
 
+// Close codes sent to devices when the server ends their session.
+const (
+	// CloseSessionReplaced closes a socket superseded by a newer connection
+	// of the same device; the device is already connected and must not
+	// reconnect on its behalf
+	CloseSessionReplaced = 4001
+)
+
 type MessageHandler func(conn *DeviceConnection, msg *Message)
 
 func (c *DeviceConnection) ReadPump(handler MessageHandler) {
@@ -64,7 +72,7 @@ func (c *DeviceConnection) WritePump() {
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.Conn.WriteMessage(websocket.CloseMessage, c.closeMessage())
 				return
 			}
 
@@ -91,12 +99,64 @@ func (c *DeviceConnection) SendMessage(msg *Message) error {
 		return err
 	}
 
+	return c.deliver(msg.Type, msgBytes)
+}
+
+// deliver enqueues a frame unless the negotiated protocol rules its type out.
+func (c *DeviceConnection) deliver(msgType string, msgBytes []byte) error {
+	if !c.Protocol.Allows(msgType) {
+		return ErrUnsupportedCommand
+	}
+	if !c.enqueue(msgBytes) {
+		return ErrDeviceNotConnected
+	}
+	return nil
+}
+
+// enqueue hands a frame to the write pump. It reports false when the buffer
+// is full or the connection is already closed, so goroutines that outlive
+// the socket (e.g. acking an event after a database write) cannot panic on
+// the closed channel.
+func (c *DeviceConnection) enqueue(msgBytes []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return false
+	}
+
 	select {
 	case c.Send <- msgBytes:
-		return nil
+		return true
 	default:
-		return ErrDeviceNotConnected
+		return false
+	}
+}
+
+func (c *DeviceConnection) closeSend() {
+	c.closeWith(0, "")
+}
+
+// closeWith stops the write pump, which sends the device a close frame with
+// code and reason before closing the socket. A zero code sends an empty
+// close frame.
+func (c *DeviceConnection) closeWith(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.Send)
+	}
+}
+
+func (c *DeviceConnection) closeMessage() []byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closeCode == 0 {
+		return []byte{}
 	}
+	return websocket.FormatCloseMessage(c.closeCode, c.closeReason)
 }
 
 func NewDeviceConnection(deviceID, userID string, conn *websocket.Conn, hub *Hub) (*DeviceConnection, error) {
@@ -110,11 +170,14 @@ func NewDeviceConnection(deviceID, userID string, conn *websocket.Conn, hub *Hub
 	}
 
 	return &DeviceConnection{
-		DeviceID: did,
-		UserID:   uid,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		Hub:      hub,
+		DeviceID:    did,
+		UserID:      uid,
+		SessionID:   uuid.New(),
+		ConnectedAt: time.Now(),
+		Conn:        conn,
+		Send:        make(chan []byte, 256),
+		Hub:         hub,
+		battery:     -1,
 	}, nil
 }
 
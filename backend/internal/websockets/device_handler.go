@@ -1,13 +1,16 @@
 package websockets
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/workers"
 )
 
 type DeviceHandler struct {
@@ -16,6 +19,13 @@ type DeviceHandler struct {
 	deviceService services.DeviceService
 	logService    services.LogService
 	ruleService   services.RuleService
+	policyService services.CapturePolicyService
+	outbound      services.OutboundService
+	suppressions  services.SuppressionService
+	commands      services.CommandService
+	events        services.EventBus
+	telemetry     services.TelemetryService
+	dispatcher    *workers.WebhookDispatcher
 }
 
 func NewDeviceHandler(
@@ -24,6 +34,13 @@ func NewDeviceHandler(
 	deviceService services.DeviceService,
 	logService services.LogService,
 	ruleService services.RuleService,
+	policyService services.CapturePolicyService,
+	outbound services.OutboundService,
+	suppressions services.SuppressionService,
+	commands services.CommandService,
+	events services.EventBus,
+	telemetry services.TelemetryService,
+	dispatcher *workers.WebhookDispatcher,
 ) *DeviceHandler {
 	return &DeviceHandler{
 		hub:           hub,
@@ -31,10 +48,22 @@ func NewDeviceHandler(
 		deviceService: deviceService,
 		logService:    logService,
 		ruleService:   ruleService,
+		policyService: policyService,
+		outbound:      outbound,
+		suppressions:  suppressions,
+		commands:      commands,
+		events:        events,
+		telemetry:     telemetry,
+		dispatcher:    dispatcher,
 	}
 }
 
 func (h *DeviceHandler) HandleMessage(conn *DeviceConnection, msg *Message) {
+	if !conn.Protocol.Accepts(msg.Type) {
+		log.Printf("dropped %s from device %s: capability not negotiated", msg.Type, conn.DeviceID)
+		return
+	}
+
 	switch msg.Type {
 	case MsgTypePing:
 		h.handlePing(conn, msg)
@@ -46,6 +75,12 @@ func (h *DeviceHandler) HandleMessage(conn *DeviceConnection, msg *Message) {
 		h.handleSMSSent(conn, msg)
 	case MsgTypeSMSFailed:
 		h.handleSMSFailed(conn, msg)
+	case MsgTypeSMSDelivered:
+		h.handleSMSDelivered(conn, msg)
+	case MsgTypeAck:
+		h.handleAck(conn, msg)
+	case MsgTypeCommandResult:
+		h.handleCommandResult(conn, msg)
 	default:
 		log.Printf("unknown message type: %s", msg.Type)
 	}
@@ -58,10 +93,40 @@ func (h *DeviceHandler) handlePing(conn *DeviceConnection, msg *Message) {
 		return
 	}
 
+	// Heartbeats repeat every 30 seconds; only changes reach the stream
+	batteryChanged := conn.battery != data.Battery
+	conn.battery = data.Battery
+
+	point := &models.DeviceTelemetry{
+		RecordedAt:  time.Now(),
+		Battery:     data.Battery,
+		Charging:    data.Charging,
+		Signal:      data.Signal,
+		NetworkType: data.NetworkType,
+		QueueDepth:  data.QueueDepth,
+		AppVersion:  conn.AppVersion,
+	}
+
 	go func() {
 		if err := h.deviceService.SetOnline(conn.DeviceID, data.Battery); err != nil {
 			log.Printf("failed to update device status: %v", err)
 		}
+		if err := h.telemetry.Record(conn.DeviceID, point); err != nil {
+			log.Printf("failed to record telemetry of device %s: %v", conn.DeviceID, err)
+		}
+		if batteryChanged {
+			battery := data.Battery
+			services.PublishEvent(h.events, conn.UserID, services.EventDeviceBattery, &conn.DeviceID, &dto.DeviceEventData{
+				DeviceID: conn.DeviceID.String(),
+				Status:   models.DeviceStatusOnline,
+				Battery:  &battery,
+			})
+		}
+		if data.SimCount > 0 {
+			if err := h.deviceService.UpdateSimCount(conn.DeviceID, data.SimCount); err != nil {
+				log.Printf("failed to update sim count: %v", err)
+			}
+		}
 	}()
 
 	pongMsg, err := NewMessage(MsgTypePong, &PongData{
@@ -85,24 +150,53 @@ func (h *DeviceHandler) handleSMSReceived(conn *DeviceConnection, msg *Message)
 	}
 
 	go func() {
-		msgLog, err := h.logService.Create(
-			conn.UserID,
-			&conn.DeviceID,
-			models.DirectionInbound,
-			data.Sender,
-			"",
-			data.Content,
-			data.SimSlot,
-		)
+		sender := h.normalizeSender(conn.UserID, data.Sender)
+
+		msgLog, err := h.logService.CreateInboundSMS(conn.UserID, conn.DeviceID, &services.InboundSMSInput{
+			EventID: msg.ID,
+			Sender:  sender,
+			Content: data.Content,
+			SimSlot: data.SimSlot,
+		})
+		if errors.Is(err, services.ErrDuplicateEvent) {
+			h.ackEvent(conn, msg.ID)
+			return
+		}
 		if err != nil {
 			log.Printf("failed to create message log: %v", err)
 			return
 		}
+		h.ackEvent(conn, msg.ID)
+		h.publishLog(conn, msgLog)
+
+		action, err := h.suppressions.HandleInbound(conn.UserID, conn.DeviceID, sender, data.Content)
+		if err != nil {
+			log.Printf("failed to apply opt-out keyword from %s: %v", sender, err)
+		} else if action != services.KeywordNone {
+			log.Printf("[suppression] %s from %s on device %s", action, sender, conn.DeviceID)
+		}
 
-		h.matchAndDispatch(conn.DeviceID, "sms", data.Sender, data.Content, msgLog.ID)
+		h.matchAndDispatch(conn.UserID, conn.DeviceID, "sms", data.Sender, data.Content, &workers.WebhookData{
+			Type:      "sms",
+			DeviceID:  conn.DeviceID.String(),
+			Sender:    sender,
+			Content:   data.Content,
+			Timestamp: eventTimestamp(data.Timestamp),
+		}, msgLog.ID)
 	}()
 }
 
+// normalizeSender stores inbound numbers in E.164 so replies and history
+// line up with outbound receivers. Rules still match the sender as the
+// device reported it.
+func (h *DeviceHandler) normalizeSender(userID uuid.UUID, sender string) string {
+	country := services.DefaultPhoneCountry
+	if user, err := h.userService.GetByID(userID); err == nil && user.DefaultCountry != "" {
+		country = user.DefaultCountry
+	}
+	return services.NormalizeSender(sender, country)
+}
+
 func (h *DeviceHandler) handleNotificationReceived(conn *DeviceConnection, msg *Message) {
 	var data NotificationReceivedData
 	if err := msg.UnmarshalData(&data); err != nil {
@@ -111,11 +205,94 @@ func (h *DeviceHandler) handleNotificationReceived(conn *DeviceConnection, msg *
 	}
 
 	go func() {
+		allowed, err := h.policyService.Allows(conn.UserID, conn.DeviceID, data.PackageName)
+		if err != nil {
+			log.Printf("failed to check capture policy: %v", err)
+			return
+		}
+		if !allowed {
+			h.ackEvent(conn, msg.ID)
+			return
+		}
+
+		msgLog, err := h.logService.CreateNotification(conn.UserID, &conn.DeviceID, &services.NotificationLogInput{
+			EventID:    msg.ID,
+			AppPackage: data.PackageName,
+			AppName:    data.AppName,
+			Title:      data.Title,
+			Text:       data.Content,
+			Category:   data.Category,
+		})
+		if errors.Is(err, services.ErrDuplicateEvent) {
+			h.ackEvent(conn, msg.ID)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to create notification log: %v", err)
+			return
+		}
+		h.ackEvent(conn, msg.ID)
+		h.publishLog(conn, msgLog)
+
 		content := data.Title + "\n" + data.Content
-		h.matchAndDispatch(conn.DeviceID, "notification", data.PackageName, content, 0)
+		h.matchAndDispatch(conn.UserID, conn.DeviceID, "notification", data.PackageName, content, &workers.WebhookData{
+			Type:       "notification",
+			DeviceID:   conn.DeviceID.String(),
+			Content:    data.Content,
+			Timestamp:  eventTimestamp(data.Timestamp),
+			AppPackage: data.PackageName,
+			AppName:    data.AppName,
+			Title:      data.Title,
+		}, msgLog.ID)
 	}()
 }
 
+// ackEvent confirms a device event once its log is committed, so the device
+// can drop it from its replay buffer. Devices that did not negotiate
+// CapEventAck do not replay and get no ACK.
+func (h *DeviceHandler) ackEvent(conn *DeviceConnection, eventID string) {
+	if eventID == "" || !conn.Protocol.Supports(CapEventAck) {
+		return
+	}
+
+	ack, err := NewMessage(MsgTypeAck, &AckData{ID: eventID})
+	if err != nil {
+		log.Printf("failed to create ack message: %v", err)
+		return
+	}
+	if err := conn.SendMessage(ack); err != nil {
+		log.Printf("failed to ack event %s from device %s: %v", eventID, conn.DeviceID, err)
+	}
+}
+
+func (h *DeviceHandler) publishLog(conn *DeviceConnection, msgLog *models.MessageLog) {
+	services.PublishEvent(h.events, conn.UserID, services.EventLogCreated, &conn.DeviceID, dto.ToLogDTO(msgLog))
+}
+
+// SendCapturePolicy pushes the device's effective capture policy straight onto
+// its connection, used right after AUTH_OK before the hub has registered it.
+func (h *DeviceHandler) SendCapturePolicy(conn *DeviceConnection) {
+	if !conn.Protocol.Allows(MsgTypeCapturePolicy) {
+		return
+	}
+
+	policy, err := h.policyService.Effective(conn.UserID, conn.DeviceID)
+	if err != nil {
+		log.Printf("failed to resolve capture policy: %v", err)
+		return
+	}
+
+	msg, err := NewCapturePolicyMessage(policy)
+	if err != nil {
+		log.Printf("failed to create capture policy message: %v", err)
+		return
+	}
+
+	if err := conn.SendMessage(msg); err != nil {
+		log.Printf("failed to send capture policy: %v", err)
+	}
+}
+
 func (h *DeviceHandler) handleSMSSent(conn *DeviceConnection, msg *Message) {
 	var data SMSSentData
 	if err := msg.UnmarshalData(&data); err != nil {
@@ -124,14 +301,24 @@ func (h *DeviceHandler) handleSMSSent(conn *DeviceConnection, msg *Message) {
 	}
 
 	go func() {
-		logID, err := parseLogID(data.RequestID)
-		if err != nil {
-			log.Printf("failed to parse request id: %v", err)
-			return
+		h.ackRequest(conn, data.RequestID)
+		if err := h.outbound.MarkSent(conn.UserID, conn.DeviceID, data.RequestID); err != nil {
+			log.Printf("failed to mark request %s as sent: %v", data.RequestID, err)
 		}
+	}()
+}
+
+func (h *DeviceHandler) handleSMSDelivered(conn *DeviceConnection, msg *Message) {
+	var data SMSDeliveredData
+	if err := msg.UnmarshalData(&data); err != nil {
+		log.Printf("failed to unmarshal sms delivered data: %v", err)
+		return
+	}
 
-		if err := h.logService.UpdateStatus(logID, models.StatusSent, ""); err != nil {
-			log.Printf("failed to update log status: %v", err)
+	go func() {
+		h.ackRequest(conn, data.RequestID)
+		if err := h.outbound.MarkDelivered(conn.UserID, conn.DeviceID, data.RequestID, data.DeliveredAt); err != nil {
+			log.Printf("failed to mark request %s as delivered: %v", data.RequestID, err)
 		}
 	}()
 }
@@ -144,19 +331,100 @@ func (h *DeviceHandler) handleSMSFailed(conn *DeviceConnection, msg *Message) {
 	}
 
 	go func() {
-		logID, err := parseLogID(data.RequestID)
+		h.ackRequest(conn, data.RequestID)
+		if err := h.outbound.MarkFailed(conn.UserID, conn.DeviceID, data.RequestID, data.SimSlot, data.Error); err != nil {
+			log.Printf("failed to mark request %s as failed: %v", data.RequestID, err)
+		}
+	}()
+}
+
+func (h *DeviceHandler) handleAck(conn *DeviceConnection, msg *Message) {
+	var data AckData
+	if err := msg.UnmarshalData(&data); err != nil {
+		log.Printf("failed to unmarshal ack data: %v", err)
+		return
+	}
+
+	commandID, err := uuid.Parse(data.ID)
+	if err != nil {
+		log.Printf("invalid command id in ack from device %s: %q", conn.DeviceID, data.ID)
+		return
+	}
+
+	go func() {
+		if err := h.commands.Ack(conn.DeviceID, commandID); err != nil {
+			log.Printf("failed to ack command %s: %v", commandID, err)
+		}
+	}()
+}
+
+func (h *DeviceHandler) handleCommandResult(conn *DeviceConnection, msg *Message) {
+	var data CommandResultData
+	if err := msg.UnmarshalData(&data); err != nil {
+		log.Printf("failed to unmarshal command result data: %v", err)
+		return
+	}
+
+	commandID, err := uuid.Parse(data.ID)
+	if err != nil {
+		log.Printf("invalid command id in result from device %s: %q", conn.DeviceID, data.ID)
+		return
+	}
+
+	go func() {
+		cmd, err := h.commands.Complete(conn.DeviceID, commandID, &services.CommandResult{
+			Success: data.Success,
+			Error:   data.Error,
+			Data:    data.Result,
+		})
+		if errors.Is(err, services.ErrCommandNotFound) {
+			log.Printf("ignored result for unknown or settled command %s from device %s", commandID, conn.DeviceID)
+			return
+		}
 		if err != nil {
-			log.Printf("failed to parse request id: %v", err)
+			log.Printf("failed to store result of command %s: %v", commandID, err)
 			return
 		}
 
-		if err := h.logService.UpdateStatus(logID, models.StatusFailed, data.Error); err != nil {
-			log.Printf("failed to update log status: %v", err)
+		if cmd.Type == MsgTypeRequestStatus && cmd.Status == models.CommandCompleted {
+			h.applyStatusReport(conn, data.Result)
 		}
+		services.PublishEvent(h.events, conn.UserID, services.EventCommandResult, &conn.DeviceID, dto.ToCommandDTO(cmd))
 	}()
 }
 
-func (h *DeviceHandler) matchAndDispatch(deviceID uuid.UUID, triggerType, sender, content string, logID uint) {
+// applyStatusReport keeps the device record in line with what the phone
+// reported; the full report stays on the command.
+func (h *DeviceHandler) applyStatusReport(conn *DeviceConnection, result json.RawMessage) {
+	var report StatusReportData
+	if err := json.Unmarshal(result, &report); err != nil {
+		log.Printf("invalid status report from device %s: %v", conn.DeviceID, err)
+		return
+	}
+
+	if len(report.Sims) > 0 {
+		if err := h.deviceService.UpdateSimCount(conn.DeviceID, len(report.Sims)); err != nil {
+			log.Printf("failed to update sim count: %v", err)
+		}
+	}
+	if report.AppVersion != "" {
+		if err := h.deviceService.UpdateProtocol(conn.DeviceID, report.AppVersion, conn.Protocol.Version); err != nil {
+			log.Printf("failed to record app version of device %s: %v", conn.DeviceID, err)
+		}
+	}
+}
+
+// ackRequest treats a status report for a request as proof the device got
+// its SEND_SMS, covering devices that skip the ACK frame. It must run before
+// the report is applied: a failover to the other SIM would otherwise have
+// its fresh command acknowledged too.
+func (h *DeviceHandler) ackRequest(conn *DeviceConnection, requestID string) {
+	if err := h.commands.AckRef(conn.DeviceID, requestID); err != nil {
+		log.Printf("failed to ack commands for request %s: %v", requestID, err)
+	}
+}
+
+func (h *DeviceHandler) matchAndDispatch(userID, deviceID uuid.UUID, triggerType, sender, content string, data *workers.WebhookData, logID uint) {
 	rules, err := h.ruleService.MatchRules(deviceID, triggerType, sender, content)
 	if err != nil {
 		log.Printf("failed to match rules: %v", err)
@@ -164,17 +432,29 @@ func (h *DeviceHandler) matchAndDispatch(deviceID uuid.UUID, triggerType, sender
 	}
 
 	for _, rule := range rules {
-		log.Printf("matched rule %d, dispatching webhook to %s", rule.ID, rule.WebhookURL)
-		// TODO: Dispatch via Asynq worker when webhook dispatcher is implemented
-		// h.dispatcher.Dispatch(rule, sender, content, logID)
+		log.Printf("matched rule %d, dispatching webhook to %s for log_id=%d", rule.ID, rule.WebhookURL, logID)
+		if h.dispatcher == nil {
+			continue
+		}
+
+		err := h.dispatcher.Dispatch(&workers.WebhookPayload{
+			RuleID:       rule.ID,
+			UserID:       userID.String(),
+			WebhookURL:   rule.WebhookURL,
+			Method:       rule.Method,
+			SecretHeader: rule.SecretHeader,
+			Data:         *data,
+			LogID:        logID,
+		})
+		if err != nil {
+			log.Printf("failed to dispatch webhook for rule %d: %v", rule.ID, err)
+		}
 	}
 }
 
-func parseLogID(requestID string) (uint, error) {
-	var id uint
-	_, err := fmt.Sscanf(requestID, "%d", &id)
-	if err != nil {
-		return 0, err
+func eventTimestamp(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
 	}
-	return id, nil
+	return t.UTC().Format(time.RFC3339)
 }
@@ -1,17 +1,35 @@
 package websockets
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"log"
 	"sync"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
 )
 
+const (
+	// envelopeDisconnect asks the instance holding a device's socket to close it
+	envelopeDisconnect = "_DISCONNECT"
+	// envelopeSupersede tells the instance holding a device's socket that the
+	// device reconnected elsewhere; Payload is the new session's start time
+	envelopeSupersede = "_SUPERSEDE"
+)
+
+// clusterTimeout bounds a single presence lookup or publish so a slow Redis
+// cannot stall a send
+const clusterTimeout = 3 * time.Second
+
 var (
 	ErrDeviceNotConnected = errors.New("device not connected")
 	ErrDeviceNotFound     = errors.New("device not found")
+	ErrNoOutbox           = errors.New("command outbox is not configured")
 )
 
 type Hub struct {
@@ -20,14 +38,52 @@ type Hub struct {
 	unregister chan *DeviceConnection
 	broadcast  chan *Message
 	mu         sync.RWMutex
+
+	registerHooks   []func(conn *DeviceConnection)
+	unregisterHooks []func(conn *DeviceConnection)
+
+	// cluster is nil for a single-instance hub
+	cluster Cluster
+	// presenceMu orders this instance's presence writes, so a session that
+	// was already replaced cannot announce itself over its successor
+	presenceMu sync.Mutex
+	// outbox is nil when commands are sent fire-and-forget
+	outbox Outbox
+}
+
+// Outbox keeps commands until the device acknowledges them. It is satisfied
+// by services.CommandService.
+type Outbox interface {
+	Record(cmd *models.DeviceCommand) error
+	Discard(id uuid.UUID) error
+	Resendable(deviceID uuid.UUID) ([]models.DeviceCommand, error)
+	MarkResent(id uuid.UUID) error
 }
 
 type DeviceConnection struct {
 	DeviceID uuid.UUID
 	UserID   uuid.UUID
-	Conn     *websocket.Conn
-	Send     chan []byte
-	Hub      *Hub
+	// SessionID tells apart the connections of a device that reconnects
+	// before its previous socket has timed out
+	SessionID   uuid.UUID
+	ConnectedAt time.Time
+	Conn        *websocket.Conn
+	Send        chan []byte
+	Hub         *Hub
+
+	// Protocol is settled during AUTH and gates the frames sent to the device
+	Protocol *Negotiated
+	// AppVersion is the app build reported at AUTH
+	AppVersion string
+
+	// battery is the level last reported by a heartbeat, -1 before the first;
+	// only the read pump touches it
+	battery int
+
+	mu          sync.RWMutex
+	closed      bool
+	closeCode   int
+	closeReason string
 }
 
 func NewHub() *Hub {
@@ -39,42 +95,85 @@ func NewHub() *Hub {
 	}
 }
 
+// NewClusterHub returns a hub that shares presence with the other instances
+// of cluster, so SendToDevice reaches devices connected to any replica and
+// GetOnlineDevices reports the whole cluster.
+func NewClusterHub(cluster Cluster) *Hub {
+	h := NewHub()
+	h.cluster = cluster
+	return h
+}
+
 func (h *Hub) Run() {
+	if h.cluster != nil {
+		go h.subscribe()
+		go h.refreshPresence()
+	}
+
 	for {
 		select {
 		case conn := <-h.register:
 			h.mu.Lock()
+			previous, replaced := h.devices[conn.DeviceID]
 			h.devices[conn.DeviceID] = conn
+			hooks := h.registerHooks
 			h.mu.Unlock()
 
+			if replaced && previous != conn {
+				log.Printf("device %s reconnected: session %s replaces %s", conn.DeviceID, conn.SessionID, previous.SessionID)
+				previous.closeWith(CloseSessionReplaced, "replaced by a newer connection")
+			}
+			if h.cluster != nil {
+				go h.claim(conn)
+			}
+			for _, hook := range hooks {
+				go hook(conn)
+			}
+
 		case conn := <-h.unregister:
+			// A superseded session must not take its successor down with it
 			h.mu.Lock()
-			if _, ok := h.devices[conn.DeviceID]; ok {
+			current := h.devices[conn.DeviceID] == conn
+			if current {
 				delete(h.devices, conn.DeviceID)
-				close(conn.Send)
 			}
+			hooks := h.unregisterHooks
 			h.mu.Unlock()
 
+			conn.closeSend()
+			if !current {
+				continue
+			}
+
+			if h.cluster != nil {
+				go h.leave(conn)
+			}
+			for _, hook := range hooks {
+				go hook(conn)
+			}
+
 		case msg := <-h.broadcast:
-			h.mu.RLock()
 			msgBytes, err := json.Marshal(msg)
 			if err != nil {
-				h.mu.RUnlock()
 				continue
 			}
-			for _, conn := range h.devices {
-				select {
-				case conn.Send <- msgBytes:
-				default:
-					h.mu.RUnlock()
-					h.mu.Lock()
-					delete(h.devices, conn.DeviceID)
-					close(conn.Send)
-					h.mu.Unlock()
-					h.mu.RLock()
-				}
+			if h.cluster != nil {
+				go h.publish("", &Envelope{Type: msg.Type, Payload: msgBytes})
+				continue
 			}
-			h.mu.RUnlock()
+			h.broadcastLocal(msg.Type, msgBytes)
+		}
+	}
+}
+
+// broadcastLocal drops connections that cannot keep up; closing their send
+// channel ends the socket, which unregisters them through ReadPump.
+func (h *Hub) broadcastLocal(msgType string, msgBytes []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, conn := range h.devices {
+		if err := conn.deliver(msgType, msgBytes); errors.Is(err, ErrDeviceNotConnected) {
+			conn.closeSend()
 		}
 	}
 }
@@ -83,6 +182,33 @@ func (h *Hub) RegisterDevice(conn *DeviceConnection) {
 	h.register <- conn
 }
 
+// OnRegister adds a hook run once a connection is visible to SendToDevice,
+// e.g. to flush messages queued while the device was offline.
+func (h *Hub) OnRegister(hook func(conn *DeviceConnection)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registerHooks = append(h.registerHooks, hook)
+}
+
+// OnUnregister adds a hook run when a device's current session ends. It is
+// not run for sessions superseded by a reconnect, so the device is not
+// reported offline while its new connection is up.
+func (h *Hub) OnUnregister(hook func(conn *DeviceConnection)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unregisterHooks = append(h.unregisterHooks, hook)
+}
+
+// UseOutbox makes SendCommand track acknowledgements and resend the commands
+// a device has not acknowledged whenever it reconnects.
+func (h *Hub) UseOutbox(outbox Outbox) {
+	h.mu.Lock()
+	h.outbox = outbox
+	h.mu.Unlock()
+
+	h.OnRegister(h.resend)
+}
+
 func (h *Hub) UnregisterDevice(deviceID uuid.UUID) {
 	h.mu.RLock()
 	conn, ok := h.devices[deviceID]
@@ -92,36 +218,264 @@ func (h *Hub) UnregisterDevice(deviceID uuid.UUID) {
 	}
 }
 
+// SendToDevice writes msg to the device's connection, forwarding it to the
+// instance holding the connection when the device is not connected here.
+// Delivery is not confirmed; use SendCommand for frames that must arrive.
 func (h *Hub) SendToDevice(deviceID uuid.UUID, msg *Message) error {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return h.sendBytes(deviceID, msg.Type, msgBytes)
+}
+
+// SendCommand sends msg under a fresh message ID and keeps it in the outbox
+// until the device ACKs it or expiresAt passes. ref links the command to what
+// it acts on. An error means the device never got the command and the caller
+// still owns the retry.
+func (h *Hub) SendCommand(deviceID uuid.UUID, msg *Message, ref string, expiresAt time.Time) error {
+	h.mu.RLock()
+	outbox := h.outbox
+	h.mu.RUnlock()
+	if outbox == nil {
+		return h.SendToDevice(deviceID, msg)
+	}
+
+	_, err := h.sendTracked(outbox, deviceID, msg, ref, expiresAt)
+	return err
+}
+
+// DispatchCommand sends a remote control command, satisfying
+// services.CommandGateway. Unlike SEND_SMS it needs the outbox: the caller
+// reads the device's answer back from the recorded command.
+func (h *Hub) DispatchCommand(deviceID uuid.UUID, cmdType string, data interface{}, expiresAt time.Time) (uuid.UUID, error) {
+	h.mu.RLock()
+	outbox := h.outbox
+	h.mu.RUnlock()
+	if outbox == nil {
+		return uuid.Nil, ErrNoOutbox
+	}
+
+	// Check before recording: an instance the frame is routed to can only
+	// drop it, which would leave the command pending until it expires
+	allowed, err := h.allows(deviceID, cmdType)
+	if err != nil {
+		return uuid.Nil, commandError(err)
+	}
+	if !allowed {
+		return uuid.Nil, services.ErrCommandNotSupported
+	}
+
+	msg, err := NewMessage(cmdType, data)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := h.sendTracked(outbox, deviceID, msg, "", expiresAt)
+	if err != nil {
+		return uuid.Nil, commandError(err)
+	}
+	return id, nil
+}
+
+// commandError maps hub errors onto the ones services.CommandGateway callers
+// expect.
+func commandError(err error) error {
+	switch {
+	case errors.Is(err, ErrDeviceNotConnected):
+		return services.ErrDeviceOffline
+	case errors.Is(err, ErrUnsupportedCommand):
+		return services.ErrCommandNotSupported
+	}
+	return err
+}
+
+func (h *Hub) sendTracked(outbox Outbox, deviceID uuid.UUID, msg *Message, ref string, expiresAt time.Time) (uuid.UUID, error) {
+	id := uuid.New()
+	msg.ID = id.String()
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Record first so an ACK racing the write finds the command
+	err = outbox.Record(&models.DeviceCommand{
+		ID:        id,
+		DeviceID:  deviceID,
+		Type:      msg.Type,
+		Ref:       ref,
+		Payload:   msgBytes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if err := h.sendBytes(deviceID, msg.Type, msgBytes); err != nil {
+		if discardErr := outbox.Discard(id); discardErr != nil {
+			log.Printf("failed to discard undelivered command %s: %v", id, discardErr)
+		}
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// allows reports whether the device negotiated what a frame of msgType needs,
+// on whichever instance holds its connection.
+func (h *Hub) allows(deviceID uuid.UUID, msgType string) (bool, error) {
 	h.mu.RLock()
 	conn, ok := h.devices[deviceID]
 	h.mu.RUnlock()
+	if ok {
+		return conn.Protocol.Allows(msgType), nil
+	}
+	if h.cluster == nil {
+		return false, ErrDeviceNotConnected
+	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	capabilities, ok, err := h.cluster.Capabilities(ctx, deviceID)
+	if err != nil {
+		return false, err
+	}
 	if !ok {
+		return false, ErrDeviceNotConnected
+	}
+	return negotiatedFrom(capabilities).Allows(msgType), nil
+}
+
+func (h *Hub) sendBytes(deviceID uuid.UUID, msgType string, msgBytes []byte) error {
+	h.mu.RLock()
+	conn, ok := h.devices[deviceID]
+	h.mu.RUnlock()
+
+	if !ok {
+		return h.sendRemote(deviceID, msgType, msgBytes)
+	}
+	return conn.deliver(msgType, msgBytes)
+}
+
+// resend replays the commands a device has not acknowledged, oldest first,
+// with their original message IDs. Devices without CapCommandAck cannot
+// drop duplicates, so their commands are left to expire instead.
+func (h *Hub) resend(conn *DeviceConnection) {
+	if !conn.Protocol.Supports(CapCommandAck) {
+		return
+	}
+
+	h.mu.RLock()
+	outbox := h.outbox
+	h.mu.RUnlock()
+
+	cmds, err := outbox.Resendable(conn.DeviceID)
+	if err != nil {
+		log.Printf("failed to load unacknowledged commands for device %s: %v", conn.DeviceID, err)
+		return
+	}
+
+	for i := range cmds {
+		if err := conn.deliver(cmds[i].Type, cmds[i].Payload); err != nil {
+			log.Printf("stopped resending commands to device %s: %v", conn.DeviceID, err)
+			return
+		}
+		if err := outbox.MarkResent(cmds[i].ID); err != nil {
+			log.Printf("failed to record resend of command %s: %v", cmds[i].ID, err)
+		}
+	}
+
+	if len(cmds) > 0 {
+		log.Printf("resent %d unacknowledged commands to device %s", len(cmds), conn.DeviceID)
+	}
+}
+
+func (h *Hub) sendRemote(deviceID uuid.UUID, msgType string, msgBytes []byte) error {
+	if h.cluster == nil {
 		return ErrDeviceNotConnected
 	}
 
-	msgBytes, err := json.Marshal(msg)
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	instanceID, ok, err := h.cluster.Locate(ctx, deviceID)
 	if err != nil {
 		return err
 	}
-
-	select {
-	case conn.Send <- msgBytes:
-		return nil
-	default:
+	// presence pointing at this instance is stale: the connection is gone
+	if !ok || instanceID == h.cluster.InstanceID() {
 		return ErrDeviceNotConnected
 	}
+
+	return h.cluster.Publish(ctx, instanceID, &Envelope{DeviceID: &deviceID, Type: msgType, Payload: msgBytes})
+}
+
+// Disconnect closes the device's socket on whichever instance holds it, e.g.
+// after its credentials were revoked.
+func (h *Hub) Disconnect(deviceID uuid.UUID) error {
+	h.mu.RLock()
+	conn, ok := h.devices[deviceID]
+	h.mu.RUnlock()
+	if ok {
+		return conn.Conn.Close()
+	}
+
+	return h.sendRemote(deviceID, envelopeDisconnect, nil)
+}
+
+// DispatchSMS sends a SEND_SMS command to the device, satisfying
+// services.SMSGateway. The command is tracked by the outbox under the
+// message's request ID until expiresAt.
+func (h *Hub) DispatchSMS(deviceID uuid.UUID, requestID, phone, content string, simSlot int, expiresAt time.Time) error {
+	msg, err := NewMessage(MsgTypeSendSMS, &SendSMSData{
+		RequestID: requestID,
+		Phone:     phone,
+		Content:   content,
+		SimSlot:   simSlot,
+	})
+	if err != nil {
+		return err
+	}
+	return h.SendCommand(deviceID, msg, requestID, expiresAt)
 }
 
 func (h *Hub) GetDeviceStatus(deviceID uuid.UUID) bool {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
 	_, ok := h.devices[deviceID]
-	return ok
+	h.mu.RUnlock()
+	if ok || h.cluster == nil {
+		return ok
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	instanceID, ok, err := h.cluster.Locate(ctx, deviceID)
+	if err != nil {
+		log.Printf("[cluster] failed to locate device %s: %v", deviceID, err)
+		return false
+	}
+	return ok && instanceID != h.cluster.InstanceID()
 }
 
+// GetOnlineDevices lists the user's connected devices across the cluster,
+// falling back to this instance's connections if the cluster is unreachable.
 func (h *Hub) GetOnlineDevices(userID uuid.UUID) []uuid.UUID {
+	if h.cluster != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+
+		devices, err := h.cluster.OnlineDevices(ctx, userID)
+		if err == nil {
+			return devices
+		}
+		log.Printf("[cluster] failed to list online devices of user %s: %v", userID, err)
+	}
+
+	return h.localDevices(userID)
+}
+
+func (h *Hub) localDevices(userID uuid.UUID) []uuid.UUID {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -134,6 +488,13 @@ func (h *Hub) GetOnlineDevices(userID uuid.UUID) []uuid.UUID {
 	return devices
 }
 
+func (h *Hub) isCurrent(conn *DeviceConnection) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.devices[conn.DeviceID] == conn
+}
+
+// GetConnection only sees connections held by this instance.
 func (h *Hub) GetConnection(deviceID uuid.UUID) (*DeviceConnection, bool) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -144,3 +505,151 @@ func (h *Hub) GetConnection(deviceID uuid.UUID) (*DeviceConnection, bool) {
 func (h *Hub) Broadcast(msg *Message) {
 	h.broadcast <- msg
 }
+
+// claim announces a new session, first asking the instance that still holds
+// an older socket of the device to close it.
+func (h *Hub) claim(conn *DeviceConnection) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	instanceID, ok, err := h.cluster.Locate(ctx, conn.DeviceID)
+	if err != nil {
+		log.Printf("[cluster] failed to locate device %s: %v", conn.DeviceID, err)
+	}
+	if ok && instanceID != h.cluster.InstanceID() {
+		since, err := conn.ConnectedAt.MarshalText()
+		if err == nil {
+			err = h.cluster.Publish(ctx, instanceID, &Envelope{DeviceID: &conn.DeviceID, Type: envelopeSupersede, Payload: since})
+		}
+		if err != nil {
+			log.Printf("[cluster] failed to supersede device %s on %s: %v", conn.DeviceID, instanceID, err)
+		}
+	}
+
+	h.join(conn)
+}
+
+// join announces conn while it is still the device's current session. The
+// check and the write happen under presenceMu, so once a successor has
+// registered, a late join of the older session cannot follow its own.
+func (h *Hub) join(conn *DeviceConnection) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	if !h.isCurrent(conn) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := h.cluster.Join(ctx, conn.DeviceID, conn.UserID, conn.SessionID, conn.Protocol.List()); err != nil {
+		log.Printf("[cluster] failed to announce device %s: %v", conn.DeviceID, err)
+	}
+}
+
+// leave clears the presence conn's session claimed. Presence a newer session
+// already took over is left alone, whichever order the goroutines run in.
+func (h *Hub) leave(conn *DeviceConnection) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := h.cluster.Leave(ctx, conn.DeviceID, conn.UserID, conn.SessionID); err != nil {
+		log.Printf("[cluster] failed to clear presence of device %s: %v", conn.DeviceID, err)
+	}
+}
+
+func (h *Hub) publish(instanceID string, env *Envelope) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := h.cluster.Publish(ctx, instanceID, env); err != nil {
+		log.Printf("[cluster] failed to publish envelope: %v", err)
+	}
+}
+
+// refreshPresence re-announces local connections before their presence TTL
+// runs out.
+func (h *Hub) refreshPresence() {
+	ticker := time.NewTicker(presenceRefresh)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.RLock()
+		conns := make([]*DeviceConnection, 0, len(h.devices))
+		for _, conn := range h.devices {
+			conns = append(conns, conn)
+		}
+		h.mu.RUnlock()
+
+		for _, conn := range conns {
+			h.join(conn)
+		}
+	}
+}
+
+// subscribe delivers frames routed from other instances, resubscribing after
+// a dropped Redis connection.
+func (h *Hub) subscribe() {
+	for {
+		err := h.cluster.Subscribe(context.Background(), h.deliver)
+		if err != nil {
+			log.Printf("[cluster] subscription failed: %v", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (h *Hub) deliver(env *Envelope) {
+	if env.DeviceID == nil {
+		h.broadcastLocal(env.Type, env.Payload)
+		return
+	}
+	if env.Type == envelopeSupersede {
+		h.supersede(*env.DeviceID, env.Payload)
+		return
+	}
+
+	h.mu.RLock()
+	conn, ok := h.devices[*env.DeviceID]
+	h.mu.RUnlock()
+	if !ok {
+		log.Printf("[cluster] dropped frame for device %s: not connected to %s", *env.DeviceID, h.cluster.InstanceID())
+		return
+	}
+
+	if env.Type == envelopeDisconnect {
+		conn.Conn.Close()
+		return
+	}
+
+	if err := conn.deliver(env.Type, env.Payload); err != nil {
+		log.Printf("[cluster] dropped %s frame for device %s: %v", env.Type, *env.DeviceID, err)
+	}
+}
+
+// supersede closes the local session of a device that reconnected to another
+// instance, unless the local session is the newer one. The session is removed
+// here so its unregister neither reports the device offline nor clears the
+// presence the new instance holds.
+func (h *Hub) supersede(deviceID uuid.UUID, payload []byte) {
+	var since time.Time
+	if err := since.UnmarshalText(payload); err != nil {
+		log.Printf("[cluster] invalid supersede frame for device %s: %v", deviceID, err)
+		return
+	}
+
+	h.mu.Lock()
+	conn, ok := h.devices[deviceID]
+	if ok && conn.ConnectedAt.Before(since) {
+		delete(h.devices, deviceID)
+	} else {
+		ok = false
+	}
+	h.mu.Unlock()
+
+	if ok {
+		log.Printf("[cluster] device %s reconnected elsewhere: closing session %s", deviceID, conn.SessionID)
+		conn.closeWith(CloseSessionReplaced, "replaced by a newer connection")
+	}
+}
@@ -0,0 +1,230 @@
+package websockets
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func startHub(t *testing.T, hub *Hub) *Hub {
+	t.Helper()
+	go hub.Run()
+	return hub
+}
+
+func newTestConn(t *testing.T, hub *Hub, deviceID, userID uuid.UUID) *DeviceConnection {
+	t.Helper()
+	conn, err := NewDeviceConnection(deviceID.String(), userID.String(), nil, hub)
+	if err != nil {
+		t.Fatalf("NewDeviceConnection: %v", err)
+	}
+	return conn
+}
+
+// eventually polls cond until it holds or the deadline passes; hub hooks and
+// presence updates run on their own goroutines.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func isClosed(conn *DeviceConnection) bool {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	return conn.closed
+}
+
+func closeCode(conn *DeviceConnection) int {
+	conn.mu.RLock()
+	defer conn.mu.RUnlock()
+	return conn.closeCode
+}
+
+// sessionRecorder collects the sessions passed to hub hooks.
+type sessionRecorder struct {
+	mu       sync.Mutex
+	sessions []uuid.UUID
+}
+
+func (r *sessionRecorder) record(conn *DeviceConnection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions = append(r.sessions, conn.SessionID)
+}
+
+func (r *sessionRecorder) count(sessionID uuid.UUID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, id := range r.sessions {
+		if id == sessionID {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHubReconnectSupersedesPreviousSession(t *testing.T) {
+	hub := startHub(t, NewHub())
+	var unregistered sessionRecorder
+	hub.OnUnregister(unregistered.record)
+
+	deviceID, userID := uuid.New(), uuid.New()
+	old := newTestConn(t, hub, deviceID, userID)
+	hub.RegisterDevice(old)
+
+	successor := newTestConn(t, hub, deviceID, userID)
+	hub.RegisterDevice(successor)
+
+	eventually(t, "old session to be closed", func() bool { return isClosed(old) })
+	if code := closeCode(old); code != CloseSessionReplaced {
+		t.Errorf("old session close code = %d, want %d", code, CloseSessionReplaced)
+	}
+
+	// the old socket's read pump ends late and unregisters it
+	hub.unregister <- old
+	// Run takes the broadcast only once it has handled the unregister
+	hub.Broadcast(&Message{Type: MsgTypePong})
+
+	if conn, ok := hub.GetConnection(deviceID); !ok || conn != successor {
+		t.Fatalf("current connection = %v, want the successor", conn)
+	}
+	if isClosed(successor) {
+		t.Fatal("successor was closed by the old session's unregister")
+	}
+
+	hub.unregister <- successor
+	eventually(t, "successor unregister hook", func() bool { return unregistered.count(successor.SessionID) == 1 })
+	if n := unregistered.count(old.SessionID); n != 0 {
+		t.Errorf("OnUnregister ran %d times for the superseded session, want 0", n)
+	}
+}
+
+func TestHubRapidReconnects(t *testing.T) {
+	hub := startHub(t, NewHub())
+	var registered, unregistered sessionRecorder
+	hub.OnRegister(registered.record)
+	hub.OnUnregister(unregistered.record)
+
+	deviceID, userID := uuid.New(), uuid.New()
+	previous := newTestConn(t, hub, deviceID, userID)
+	hub.RegisterDevice(previous)
+
+	for i := 0; i < 200; i++ {
+		successor := newTestConn(t, hub, deviceID, userID)
+
+		// the superseded socket's unregister races its successor's register
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func(conn *DeviceConnection) {
+			defer wg.Done()
+			hub.unregister <- conn
+		}(previous)
+		go func() {
+			defer wg.Done()
+			hub.RegisterDevice(successor)
+		}()
+		wg.Wait()
+
+		// Run has received both but may still be applying the last one
+		eventually(t, "newest session to be current", func() bool {
+			conn, ok := hub.GetConnection(deviceID)
+			return ok && conn == successor
+		})
+		eventually(t, "superseded session to be closed", func() bool { return isClosed(previous) })
+		if isClosed(successor) {
+			t.Fatalf("iteration %d: newest session was closed", i)
+		}
+		previous = successor
+	}
+
+	eventually(t, "register hooks", func() bool { return registered.count(previous.SessionID) == 1 })
+	if n := unregistered.count(previous.SessionID); n != 0 {
+		t.Errorf("OnUnregister ran for the live session %d times", n)
+	}
+}
+
+// TestCloseWithDuringSupersede closes one connection from the paths that
+// race in practice: the hub replacing it, its own unregister and a send.
+func TestCloseWithDuringSupersede(t *testing.T) {
+	hub := NewHub()
+	for i := 0; i < 200; i++ {
+		conn := newTestConn(t, hub, uuid.New(), uuid.New())
+
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			conn.closeWith(CloseSessionReplaced, "replaced by a newer connection")
+		}()
+		go func() {
+			defer wg.Done()
+			conn.closeSend()
+		}()
+		go func() {
+			defer wg.Done()
+			conn.enqueue([]byte(`{"type":"PING"}`))
+		}()
+		wg.Wait()
+
+		if !isClosed(conn) {
+			t.Fatal("connection not closed")
+		}
+		if code := closeCode(conn); code != 0 && code != CloseSessionReplaced {
+			t.Fatalf("close code = %d", code)
+		}
+		if conn.enqueue([]byte(`{}`)) {
+			t.Fatal("enqueue succeeded on a closed connection")
+		}
+	}
+}
+
+func TestHubLateLeaveKeepsSuccessorPresence(t *testing.T) {
+	broker := NewMemoryBroker()
+	cluster := broker.Node("a")
+	hub := startHub(t, NewClusterHub(cluster))
+
+	deviceID, userID := uuid.New(), uuid.New()
+	previous := newTestConn(t, hub, deviceID, userID)
+	hub.RegisterDevice(previous)
+
+	for i := 0; i < 100; i++ {
+		successor := newTestConn(t, hub, deviceID, userID)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func(conn *DeviceConnection) {
+			defer wg.Done()
+			hub.unregister <- conn
+		}(previous)
+		go func() {
+			defer wg.Done()
+			hub.RegisterDevice(successor)
+		}()
+		wg.Wait()
+		previous = successor
+	}
+
+	eventually(t, "presence to belong to the newest session", func() bool {
+		broker.mu.RLock()
+		defer broker.mu.RUnlock()
+		p, ok := broker.presence[deviceID]
+		return ok && p.instanceID == "a" && p.sessionID == previous.SessionID
+	})
+
+	// once settled, no straggling leave may clear it
+	time.Sleep(50 * time.Millisecond)
+	if _, ok, _ := cluster.Locate(context.Background(), deviceID); !ok {
+		t.Fatal("presence of the connected device was cleared")
+	}
+}
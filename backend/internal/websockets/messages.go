@@ -3,6 +3,8 @@ package websockets
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 )
 
 const (
@@ -16,20 +18,66 @@ const (
 	MsgTypeSendSMS       = "SEND_SMS"
 	MsgTypeSMSSent       = "SMS_SENT"
 	MsgTypeSMSFailed     = "SMS_FAILED"
+	MsgTypeSMSDelivered  = "SMS_DELIVERED"
+	MsgTypeCapturePolicy = "CAPTURE_POLICY"
+	MsgTypeAck           = "ACK"
+
+	// Remote control commands, answered with COMMAND_RESULT
+	MsgTypeRequestStatus  = models.CommandRequestStatus
+	MsgTypeRestartService = models.CommandRestartService
+	MsgTypeUpdateConfig   = models.CommandUpdateConfig
+	MsgTypeUploadLogs     = models.CommandUploadLogs
+	MsgTypeRunUSSD        = models.CommandRunUSSD
+	MsgTypeCommandResult  = "COMMAND_RESULT"
 )
 
+// Frames of the dashboard socket at /ws/user. Clients send SUBSCRIBE and get
+// SUBSCRIBED, then EVENT frames; PING/PONG work as on the device socket.
+const (
+	MsgTypeSubscribe  = "SUBSCRIBE"
+	MsgTypeSubscribed = "SUBSCRIBED"
+	MsgTypeEvent      = "EVENT"
+	MsgTypeError      = "ERROR"
+)
+
+// CaptureModeAll is sent when no capture policy applies to a device.
+const CaptureModeAll = "all"
+
+// Message is a protocol frame. Frames that must not be lost carry an ID that
+// the receiving side answers with an ACK frame: the server for commands it
+// sends, the device for SMS_RECEIVED and NOTIFICATION_RECEIVED events. A
+// resent or replayed frame keeps its ID so the receiver can drop duplicates.
 type Message struct {
+	ID   string          `json:"id,omitempty"`
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
 }
 
+// AuthData opens the handshake. Paired devices send their DeviceToken; a
+// phone that just scanned a QR code sends its PairingToken instead. APIKey is
+// the legacy account-key login. ProtocolVersion and Capabilities are absent
+// from ProtocolV1 clients.
 type AuthData struct {
-	APIKey    string `json:"api_key"`
-	DeviceUID string `json:"device_uid"`
+	DeviceToken     string   `json:"device_token,omitempty"`
+	PairingToken    string   `json:"pairing_token,omitempty"`
+	DeviceName      string   `json:"device_name,omitempty"`
+	APIKey          string   `json:"api_key,omitempty"`
+	DeviceUID       string   `json:"device_uid"`
+	ProtocolVersion int      `json:"protocol_version,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+	AppVersion      string   `json:"app_version,omitempty"`
 }
 
+// AuthOKData carries the negotiated protocol; the device must not use
+// anything beyond it for the rest of the connection. DeviceToken is only set
+// when the handshake redeemed a pairing token, or signed a CapDeviceToken
+// device in with the API key; the device must store it and authenticate with
+// it from then on.
 type AuthOKData struct {
-	DeviceID string `json:"device_id"`
+	DeviceID        string   `json:"device_id"`
+	DeviceToken     string   `json:"device_token,omitempty"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
 }
 
 type AuthFailData struct {
@@ -37,8 +85,13 @@ type AuthFailData struct {
 }
 
 type PingData struct {
-	Battery int `json:"battery"`
-	Signal  int `json:"signal"`
+	Battery     int    `json:"battery"`
+	Charging    bool   `json:"charging,omitempty"`
+	Signal      int    `json:"signal"`
+	NetworkType string `json:"network_type,omitempty"`
+	// QueueDepth is how many messages the device holds that it has not sent
+	QueueDepth int `json:"queue_depth,omitempty"`
+	SimCount   int `json:"sim_count,omitempty"`
 }
 
 type PongData struct {
@@ -53,9 +106,11 @@ type SMSReceivedData struct {
 }
 
 type NotificationReceivedData struct {
-	PackageName string    `json:"package_name"`
+	PackageName string    `json:"app_package"`
+	AppName     string    `json:"app_name"`
 	Title       string    `json:"title"`
 	Content     string    `json:"content"`
+	Category    string    `json:"category"`
 	Timestamp   time.Time `json:"timestamp"`
 }
 
@@ -70,9 +125,76 @@ type SMSSentData struct {
 	RequestID string `json:"request_id"`
 }
 
+// SMSDeliveredData carries the carrier delivery report for a sent message.
+type SMSDeliveredData struct {
+	RequestID   string    `json:"request_id"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+// SMSFailedData reports a failed send. SimSlot is optional; older app builds
+// do not send it.
 type SMSFailedData struct {
 	RequestID string `json:"request_id"`
 	Error     string `json:"error"`
+	SimSlot   *int   `json:"sim_slot,omitempty"`
+}
+
+// AckData confirms receipt of the frame with this message ID. The server only
+// acknowledges a device event once it is stored.
+type AckData struct {
+	ID string `json:"id"`
+}
+
+// CommandResultData answers a remote control command. Result is
+// command-specific: StatusReportData for REQUEST_STATUS, the network reply
+// text for RUN_USSD.
+type CommandResultData struct {
+	ID      string          `json:"id"`
+	Success bool            `json:"success"`
+	Error   string          `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+}
+
+type SimStatus struct {
+	Slot     int    `json:"slot"`
+	Carrier  string `json:"carrier,omitempty"`
+	Number   string `json:"number,omitempty"`
+	Signal   int    `json:"signal"`
+	Roaming  bool   `json:"roaming,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+type StatusReportData struct {
+	Sims           []SimStatus `json:"sims"`
+	Battery        int         `json:"battery"`
+	Charging       bool        `json:"charging"`
+	StorageFree    int64       `json:"storage_free"`
+	StorageTotal   int64       `json:"storage_total"`
+	AndroidVersion string      `json:"android_version"`
+	AppVersion     string      `json:"app_version"`
+}
+
+type CapturePolicyData struct {
+	Mode     string   `json:"mode"`
+	Packages []string `json:"packages"`
+}
+
+// SubscribeData replaces the stream's filters. Empty lists match everything;
+// Backfill asks for up to that many recent matching events first.
+type SubscribeData struct {
+	Types     []string `json:"types,omitempty"`
+	DeviceIDs []string `json:"device_ids,omitempty"`
+	Backfill  int      `json:"backfill,omitempty"`
+}
+
+type SubscribedData struct {
+	Types      []string `json:"types"`
+	DeviceIDs  []string `json:"device_ids"`
+	Backfilled int      `json:"backfilled"`
+}
+
+type ErrorData struct {
+	Error string `json:"error"`
 }
 
 func NewMessage(msgType string, data interface{}) (*Message, error) {
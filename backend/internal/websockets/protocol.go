@@ -0,0 +1,173 @@
+package websockets
+
+import (
+	"errors"
+	"sort"
+)
+
+// Protocol versions. Clients that send AUTH without a version speak
+// ProtocolV1.
+const (
+	// ProtocolV1 is the original protocol: fire-and-forget frames
+	ProtocolV1 = 1
+	// ProtocolV2 adds message IDs, ACK frames and capability negotiation
+	ProtocolV2 = 2
+
+	MinProtocolVersion     = ProtocolV1
+	CurrentProtocolVersion = ProtocolV2
+)
+
+// Capabilities a device can announce in AUTH.
+const (
+	// CapCapturePolicy devices apply CAPTURE_POLICY frames
+	CapCapturePolicy = "capture_policy"
+	// CapCommandAck devices ACK commands carrying a message ID, so the
+	// server tracks them in the outbox instead of relying on status reports
+	CapCommandAck = "command_ack"
+	// CapEventAck devices give their events an ID and replay them until the
+	// server ACKs
+	CapEventAck = "event_ack"
+	// CapDeliveryReport devices forward carrier delivery reports as
+	// SMS_DELIVERED
+	CapDeliveryReport = "delivery_report"
+	// CapDeviceToken devices store a device token handed out in AUTH_OK,
+	// so one that still signs in with the API key can be moved onto its
+	// own token
+	CapDeviceToken = "device_token"
+
+	// Remote control commands, one capability each so an app build can
+	// roll them out gradually
+	CapStatusReport = "status_report"
+	CapRestart      = "restart_service"
+	CapRemoteConfig = "remote_config"
+	CapLogUpload    = "log_upload"
+	CapUSSD         = "ussd"
+)
+
+var (
+	ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+	ErrUnsupportedCommand  = errors.New("device does not support this command")
+)
+
+// protocolCapabilities lists what the server offers at each version. V1
+// clients never announce capabilities, so theirs are implied: CAPTURE_POLICY
+// and SMS_DELIVERED shipped before negotiation existed.
+var protocolCapabilities = map[int][]string{
+	ProtocolV1: {CapCapturePolicy, CapDeliveryReport},
+	ProtocolV2: {
+		CapCapturePolicy, CapDeliveryReport, CapCommandAck, CapEventAck, CapDeviceToken,
+		CapStatusReport, CapRestart, CapRemoteConfig, CapLogUpload, CapUSSD,
+	},
+}
+
+// commandCapabilities gates server frames newer than ProtocolV1 on the
+// capability a device must have negotiated to receive them.
+var commandCapabilities = map[string]string{
+	MsgTypeCapturePolicy:  CapCapturePolicy,
+	MsgTypeRequestStatus:  CapStatusReport,
+	MsgTypeRestartService: CapRestart,
+	MsgTypeUpdateConfig:   CapRemoteConfig,
+	MsgTypeUploadLogs:     CapLogUpload,
+	MsgTypeRunUSSD:        CapUSSD,
+}
+
+// frameCapabilities gates device frames on the capabilities under which a
+// device may send them; any one of them will do. A device that did not
+// negotiate them has no business sending the frame, so it is dropped.
+var frameCapabilities = map[string][]string{
+	MsgTypeAck:           {CapCommandAck},
+	MsgTypeSMSDelivered:  {CapDeliveryReport},
+	MsgTypeCommandResult: {CapStatusReport, CapRestart, CapRemoteConfig, CapLogUpload, CapUSSD},
+}
+
+// Negotiated is the protocol agreed on during the handshake.
+type Negotiated struct {
+	Version      int
+	Capabilities map[string]bool
+}
+
+// Negotiate settles on the highest version both sides speak and the
+// capabilities both support. A missing version means ProtocolV1.
+func Negotiate(version int, capabilities []string) (*Negotiated, error) {
+	if version == 0 {
+		version = ProtocolV1
+	}
+	if version < MinProtocolVersion {
+		return nil, ErrUnsupportedProtocol
+	}
+	if version > CurrentProtocolVersion {
+		version = CurrentProtocolVersion
+	}
+
+	offered := protocolCapabilities[version]
+	negotiated := &Negotiated{
+		Version:      version,
+		Capabilities: make(map[string]bool, len(offered)),
+	}
+
+	if version == ProtocolV1 {
+		for _, capability := range offered {
+			negotiated.Capabilities[capability] = true
+		}
+		return negotiated, nil
+	}
+
+	announced := make(map[string]bool, len(capabilities))
+	for _, capability := range capabilities {
+		announced[capability] = true
+	}
+	for _, capability := range offered {
+		if announced[capability] {
+			negotiated.Capabilities[capability] = true
+		}
+	}
+	return negotiated, nil
+}
+
+// negotiatedFrom rebuilds the capabilities another instance negotiated with a
+// device, as shared through the cluster.
+func negotiatedFrom(capabilities []string) *Negotiated {
+	negotiated := &Negotiated{Capabilities: make(map[string]bool, len(capabilities))}
+	for _, capability := range capabilities {
+		negotiated.Capabilities[capability] = true
+	}
+	return negotiated
+}
+
+// Supports reports whether the capability was negotiated.
+func (n *Negotiated) Supports(capability string) bool {
+	return n != nil && n.Capabilities[capability]
+}
+
+// Allows reports whether a server frame of msgType may be sent.
+func (n *Negotiated) Allows(msgType string) bool {
+	capability, gated := commandCapabilities[msgType]
+	return !gated || n.Supports(capability)
+}
+
+// Accepts reports whether a device frame of msgType may be processed.
+func (n *Negotiated) Accepts(msgType string) bool {
+	capabilities, gated := frameCapabilities[msgType]
+	if !gated {
+		return true
+	}
+	for _, capability := range capabilities {
+		if n.Supports(capability) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the negotiated capabilities in a stable order for AUTH_OK.
+func (n *Negotiated) List() []string {
+	if n == nil {
+		return nil
+	}
+	list := make([]string, 0, len(n.Capabilities))
+	for capability := range n.Capabilities {
+		list = append(list, capability)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,191 @@
+package websockets
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var allV2Capabilities = []string{
+	CapCapturePolicy, CapDeliveryReport, CapCommandAck, CapEventAck,
+	CapStatusReport, CapRestart, CapRemoteConfig, CapLogUpload, CapUSSD,
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      int
+		capabilities []string
+		wantVersion  int
+		wantCaps     []string
+		wantErr      error
+	}{
+		{
+			name:        "v1 client without version or capabilities",
+			version:     0,
+			wantVersion: ProtocolV1,
+			wantCaps:    []string{CapCapturePolicy, CapDeliveryReport},
+		},
+		{
+			name:         "v1 client announcing capabilities gets only the implied ones",
+			version:      ProtocolV1,
+			capabilities: []string{CapCommandAck, CapEventAck},
+			wantVersion:  ProtocolV1,
+			wantCaps:     []string{CapCapturePolicy, CapDeliveryReport},
+		},
+		{
+			name:         "current client with every capability",
+			version:      CurrentProtocolVersion,
+			capabilities: allV2Capabilities,
+			wantVersion:  CurrentProtocolVersion,
+			wantCaps:     allV2Capabilities,
+		},
+		{
+			name:         "current client with a subset",
+			version:      ProtocolV2,
+			capabilities: []string{CapCommandAck, CapStatusReport},
+			wantVersion:  ProtocolV2,
+			wantCaps:     []string{CapCommandAck, CapStatusReport},
+		},
+		{
+			name:         "current client without capabilities",
+			version:      ProtocolV2,
+			capabilities: nil,
+			wantVersion:  ProtocolV2,
+			wantCaps:     []string{},
+		},
+		{
+			name:         "unknown capabilities are ignored",
+			version:      ProtocolV2,
+			capabilities: []string{"hologram", CapEventAck},
+			wantVersion:  ProtocolV2,
+			wantCaps:     []string{CapEventAck},
+		},
+		{
+			name:         "future version is downgraded to the current one",
+			version:      CurrentProtocolVersion + 5,
+			capabilities: []string{CapCommandAck, "future_feature"},
+			wantVersion:  CurrentProtocolVersion,
+			wantCaps:     []string{CapCommandAck},
+		},
+		{
+			name:    "negative version is rejected",
+			version: -1,
+			wantErr: ErrUnsupportedProtocol,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Negotiate(tt.version, tt.capabilities)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Negotiate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Negotiate() unexpected error: %v", err)
+			}
+			if got.Version != tt.wantVersion {
+				t.Errorf("Version = %d, want %d", got.Version, tt.wantVersion)
+			}
+
+			want := (&Negotiated{Capabilities: toSet(tt.wantCaps)}).List()
+			if !reflect.DeepEqual(got.List(), want) {
+				t.Errorf("capabilities = %v, want %v", got.List(), want)
+			}
+		})
+	}
+}
+
+func TestNegotiatedAllows(t *testing.T) {
+	v1 := mustNegotiate(t, 0, nil)
+	bare := mustNegotiate(t, ProtocolV2, nil)
+	full := mustNegotiate(t, ProtocolV2, allV2Capabilities)
+	statusOnly := mustNegotiate(t, ProtocolV2, []string{CapStatusReport})
+
+	tests := []struct {
+		name     string
+		protocol *Negotiated
+		msgType  string
+		want     bool
+	}{
+		{"v1 gets SEND_SMS", v1, MsgTypeSendSMS, true},
+		{"v1 gets CAPTURE_POLICY", v1, MsgTypeCapturePolicy, true},
+		{"v1 gets no REQUEST_STATUS", v1, MsgTypeRequestStatus, false},
+		{"v1 gets no RESTART_SERVICE", v1, MsgTypeRestartService, false},
+		{"v1 gets no RUN_USSD", v1, MsgTypeRunUSSD, false},
+		{"bare v2 gets SEND_SMS", bare, MsgTypeSendSMS, true},
+		{"bare v2 gets no CAPTURE_POLICY", bare, MsgTypeCapturePolicy, false},
+		{"bare v2 gets no UPDATE_CONFIG", bare, MsgTypeUpdateConfig, false},
+		{"full v2 gets UPLOAD_LOGS", full, MsgTypeUploadLogs, true},
+		{"full v2 gets RUN_USSD", full, MsgTypeRunUSSD, true},
+		{"status only gets REQUEST_STATUS", statusOnly, MsgTypeRequestStatus, true},
+		{"status only gets no RESTART_SERVICE", statusOnly, MsgTypeRestartService, false},
+		{"nil protocol gets ungated frames", nil, MsgTypeSendSMS, true},
+		{"nil protocol gets no gated frames", nil, MsgTypeRequestStatus, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.protocol.Allows(tt.msgType); got != tt.want {
+				t.Errorf("Allows(%s) = %v, want %v", tt.msgType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiatedAccepts(t *testing.T) {
+	v1 := mustNegotiate(t, 0, nil)
+	bare := mustNegotiate(t, ProtocolV2, nil)
+	full := mustNegotiate(t, ProtocolV2, allV2Capabilities)
+	ussdOnly := mustNegotiate(t, ProtocolV2, []string{CapUSSD})
+
+	tests := []struct {
+		name     string
+		protocol *Negotiated
+		msgType  string
+		want     bool
+	}{
+		{"v1 sends PING", v1, MsgTypePing, true},
+		{"v1 sends SMS_RECEIVED", v1, MsgTypeSMSReceived, true},
+		{"v1 sends SMS_SENT", v1, MsgTypeSMSSent, true},
+		{"v1 sends SMS_DELIVERED", v1, MsgTypeSMSDelivered, true},
+		{"v1 cannot send ACK", v1, MsgTypeAck, false},
+		{"v1 cannot send COMMAND_RESULT", v1, MsgTypeCommandResult, false},
+		{"bare v2 sends SMS_FAILED", bare, MsgTypeSMSFailed, true},
+		{"bare v2 cannot send ACK", bare, MsgTypeAck, false},
+		{"bare v2 cannot send SMS_DELIVERED", bare, MsgTypeSMSDelivered, false},
+		{"bare v2 cannot send COMMAND_RESULT", bare, MsgTypeCommandResult, false},
+		{"full v2 sends ACK", full, MsgTypeAck, true},
+		{"full v2 sends SMS_DELIVERED", full, MsgTypeSMSDelivered, true},
+		{"full v2 sends COMMAND_RESULT", full, MsgTypeCommandResult, true},
+		{"any remote command allows COMMAND_RESULT", ussdOnly, MsgTypeCommandResult, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.protocol.Accepts(tt.msgType); got != tt.want {
+				t.Errorf("Accepts(%s) = %v, want %v", tt.msgType, got, tt.want)
+			}
+		})
+	}
+}
+
+func mustNegotiate(t *testing.T, version int, capabilities []string) *Negotiated {
+	t.Helper()
+	negotiated, err := Negotiate(version, capabilities)
+	if err != nil {
+		t.Fatalf("Negotiate(%d, %v): %v", version, capabilities, err)
+	}
+	return negotiated
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,382 @@
+package websockets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+const (
+	// maxBackfill caps the events replayed on SUBSCRIBE
+	maxBackfill = 100
+	// maxPendingEvents bounds the live events held back while a backfill is
+	// being sent; a connection that overflows it is closed
+	maxPendingEvents = 256
+)
+
+var (
+	ErrInvalidEventType = errors.New("invalid event type")
+	ErrInvalidDeviceID  = errors.New("invalid device id")
+)
+
+var streamEventTypes = map[services.EventType]bool{
+	services.EventLogCreated:    true,
+	services.EventLogStatus:     true,
+	services.EventWebhookResult: true,
+	services.EventDeviceOnline:  true,
+	services.EventDeviceOffline: true,
+	services.EventDeviceBattery: true,
+	services.EventDevicePaired:  true,
+	services.EventCommandResult: true,
+}
+
+// UserHub serves the dashboard sockets of this instance. Events arrive from
+// the EventBus, so users see events raised on any instance or worker.
+type UserHub struct {
+	events services.EventBus
+	mu     sync.RWMutex
+	users  map[uuid.UUID]map[*UserConnection]struct{}
+}
+
+// UserConnection is one dashboard socket. It streams nothing until the
+// client subscribes.
+type UserConnection struct {
+	UserID uuid.UUID
+	Conn   *websocket.Conn
+	Send   chan []byte
+	Hub    *UserHub
+
+	mu         sync.Mutex
+	closed     bool
+	subscribed bool
+	filter     services.EventFilter
+	// pending holds live events while a backfill is in flight, so they
+	// follow it instead of interleaving with it
+	backfilling bool
+	pending     []*services.UserEvent
+}
+
+func NewUserHub(events services.EventBus) *UserHub {
+	return &UserHub{
+		events: events,
+		users:  make(map[uuid.UUID]map[*UserConnection]struct{}),
+	}
+}
+
+func NewUserConnection(userID uuid.UUID, conn *websocket.Conn, hub *UserHub) *UserConnection {
+	return &UserConnection{
+		UserID: userID,
+		Conn:   conn,
+		Send:   make(chan []byte, 256),
+		Hub:    hub,
+	}
+}
+
+// Run delivers bus events to local sockets until ctx is cancelled,
+// resubscribing after a dropped Redis connection.
+func (h *UserHub) Run(ctx context.Context) {
+	for {
+		err := h.events.Subscribe(ctx, h.dispatch)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[events] subscription failed: %v", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (h *UserHub) Register(conn *UserConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conns, ok := h.users[conn.UserID]
+	if !ok {
+		conns = make(map[*UserConnection]struct{})
+		h.users[conn.UserID] = conns
+	}
+	conns[conn] = struct{}{}
+}
+
+func (h *UserHub) Unregister(conn *UserConnection) {
+	h.mu.Lock()
+	if conns, ok := h.users[conn.UserID]; ok {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(h.users, conn.UserID)
+		}
+	}
+	h.mu.Unlock()
+
+	conn.closeSend()
+}
+
+func (h *UserHub) dispatch(event *services.UserEvent) {
+	h.mu.RLock()
+	conns := make([]*UserConnection, 0, len(h.users[event.UserID]))
+	for conn := range h.users[event.UserID] {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	for _, conn := range conns {
+		conn.push(event)
+	}
+}
+
+func (h *UserHub) HandleMessage(conn *UserConnection, msg *Message) {
+	switch msg.Type {
+	case MsgTypePing:
+		conn.sendFrame(MsgTypePong, &PongData{Timestamp: time.Now()})
+	case MsgTypeSubscribe:
+		var data SubscribeData
+		if err := msg.UnmarshalData(&data); err != nil {
+			conn.sendFrame(MsgTypeError, &ErrorData{Error: "invalid subscribe data"})
+			return
+		}
+		if err := h.subscribe(conn, &data); err != nil {
+			conn.sendFrame(MsgTypeError, &ErrorData{Error: err.Error()})
+		}
+	default:
+		conn.sendFrame(MsgTypeError, &ErrorData{Error: "unknown message type: " + msg.Type})
+	}
+}
+
+// subscribe swaps the connection's filter and replays the latest matching
+// events before any live event under the new filter is sent.
+func (h *UserHub) subscribe(conn *UserConnection, data *SubscribeData) error {
+	filter, err := parseEventFilter(data)
+	if err != nil {
+		return err
+	}
+
+	backfill := data.Backfill
+	if backfill < 0 {
+		backfill = 0
+	}
+	if backfill > maxBackfill {
+		backfill = maxBackfill
+	}
+
+	conn.mu.Lock()
+	conn.subscribed = true
+	conn.filter = filter
+	conn.backfilling = true
+	conn.pending = nil
+	conn.mu.Unlock()
+
+	var replay []services.UserEvent
+	if backfill > 0 {
+		replay, err = h.recent(conn.UserID, &filter, backfill)
+		if err != nil {
+			log.Printf("[events] failed to load backfill for user %s: %v", conn.UserID, err)
+		}
+	}
+
+	conn.sendFrame(MsgTypeSubscribed, &SubscribedData{
+		Types:      data.Types,
+		DeviceIDs:  data.DeviceIDs,
+		Backfilled: len(replay),
+	})
+
+	replayed := make(map[string]bool, len(replay))
+	for i := range replay {
+		replayed[replay[i].ID] = true
+		conn.sendFrame(MsgTypeEvent, &replay[i])
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	for _, event := range conn.pending {
+		if !replayed[event.ID] {
+			conn.sendEventLocked(event)
+		}
+	}
+	conn.pending = nil
+	conn.backfilling = false
+	return nil
+}
+
+// recent returns the newest limit events matching filter, oldest first. The
+// bus backfill is shared by all filters, so the whole buffer is scanned.
+func (h *UserHub) recent(userID uuid.UUID, filter *services.EventFilter, limit int) ([]services.UserEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+
+	events, err := h.events.Recent(ctx, userID, services.RecentEventLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]services.UserEvent, 0, limit)
+	for i := len(events) - 1; i >= 0 && len(matched) < limit; i-- {
+		if filter.Matches(&events[i]) {
+			matched = append(matched, events[i])
+		}
+	}
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+	return matched, nil
+}
+
+func parseEventFilter(data *SubscribeData) (services.EventFilter, error) {
+	var filter services.EventFilter
+	for _, t := range data.Types {
+		eventType := services.EventType(t)
+		if !streamEventTypes[eventType] {
+			return filter, ErrInvalidEventType
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+	for _, id := range data.DeviceIDs {
+		deviceID, err := uuid.Parse(id)
+		if err != nil {
+			return filter, ErrInvalidDeviceID
+		}
+		filter.DeviceIDs = append(filter.DeviceIDs, deviceID)
+	}
+	return filter, nil
+}
+
+func (c *UserConnection) push(event *services.UserEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.subscribed || !c.filter.Matches(event) {
+		return
+	}
+	if c.backfilling {
+		if len(c.pending) >= maxPendingEvents {
+			c.closeLocked()
+			return
+		}
+		c.pending = append(c.pending, event)
+		return
+	}
+	c.sendEventLocked(event)
+}
+
+func (c *UserConnection) sendEventLocked(event *services.UserEvent) {
+	msg, err := NewMessage(MsgTypeEvent, event)
+	if err != nil {
+		log.Printf("[events] failed to create event message: %v", err)
+		return
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.enqueueLocked(msgBytes)
+}
+
+func (c *UserConnection) sendFrame(msgType string, data interface{}) {
+	msg, err := NewMessage(msgType, data)
+	if err != nil {
+		log.Printf("failed to create %s message: %v", msgType, err)
+		return
+	}
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enqueueLocked(msgBytes)
+}
+
+// enqueueLocked drops a dashboard that cannot keep up rather than blocking
+// the bus for every other user.
+func (c *UserConnection) enqueueLocked(msgBytes []byte) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.Send <- msgBytes:
+	default:
+		log.Printf("[events] dropping slow dashboard connection of user %s", c.UserID)
+		c.closeLocked()
+	}
+}
+
+func (c *UserConnection) closeSend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked()
+}
+
+func (c *UserConnection) closeLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
+}
+
+func (c *UserConnection) ReadPump() {
+	defer func() {
+		c.Hub.Unregister(c)
+		c.Conn.Close()
+	}()
+
+	c.Conn.SetReadLimit(maxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, msgBytes, err := c.Conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("websocket error: %v", err)
+			}
+			break
+		}
+
+		var msg Message
+		if err := json.Unmarshal(msgBytes, &msg); err != nil {
+			c.sendFrame(MsgTypeError, &ErrorData{Error: "invalid message"})
+			continue
+		}
+		c.Hub.HandleMessage(c, &msg)
+	}
+}
+
+func (c *UserConnection) WritePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+const (
+	TypeCampaignDispatch = "campaign:dispatch"
+	TypeOutboundExpiry   = "sms:expire"
+	TypeCommandExpiry    = "command:expire"
+	TypeTelemetryCompact = "telemetry:compact"
+	TypeIdempotencyPurge = "idempotency:purge"
+
+	periodicQueue = "default"
+	// periodicTimeout bounds one run and is also how long its uniqueness
+	// lock holds, so a stuck run cannot overlap the next one
+	periodicTimeout = time.Minute
+)
+
+// periodicTask is a job the scheduler enqueues on a fixed interval. Every
+// replica runs a scheduler; the Unique option lets only one copy of a task
+// wait or run at a time.
+type periodicTask struct {
+	taskType string
+	interval time.Duration
+}
+
+var periodicTasks = []periodicTask{
+	{TypeCampaignDispatch, 5 * time.Second},
+	{TypeOutboundExpiry, 30 * time.Second},
+	{TypeCommandExpiry, 30 * time.Second},
+	{TypeTelemetryCompact, 15 * time.Minute},
+	{TypeIdempotencyPurge, time.Hour},
+}
+
+func newPeriodicScheduler(redisAddr string) (*asynq.Scheduler, error) {
+	scheduler := asynq.NewScheduler(
+		asynq.RedisClientOpt{Addr: redisAddr},
+		&asynq.SchedulerOpts{
+			PostEnqueueFunc: func(info *asynq.TaskInfo, err error) {
+				if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+					log.Printf("[worker] failed to enqueue periodic task: %v", err)
+				}
+			},
+		},
+	)
+
+	for _, task := range periodicTasks {
+		_, err := scheduler.Register("@every "+task.interval.String(), asynq.NewTask(task.taskType, nil),
+			asynq.Unique(periodicTimeout),
+			asynq.MaxRetry(0),
+			asynq.Timeout(periodicTimeout),
+			asynq.Queue(periodicQueue),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scheduler, nil
+}
+
+// PeriodicHandler runs the periodic tasks against the services. Failures are
+// logged rather than returned: a failed task keeps its uniqueness lock until
+// periodicTimeout, while the next tick retries soon enough anyway.
+type PeriodicHandler struct {
+	campaignService services.CampaignService
+	outbound        services.OutboundService
+	commands        services.CommandService
+	telemetry       services.TelemetryService
+	idempotency     services.IdempotencyService
+}
+
+func NewPeriodicHandler(
+	campaignService services.CampaignService,
+	outbound services.OutboundService,
+	commands services.CommandService,
+	telemetry services.TelemetryService,
+	idempotency services.IdempotencyService,
+) *PeriodicHandler {
+	return &PeriodicHandler{
+		campaignService: campaignService,
+		outbound:        outbound,
+		commands:        commands,
+		telemetry:       telemetry,
+		idempotency:     idempotency,
+	}
+}
+
+func (h *PeriodicHandler) HandleCampaignDispatchTask(ctx context.Context, t *asynq.Task) error {
+	submitted, err := h.campaignService.DispatchDue(ctx)
+	if err != nil {
+		log.Printf("[campaign] failed to dispatch campaigns: %v", err)
+		return nil
+	}
+	if submitted > 0 {
+		log.Printf("[campaign] submitted %d recipients", submitted)
+	}
+	return nil
+}
+
+func (h *PeriodicHandler) HandleOutboundExpiryTask(ctx context.Context, t *asynq.Task) error {
+	count, err := h.outbound.ExpireStale()
+	if err != nil {
+		log.Printf("[outbound] failed to expire queued messages: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[outbound] expired %d queued messages", count)
+	}
+	return nil
+}
+
+// HandleCommandExpiryTask fails commands nobody acknowledged in time, which
+// also fails any message they were carrying.
+func (h *PeriodicHandler) HandleCommandExpiryTask(ctx context.Context, t *asynq.Task) error {
+	count, err := h.commands.ExpireStale()
+	if err != nil {
+		log.Printf("[commands] failed to expire commands: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[commands] expired %d unacknowledged commands", count)
+	}
+	return nil
+}
+
+func (h *PeriodicHandler) HandleTelemetryCompactTask(ctx context.Context, t *asynq.Task) error {
+	if err := h.telemetry.Compact(); err != nil {
+		log.Printf("[telemetry] failed to compact telemetry: %v", err)
+	}
+	return nil
+}
+
+func (h *PeriodicHandler) HandleIdempotencyPurgeTask(ctx context.Context, t *asynq.Task) error {
+	count, err := h.idempotency.PurgeExpired()
+	if err != nil {
+		log.Printf("[idempotency] failed to purge expired keys: %v", err)
+	}
+	if count > 0 {
+		log.Printf("[idempotency] purged %d expired keys", count)
+	}
+	return nil
+}
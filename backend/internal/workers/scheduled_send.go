@@ -0,0 +1,114 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+const (
+	TypeScheduledSend = "sms:scheduled"
+
+	scheduledQueue = "default"
+)
+
+type ScheduledSendPayload struct {
+	ScheduleID string `json:"schedule_id"`
+	RunAt      int64  `json:"run_at"`
+}
+
+// SMSScheduler queues scheduled messages as Asynq tasks processed at their
+// run time. It implements services.MessageScheduler.
+type SMSScheduler struct {
+	client    *asynq.Client
+	inspector *asynq.Inspector
+}
+
+func NewSMSScheduler(redisAddr string) *SMSScheduler {
+	opt := asynq.RedisClientOpt{Addr: redisAddr}
+	return &SMSScheduler{
+		client:    asynq.NewClient(opt),
+		inspector: asynq.NewInspector(opt),
+	}
+}
+
+func (s *SMSScheduler) Close() error {
+	if err := s.inspector.Close(); err != nil {
+		return err
+	}
+	return s.client.Close()
+}
+
+// Schedule enqueues the run under a deterministic task ID, so queueing the
+// same run twice is a no-op.
+func (s *SMSScheduler) Schedule(scheduleID uuid.UUID, runAt time.Time) error {
+	task, err := NewScheduledSendTask(&ScheduledSendPayload{
+		ScheduleID: scheduleID.String(),
+		RunAt:      runAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.Enqueue(task,
+		asynq.ProcessAt(runAt),
+		asynq.TaskID(scheduledTaskID(scheduleID, runAt)),
+		asynq.MaxRetry(3),
+		asynq.Timeout(30*time.Second),
+		asynq.Queue(scheduledQueue),
+	)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+func (s *SMSScheduler) Unschedule(scheduleID uuid.UUID, runAt time.Time) error {
+	err := s.inspector.DeleteTask(scheduledQueue, scheduledTaskID(scheduleID, runAt))
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+	return err
+}
+
+func NewScheduledSendTask(payload *ScheduledSendPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeScheduledSend, data), nil
+}
+
+func scheduledTaskID(scheduleID uuid.UUID, runAt time.Time) string {
+	return fmt.Sprintf("schedule:%s:%d", scheduleID, runAt.Unix())
+}
+
+type ScheduledSendHandler struct {
+	scheduleService services.ScheduleService
+}
+
+func NewScheduledSendHandler(scheduleService services.ScheduleService) *ScheduledSendHandler {
+	return &ScheduledSendHandler{scheduleService: scheduleService}
+}
+
+func (h *ScheduledSendHandler) HandleScheduledSendTask(ctx context.Context, t *asynq.Task) error {
+	var payload ScheduledSendPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		log.Printf("[schedule] failed to unmarshal payload: %v", err)
+		return fmt.Errorf("unmarshal payload: %w", asynq.SkipRetry)
+	}
+
+	scheduleID, err := uuid.Parse(payload.ScheduleID)
+	if err != nil {
+		return fmt.Errorf("invalid schedule id %q: %w", payload.ScheduleID, asynq.SkipRetry)
+	}
+
+	return h.scheduleService.Run(scheduleID, time.Unix(payload.RunAt, 0).UTC())
+}
@@ -1,6 +1,7 @@
 package workers
 
 import (
+	"context"
 	"log"
 
 	"github.com/hibiken/asynq"
@@ -8,10 +9,21 @@ import (
 )
 
 type WorkerServer struct {
-	srv *asynq.Server
+	srv       *asynq.Server
+	scheduler *asynq.Scheduler
 }
 
-func StartWorkerServer(redisAddr string, logService services.LogService) *WorkerServer {
+func StartWorkerServer(
+	redisAddr string,
+	logService services.LogService,
+	scheduleService services.ScheduleService,
+	events services.EventBus,
+	campaignService services.CampaignService,
+	outbound services.OutboundService,
+	commands services.CommandService,
+	telemetry services.TelemetryService,
+	idempotency services.IdempotencyService,
+) *WorkerServer {
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: redisAddr},
 		asynq.Config{
@@ -20,15 +32,29 @@ func StartWorkerServer(redisAddr string, logService services.LogService) *Worker
 				"webhooks": 6,
 				"default":  4,
 			},
-			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx asynq.Context, task *asynq.Task, err error) {
+			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
 				log.Printf("[worker] task %s failed: %v", task.Type(), err)
 			}),
 		},
 	)
 
-	handler := NewWebhookHandler(logService)
+	handler := NewWebhookHandler(logService, events)
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(TypeWebhookDispatch, handler.HandleWebhookTask)
+	mux.HandleFunc(TypeStatusCallback, handler.HandleStatusCallbackTask)
+	mux.HandleFunc(TypeScheduledSend, NewScheduledSendHandler(scheduleService).HandleScheduledSendTask)
+
+	periodic := NewPeriodicHandler(campaignService, outbound, commands, telemetry, idempotency)
+	mux.HandleFunc(TypeCampaignDispatch, periodic.HandleCampaignDispatchTask)
+	mux.HandleFunc(TypeOutboundExpiry, periodic.HandleOutboundExpiryTask)
+	mux.HandleFunc(TypeCommandExpiry, periodic.HandleCommandExpiryTask)
+	mux.HandleFunc(TypeTelemetryCompact, periodic.HandleTelemetryCompactTask)
+	mux.HandleFunc(TypeIdempotencyPurge, periodic.HandleIdempotencyPurgeTask)
+
+	scheduler, err := newPeriodicScheduler(redisAddr)
+	if err != nil {
+		log.Fatalf("[worker] could not register periodic tasks: %v", err)
+	}
 
 	go func() {
 		log.Printf("[worker] starting asynq server on redis=%s", redisAddr)
@@ -37,10 +63,17 @@ func StartWorkerServer(redisAddr string, logService services.LogService) *Worker
 		}
 	}()
 
-	return &WorkerServer{srv: srv}
+	if err := scheduler.Start(); err != nil {
+		log.Fatalf("[worker] could not start periodic scheduler: %v", err)
+	}
+
+	return &WorkerServer{srv: srv, scheduler: scheduler}
 }
 
 func (w *WorkerServer) Shutdown() {
+	if w.scheduler != nil {
+		w.scheduler.Shutdown()
+	}
 	if w.srv != nil {
 		w.srv.Shutdown()
 	}
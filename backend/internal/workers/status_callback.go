@@ -0,0 +1,210 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
+)
+
+const (
+	TypeStatusCallback = "status:callback"
+
+	SignatureHeader = "X-TingHook-Signature"
+	TimestampHeader = "X-TingHook-Timestamp"
+)
+
+// Status callback event names, one per outbound lifecycle stage.
+const (
+	StatusEventQueued     = "queued"
+	StatusEventDispatched = "dispatched"
+	StatusEventSent       = "sent"
+	StatusEventDelivered  = "delivered"
+	StatusEventFailed     = "failed"
+	StatusEventExpired    = "expired"
+)
+
+type StatusCallbackPayload struct {
+	URL    string              `json:"url"`
+	Secret string              `json:"secret"`
+	Event  StatusCallbackEvent `json:"event"`
+}
+
+type StatusCallbackEvent struct {
+	RequestID string `json:"request_id"`
+	Event     string `json:"event"`
+	// Sequence grows with every transition of the message, across failover
+	// hops, so receivers can order callbacks that retries deliver late
+	Sequence  int    `json:"sequence"`
+	DeviceID  string `json:"device_id,omitempty"`
+	Phone     string `json:"phone"`
+	SimSlot   int    `json:"sim_slot"`
+	Reason    string `json:"reason,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+func NewStatusCallbackTask(payload *StatusCallbackPayload) (*asynq.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeStatusCallback, data), nil
+}
+
+func (d *WebhookDispatcher) DispatchStatusCallback(payload *StatusCallbackPayload) error {
+	task, err := NewStatusCallbackTask(payload)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.Enqueue(task,
+		asynq.MaxRetry(5),
+		asynq.Timeout(30*time.Second),
+		asynq.Queue("webhooks"),
+	)
+	return err
+}
+
+// SignStatusCallback computes the signature sent in SignatureHeader: a hex
+// HMAC-SHA256 over "<timestamp>.<body>" keyed with the user's callback secret.
+func SignStatusCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *WebhookHandler) HandleStatusCallbackTask(ctx context.Context, t *asynq.Task) error {
+	var payload StatusCallbackPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	body, err := json.Marshal(payload.Event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, payload.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TingHook-Webhook/1.0")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, SignStatusCallback(payload.Secret, timestamp, body))
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("status callback request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status callback returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("[webhook] delivered %s callback for request %s", payload.Event.Event, payload.Event.RequestID)
+	return nil
+}
+
+// NewStatusCallbackListener enqueues a signed callback for every status
+// transition of an outbound message that has a callback URL, falling back to
+// the owner's default URL.
+func NewStatusCallbackListener(dispatcher *WebhookDispatcher, userService services.UserService) services.StatusListener {
+	return func(msgLog *models.MessageLog) {
+		if msgLog.Direction != models.DirectionOutbound {
+			return
+		}
+
+		user, err := userService.GetByID(msgLog.UserID)
+		if err != nil {
+			log.Printf("[webhook] failed to load user for request %s: %v", msgLog.RequestID, err)
+			return
+		}
+
+		url := msgLog.StatusCallbackURL
+		if url == "" {
+			url = user.StatusCallbackURL
+		}
+		if url == "" {
+			return
+		}
+
+		secret, err := userService.EnsureCallbackSecret(user.ID)
+		if err != nil {
+			log.Printf("[webhook] failed to load callback secret for user %s: %v", user.ID, err)
+			return
+		}
+
+		event := StatusCallbackEvent{
+			RequestID: msgLog.RequestID,
+			Event:     statusEvent(msgLog.Status),
+			Sequence:  statusSequence(msgLog),
+			Phone:     msgLog.Receiver,
+			SimSlot:   msgLog.SimSlot,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		if msgLog.DeviceID != nil {
+			event.DeviceID = msgLog.DeviceID.String()
+		}
+		if msgLog.Status == models.StatusFailed || msgLog.Status == models.StatusExpired {
+			event.Reason = msgLog.ErrorMessage
+		}
+
+		err = dispatcher.DispatchStatusCallback(&StatusCallbackPayload{
+			URL:    url,
+			Secret: secret,
+			Event:  event,
+		})
+		if err != nil {
+			log.Printf("[webhook] failed to enqueue status callback for request %s: %v", msgLog.RequestID, err)
+		}
+	}
+}
+
+// statusSequence numbers a message's transitions without extra state: each
+// delivery attempt spans four stages, queued, dispatched, sent and final,
+// and a failover hop starts the next four.
+func statusSequence(msgLog *models.MessageLog) int {
+	stage := 4
+	switch msgLog.Status {
+	case models.StatusQueued:
+		stage = 1
+	case models.StatusPending:
+		stage = 2
+	case models.StatusSent:
+		stage = 3
+	}
+	return msgLog.RetryCount*4 + stage
+}
+
+func statusEvent(status models.MessageStatus) string {
+	switch status {
+	case models.StatusQueued:
+		return StatusEventQueued
+	case models.StatusPending:
+		return StatusEventDispatched
+	case models.StatusSent:
+		return StatusEventSent
+	case models.StatusDelivered:
+		return StatusEventDelivered
+	case models.StatusExpired:
+		return StatusEventExpired
+	default:
+		return StatusEventFailed
+	}
+}
@@ -12,7 +12,10 @@ const (
 )
 
 type WebhookPayload struct {
-	RuleID       uint        `json:"rule_id"`
+	RuleID uint `json:"rule_id"`
+	// UserID routes the delivery result to the user's live event stream;
+	// tasks queued before it existed carry none
+	UserID       string      `json:"user_id,omitempty"`
 	WebhookURL   string      `json:"webhook_url"`
 	Method       string      `json:"method"`
 	SecretHeader string      `json:"secret_header"`
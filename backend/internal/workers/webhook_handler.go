@@ -10,7 +10,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"github.com/octopuslowtech/tinghook-project/backend/internal/handlers/dto"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/models"
 	"github.com/octopuslowtech/tinghook-project/backend/internal/services"
 )
@@ -18,12 +20,14 @@ import (
 type WebhookHandler struct {
 	httpClient *http.Client
 	logService services.LogService
+	events     services.EventBus
 }
 
-func NewWebhookHandler(logService services.LogService) *WebhookHandler {
+func NewWebhookHandler(logService services.LogService, events services.EventBus) *WebhookHandler {
 	return &WebhookHandler{
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		logService: logService,
+		events:     events,
 	}
 }
 
@@ -55,7 +59,9 @@ func (h *WebhookHandler) HandleWebhookTask(ctx context.Context, t *asynq.Task) e
 
 	resp, err := h.httpClient.Do(req)
 	if err != nil {
-		h.updateLogStatus(payload.LogID, models.StatusFailed, fmt.Sprintf("request failed: %v", err))
+		errMsg := fmt.Sprintf("request failed: %v", err)
+		h.updateLogStatus(payload.LogID, models.StatusFailed, errMsg)
+		h.publishResult(ctx, &payload, 0, errMsg)
 		return fmt.Errorf("webhook request failed: %w", err)
 	}
 	defer resp.Body.Close()
@@ -65,11 +71,13 @@ func (h *WebhookHandler) HandleWebhookTask(ctx context.Context, t *asynq.Task) e
 	if resp.StatusCode >= 400 {
 		errMsg := fmt.Sprintf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
 		h.updateLogStatus(payload.LogID, models.StatusFailed, errMsg)
+		h.publishResult(ctx, &payload, resp.StatusCode, errMsg)
 		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
 
 	log.Printf("[webhook] successfully delivered to %s (status=%d)", payload.WebhookURL, resp.StatusCode)
 	h.updateLogStatus(payload.LogID, models.StatusDelivered, "")
+	h.publishResult(ctx, &payload, resp.StatusCode, "")
 
 	return nil
 }
@@ -82,3 +90,31 @@ func (h *WebhookHandler) updateLogStatus(logID uint, status models.MessageStatus
 		log.Printf("[webhook] failed to update log status for id=%d: %v", logID, err)
 	}
 }
+
+// publishResult streams the outcome of this attempt to the rule owner's
+// dashboard. An empty errMsg means the webhook was delivered.
+func (h *WebhookHandler) publishResult(ctx context.Context, payload *WebhookPayload, statusCode int, errMsg string) {
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+	var deviceID *uuid.UUID
+	if id, err := uuid.Parse(payload.Data.DeviceID); err == nil {
+		deviceID = &id
+	}
+
+	services.PublishEvent(h.events, userID, services.EventWebhookResult, deviceID, &dto.WebhookResultData{
+		RuleID:     payload.RuleID,
+		LogID:      payload.LogID,
+		WebhookURL: payload.WebhookURL,
+		Success:    errMsg == "",
+		StatusCode: statusCode,
+		Error:      errMsg,
+		Attempt:    retried + 1,
+		Final:      errMsg == "" || retried >= maxRetry,
+	})
+}
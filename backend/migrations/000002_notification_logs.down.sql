@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_message_logs_app_package;
+DROP INDEX IF EXISTS idx_message_logs_type;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS category;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS title;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS app_name;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS app_package;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS type;
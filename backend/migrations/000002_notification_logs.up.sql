@@ -0,0 +1,10 @@
+-- Persist inbound notifications as first-class message logs
+
+ALTER TABLE message_logs ADD COLUMN type VARCHAR(20) NOT NULL DEFAULT 'sms';
+ALTER TABLE message_logs ADD COLUMN app_package VARCHAR(255);
+ALTER TABLE message_logs ADD COLUMN app_name VARCHAR(255);
+ALTER TABLE message_logs ADD COLUMN title TEXT;
+ALTER TABLE message_logs ADD COLUMN category VARCHAR(50);
+
+CREATE INDEX idx_message_logs_type ON message_logs(type);
+CREATE INDEX idx_message_logs_app_package ON message_logs(app_package);
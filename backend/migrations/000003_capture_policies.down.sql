@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_capture_policies_device_scope;
+DROP INDEX IF EXISTS idx_capture_policies_account_scope;
+DROP INDEX IF EXISTS idx_capture_policies_device_id;
+DROP INDEX IF EXISTS idx_capture_policies_user_id;
+DROP TABLE IF EXISTS capture_policies;
@@ -0,0 +1,15 @@
+-- Per-account and per-device notification capture policies
+
+CREATE TABLE capture_policies (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id UUID REFERENCES devices(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL DEFAULT 'blocklist',
+    packages TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX idx_capture_policies_user_id ON capture_policies(user_id);
+CREATE INDEX idx_capture_policies_device_id ON capture_policies(device_id);
+CREATE UNIQUE INDEX idx_capture_policies_account_scope ON capture_policies(user_id) WHERE device_id IS NULL;
+CREATE UNIQUE INDEX idx_capture_policies_device_scope ON capture_policies(user_id, device_id) WHERE device_id IS NOT NULL;
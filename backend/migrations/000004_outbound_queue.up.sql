@@ -0,0 +1,5 @@
+-- Store-and-forward queue for outbound SMS while devices are offline
+
+ALTER TABLE message_logs ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX idx_message_logs_queued ON message_logs(user_id, status, expires_at) WHERE status = 'queued';
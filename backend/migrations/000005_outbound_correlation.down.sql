@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_message_logs_request_id;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS failed_at;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS delivered_at;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS sent_at;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS dispatched_at;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS request_id;
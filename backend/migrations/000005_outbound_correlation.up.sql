@@ -0,0 +1,9 @@
+-- First-class correlation IDs and lifecycle timestamps for outbound SMS
+
+ALTER TABLE message_logs ADD COLUMN request_id VARCHAR(36);
+ALTER TABLE message_logs ADD COLUMN dispatched_at TIMESTAMP;
+ALTER TABLE message_logs ADD COLUMN sent_at TIMESTAMP;
+ALTER TABLE message_logs ADD COLUMN delivered_at TIMESTAMP;
+ALTER TABLE message_logs ADD COLUMN failed_at TIMESTAMP;
+
+CREATE UNIQUE INDEX idx_message_logs_request_id ON message_logs(request_id) WHERE request_id IS NOT NULL AND request_id <> '';
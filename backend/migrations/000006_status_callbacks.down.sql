@@ -0,0 +1,4 @@
+ALTER TABLE message_logs DROP COLUMN IF EXISTS status_callback_url;
+
+ALTER TABLE users DROP COLUMN IF EXISTS callback_secret;
+ALTER TABLE users DROP COLUMN IF EXISTS status_callback_url;
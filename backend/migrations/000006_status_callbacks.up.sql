@@ -0,0 +1,6 @@
+-- Status callback webhooks for outbound messages
+
+ALTER TABLE users ADD COLUMN status_callback_url TEXT;
+ALTER TABLE users ADD COLUMN callback_secret VARCHAR(64);
+
+ALTER TABLE message_logs ADD COLUMN status_callback_url TEXT;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_message_logs_receiver;
+
+DROP INDEX IF EXISTS idx_routing_prefixes_device_id;
+DROP INDEX IF EXISTS idx_routing_prefixes_user_id;
+DROP TABLE IF EXISTS routing_prefixes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS routing_strategy;
@@ -0,0 +1,16 @@
+-- Device selection strategies for outbound SMS
+
+ALTER TABLE users ADD COLUMN routing_strategy VARCHAR(20) DEFAULT 'round_robin';
+
+CREATE TABLE routing_prefixes (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    prefix VARCHAR(20) NOT NULL,
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    sim_slot INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX idx_routing_prefixes_user_id ON routing_prefixes(user_id);
+CREATE INDEX idx_routing_prefixes_device_id ON routing_prefixes(device_id);
+
+CREATE INDEX idx_message_logs_receiver ON message_logs(user_id, receiver, created_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_delivery_attempts_log_id;
+DROP TABLE IF EXISTS delivery_attempts;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS pinned;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS max_attempts;
+
+ALTER TABLE devices DROP COLUMN IF EXISTS sim_count;
+ALTER TABLE users DROP COLUMN IF EXISTS failover_max_attempts;
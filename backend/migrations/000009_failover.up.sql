@@ -0,0 +1,20 @@
+-- Failover of outbound SMS across devices and SIMs
+
+ALTER TABLE users ADD COLUMN failover_max_attempts INTEGER DEFAULT 1;
+ALTER TABLE devices ADD COLUMN sim_count INTEGER DEFAULT 1;
+
+ALTER TABLE message_logs ADD COLUMN max_attempts INTEGER DEFAULT 1;
+ALTER TABLE message_logs ADD COLUMN pinned BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE delivery_attempts (
+    id SERIAL PRIMARY KEY,
+    log_id BIGINT NOT NULL REFERENCES message_logs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    device_id UUID NOT NULL,
+    sim_slot INTEGER DEFAULT 0,
+    status VARCHAR(20) DEFAULT 'pending',
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+CREATE INDEX idx_delivery_attempts_log_id ON delivery_attempts(log_id);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_message_logs_campaign_id;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS campaign_id;
+
+DROP INDEX IF EXISTS idx_campaign_recipients_status;
+DROP INDEX IF EXISTS idx_campaign_recipients_campaign_id;
+DROP TABLE IF EXISTS campaign_recipients;
+
+DROP INDEX IF EXISTS idx_campaigns_status;
+DROP INDEX IF EXISTS idx_campaigns_user_id;
+DROP TABLE IF EXISTS campaigns;
@@ -0,0 +1,37 @@
+-- Bulk sends grouped into campaigns
+
+CREATE TABLE campaigns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255),
+    content TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'running',
+    total INTEGER NOT NULL,
+    strategy VARCHAR(20),
+    rate_per_minute INTEGER NOT NULL,
+    ttl_seconds INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 1,
+    status_callback_url TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    completed_at TIMESTAMP
+);
+CREATE INDEX idx_campaigns_user_id ON campaigns(user_id);
+CREATE INDEX idx_campaigns_status ON campaigns(status);
+
+CREATE TABLE campaign_recipients (
+    id BIGSERIAL PRIMARY KEY,
+    campaign_id UUID NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    phone VARCHAR(50) NOT NULL,
+    variables TEXT,
+    status VARCHAR(20) DEFAULT 'pending',
+    request_id VARCHAR(36),
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    submitted_at TIMESTAMP
+);
+CREATE INDEX idx_campaign_recipients_campaign_id ON campaign_recipients(campaign_id);
+CREATE INDEX idx_campaign_recipients_status ON campaign_recipients(campaign_id, status);
+
+ALTER TABLE message_logs ADD COLUMN campaign_id UUID REFERENCES campaigns(id) ON DELETE SET NULL;
+CREATE INDEX idx_message_logs_campaign_id ON message_logs(campaign_id);
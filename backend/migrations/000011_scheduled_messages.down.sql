@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_scheduled_messages_next_run_at;
+DROP INDEX IF EXISTS idx_scheduled_messages_status;
+DROP INDEX IF EXISTS idx_scheduled_messages_user_id;
+DROP TABLE IF EXISTS scheduled_messages;
@@ -0,0 +1,27 @@
+-- Outbound messages deferred to a send time, optionally recurring
+
+CREATE TABLE scheduled_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(50) NOT NULL,
+    content TEXT NOT NULL,
+    device_id UUID REFERENCES devices(id) ON DELETE SET NULL,
+    sim_slot INTEGER DEFAULT 0,
+    strategy VARCHAR(20),
+    ttl_seconds INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 1,
+    timezone VARCHAR(64) DEFAULT 'UTC',
+    recurrence VARCHAR(255),
+    status VARCHAR(20) DEFAULT 'scheduled',
+    next_run_at TIMESTAMP,
+    occurrences INTEGER DEFAULT 0,
+    last_run_at TIMESTAMP,
+    last_request_id VARCHAR(36),
+    last_error TEXT,
+    status_callback_url TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX idx_scheduled_messages_user_id ON scheduled_messages(user_id);
+CREATE INDEX idx_scheduled_messages_status ON scheduled_messages(status);
+CREATE INDEX idx_scheduled_messages_next_run_at ON scheduled_messages(next_run_at);
@@ -0,0 +1,4 @@
+ALTER TABLE campaigns DROP COLUMN IF EXISTS segments;
+
+DROP INDEX IF EXISTS idx_message_templates_user_name;
+DROP TABLE IF EXISTS message_templates;
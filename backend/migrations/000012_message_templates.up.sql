@@ -0,0 +1,15 @@
+-- Reusable message templates with locale variants
+
+CREATE TABLE message_templates (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    body TEXT NOT NULL,
+    locales TEXT,
+    defaults TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_message_templates_user_name ON message_templates(user_id, name);
+
+ALTER TABLE campaigns ADD COLUMN segments INTEGER DEFAULT 0;
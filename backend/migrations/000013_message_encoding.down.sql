@@ -0,0 +1,4 @@
+ALTER TABLE campaigns DROP COLUMN IF EXISTS transliterate;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS segments;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS encoding;
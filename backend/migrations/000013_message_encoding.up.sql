@@ -0,0 +1,6 @@
+-- SMS encoding and segment count of outbound messages
+
+ALTER TABLE message_logs ADD COLUMN encoding VARCHAR(10);
+ALTER TABLE message_logs ADD COLUMN segments INTEGER DEFAULT 0;
+
+ALTER TABLE campaigns ADD COLUMN transliterate BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,14 @@
+-- Region used to read national-format phone numbers
+
+ALTER TABLE users ADD COLUMN default_country VARCHAR(2) DEFAULT 'VN';
+
+-- Bring existing Vietnamese mobile numbers stored as typed to E.164 so
+-- history matches newly normalized traffic
+UPDATE message_logs SET receiver = '+84' || substr(receiver, 2)
+WHERE receiver ~ '^0[35789][0-9]{8}$';
+UPDATE message_logs SET sender = '+84' || substr(sender, 2)
+WHERE sender ~ '^0[35789][0-9]{8}$';
+UPDATE message_logs SET receiver = '+' || receiver
+WHERE receiver ~ '^84[35789][0-9]{8}$';
+UPDATE message_logs SET sender = '+' || sender
+WHERE sender ~ '^84[35789][0-9]{8}$';
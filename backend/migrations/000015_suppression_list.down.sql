@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_suppressed_user_phone;
+DROP TABLE IF EXISTS suppressed_numbers;
+
+ALTER TABLE users DROP COLUMN IF EXISTS opt_in_keywords;
+ALTER TABLE users DROP COLUMN IF EXISTS opt_out_keywords;
@@ -0,0 +1,15 @@
+-- Opt-out keywords and per-user suppression list
+
+ALTER TABLE users ADD COLUMN opt_out_keywords TEXT;
+ALTER TABLE users ADD COLUMN opt_in_keywords TEXT;
+
+CREATE TABLE suppressed_numbers (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    phone VARCHAR(20) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    keyword VARCHAR(50),
+    device_id UUID,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_suppressed_user_phone ON suppressed_numbers(user_id, phone);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_message_logs_thread;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS read_at;
+ALTER TABLE message_logs DROP COLUMN IF EXISTS remote_number;
@@ -0,0 +1,9 @@
+-- Conversation threads over SMS message logs
+
+ALTER TABLE message_logs ADD COLUMN remote_number VARCHAR(50);
+ALTER TABLE message_logs ADD COLUMN read_at TIMESTAMP;
+
+UPDATE message_logs SET remote_number = CASE WHEN direction = 'inbound' THEN sender ELSE receiver END
+    WHERE type = 'sms';
+
+CREATE INDEX idx_message_logs_thread ON message_logs(user_id, device_id, sim_slot, remote_number, created_at);
@@ -0,0 +1,19 @@
+-- Acknowledged device commands
+
+CREATE TABLE device_commands (
+    id UUID PRIMARY KEY,
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    ref VARCHAR(100),
+    payload BYTEA,
+    status VARCHAR(20) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 1,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    sent_at TIMESTAMP NOT NULL,
+    acked_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_device_commands_device_status ON device_commands(device_id, status);
+CREATE INDEX idx_device_commands_ref ON device_commands(ref);
+CREATE INDEX idx_device_commands_expires_at ON device_commands(expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_message_logs_device_event;
+
+ALTER TABLE message_logs DROP COLUMN IF EXISTS event_id;
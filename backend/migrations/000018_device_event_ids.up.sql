@@ -0,0 +1,5 @@
+-- Idempotent ingestion of device events
+
+ALTER TABLE message_logs ADD COLUMN event_id VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_message_logs_device_event ON message_logs(device_id, event_id) WHERE event_id IS NOT NULL;
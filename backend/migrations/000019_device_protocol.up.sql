@@ -0,0 +1,3 @@
+-- Negotiated device protocol version
+
+ALTER TABLE devices ADD COLUMN protocol_version INTEGER DEFAULT 1;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_devices_token_hash;
+
+ALTER TABLE devices DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE devices DROP COLUMN IF EXISTS token_issued_at;
+ALTER TABLE devices DROP COLUMN IF EXISTS token_hash;
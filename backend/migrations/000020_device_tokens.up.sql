@@ -0,0 +1,7 @@
+-- Per-device socket credentials
+
+ALTER TABLE devices ADD COLUMN token_hash VARCHAR(64);
+ALTER TABLE devices ADD COLUMN token_issued_at TIMESTAMP;
+ALTER TABLE devices ADD COLUMN revoked_at TIMESTAMP;
+
+CREATE INDEX idx_devices_token_hash ON devices(token_hash);
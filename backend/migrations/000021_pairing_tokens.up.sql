@@ -0,0 +1,16 @@
+-- Single-use QR pairing tokens
+
+CREATE TABLE pairing_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    device_name VARCHAR(100),
+    status VARCHAR(20) DEFAULT 'pending',
+    device_id UUID REFERENCES devices(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    paired_at TIMESTAMP
+);
+CREATE UNIQUE INDEX idx_pairing_tokens_token_hash ON pairing_tokens(token_hash);
+CREATE INDEX idx_pairing_tokens_user_id ON pairing_tokens(user_id);
+CREATE INDEX idx_pairing_tokens_expires_at ON pairing_tokens(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE device_commands DROP COLUMN IF EXISTS completed_at;
+ALTER TABLE device_commands DROP COLUMN IF EXISTS result;
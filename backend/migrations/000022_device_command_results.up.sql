@@ -0,0 +1,4 @@
+-- Results of remote control commands
+
+ALTER TABLE device_commands ADD COLUMN result BYTEA;
+ALTER TABLE device_commands ADD COLUMN completed_at TIMESTAMP;
@@ -0,0 +1,19 @@
+-- Device health time series
+
+CREATE TABLE device_telemetry (
+    id SERIAL PRIMARY KEY,
+    device_id UUID NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    resolution VARCHAR(10) NOT NULL DEFAULT 'raw',
+    recorded_at TIMESTAMP NOT NULL,
+    battery INTEGER DEFAULT 0,
+    min_battery INTEGER DEFAULT 0,
+    charging BOOLEAN DEFAULT FALSE,
+    signal INTEGER DEFAULT 0,
+    network_type VARCHAR(20),
+    queue_depth INTEGER DEFAULT 0,
+    app_version VARCHAR(50),
+    samples INTEGER DEFAULT 1,
+    online_minutes INTEGER DEFAULT 0
+);
+CREATE INDEX idx_device_telemetry_device_time ON device_telemetry(device_id, recorded_at);
+CREATE INDEX idx_device_telemetry_resolution_time ON device_telemetry(resolution, recorded_at);
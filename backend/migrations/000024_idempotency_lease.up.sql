@@ -0,0 +1,3 @@
+-- Lease on in-progress idempotency keys so a crashed request does not hold its key for the whole retention window
+
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Anchor of a recurring schedule; monthly runs are counted from it so a schedule on the 31st keeps to month ends
+
+ALTER TABLE scheduled_messages ADD COLUMN anchor_at TIMESTAMP;
+UPDATE scheduled_messages SET anchor_at = next_run_at WHERE anchor_at IS NULL;
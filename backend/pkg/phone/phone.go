@@ -0,0 +1,210 @@
+// Package phone parses phone numbers as users type them into E.164, the
+// form the gateway stores and compares them in.
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+type NumberType string
+
+const (
+	TypeMobile    NumberType = "mobile"
+	TypeFixedLine NumberType = "fixed_line"
+	TypeUnknown   NumberType = "unknown"
+)
+
+// E.164 allows at most 15 digits including the country calling code.
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+var (
+	ErrEmpty              = errors.New("phone number is empty")
+	ErrInvalidCharacters  = errors.New("phone number contains invalid characters")
+	ErrMissingCountryCode = errors.New("phone number has no country code and no default country is set")
+	ErrUnknownCountryCode = errors.New("phone number has an unknown country code")
+	ErrTooShort           = errors.New("phone number is too short")
+	ErrTooLong            = errors.New("phone number is too long")
+	ErrNotAllocated       = errors.New("phone number is not in a range used by the country")
+)
+
+type Number struct {
+	// E164 is the canonical form, e.g. +84912345678
+	E164        string `json:"e164"`
+	CallingCode string `json:"calling_code"`
+	// Region is empty for calling codes outside the built-in numbering plans
+	Region   string     `json:"region,omitempty"`
+	National string     `json:"national"`
+	Type     NumberType `json:"type"`
+}
+
+// Parse reads raw in international form ("+84 912 345 678", "0084912345678")
+// or in the national form of defaultRegion ("0912 345 678"). Spaces, dashes,
+// dots and parentheses are ignored.
+func Parse(raw, defaultRegion string) (*Number, error) {
+	international, digits, err := clean(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	def := regionsByCode[strings.ToUpper(defaultRegion)]
+
+	if !international && def != nil {
+		// "84912345678" typed without the plus sign
+		if rest, ok := strings.CutPrefix(digits, def.CallingCode); ok && len(digits) > def.MaxLength &&
+			len(rest) >= def.MinLength && len(rest) <= def.MaxLength {
+			return parseNational(def, rest)
+		}
+		return parseNational(def, strings.TrimPrefix(digits, def.TrunkPrefix))
+	}
+
+	if !international {
+		return nil, ErrMissingCountryCode
+	}
+	return parseInternational(digits, def)
+}
+
+// NationalFormat returns the number as dialled inside its country, trunk
+// prefix included ("0912345678"). Numbers outside the built-in plans are
+// returned in E.164.
+func (n *Number) NationalFormat() string {
+	r := regionsByCode[n.Region]
+	if r == nil {
+		return n.E164
+	}
+	return r.TrunkPrefix + n.National
+}
+
+func clean(raw string) (bool, string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return false, "", ErrEmpty
+	}
+
+	international := false
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return false, "", ErrInvalidCharacters
+		}
+	}
+
+	digits := b.String()
+	if !international {
+		if rest, ok := strings.CutPrefix(digits, "00"); ok {
+			international, digits = true, rest
+		}
+	}
+	if digits == "" {
+		return false, "", ErrEmpty
+	}
+	return international, digits, nil
+}
+
+func parseInternational(digits string, def *region) (*Number, error) {
+	if len(digits) < minE164Digits {
+		return nil, ErrTooShort
+	}
+	if len(digits) > maxE164Digits {
+		return nil, ErrTooLong
+	}
+
+	for size := 1; size <= 3; size++ {
+		candidates := regionsByCalling[digits[:size]]
+		if len(candidates) == 0 {
+			continue
+		}
+
+		// Regions sharing a calling code (US/CA) are told apart by the
+		// default region only
+		r := candidates[0]
+		for _, c := range candidates {
+			if def != nil && c.Code == def.Code {
+				r = c
+			}
+		}
+		// "+44 (0)20 ..." keeps the trunk prefix after the country code
+		national := digits[size:]
+		if r.TrunkPrefix != "" && len(national) > r.MaxLength {
+			national = strings.TrimPrefix(national, r.TrunkPrefix)
+		}
+		return parseNational(r, national)
+	}
+
+	if digits[0] == '0' {
+		return nil, ErrUnknownCountryCode
+	}
+
+	return &Number{
+		E164:     "+" + digits,
+		National: digits,
+		Type:     TypeUnknown,
+	}, nil
+}
+
+func parseNational(r *region, national string) (*Number, error) {
+	if len(national) < r.MinLength {
+		return nil, ErrTooShort
+	}
+	if len(national) > r.MaxLength {
+		return nil, ErrTooLong
+	}
+
+	numberType := classify(r, national)
+	if numberType == "" {
+		return nil, ErrNotAllocated
+	}
+
+	// "0912 345 6789" has a valid VN length but not for a mobile number
+	bounds := r.lengthFor(numberType)
+	if len(national) < bounds.Min {
+		return nil, ErrTooShort
+	}
+	if len(national) > bounds.Max {
+		return nil, ErrTooLong
+	}
+
+	return &Number{
+		E164:        "+" + r.CallingCode + national,
+		CallingCode: r.CallingCode,
+		Region:      r.Code,
+		National:    national,
+		Type:        numberType,
+	}, nil
+}
+
+// classify returns "" for numbers outside every known range of a region
+// whose plan lists both mobile and fixed-line prefixes.
+func classify(r *region, national string) NumberType {
+	if hasAnyPrefix(national, r.MobilePrefixes) {
+		return TypeMobile
+	}
+	if hasAnyPrefix(national, r.FixedPrefixes) {
+		return TypeFixedLine
+	}
+	if len(r.MobilePrefixes) > 0 && len(r.FixedPrefixes) > 0 {
+		return ""
+	}
+	if national[0] == '0' {
+		return ""
+	}
+	return TypeUnknown
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package phone
+
+// region describes the numbering plan of one country closely enough to
+// validate lengths and tell mobile numbers from fixed lines.
+type region struct {
+	Code        string
+	CallingCode string
+	// TrunkPrefix is dialled before national numbers inside the country
+	TrunkPrefix string
+	// MinLength and MaxLength bound the national significant number
+	MinLength int
+	MaxLength int
+	// MobileLength and FixedLength narrow those bounds for one number type;
+	// zero means the region-wide bounds apply
+	MobileLength lengthRange
+	FixedLength  lengthRange
+	// MobilePrefixes start national significant numbers of mobile lines; nil
+	// when the plan does not separate them (NANP)
+	MobilePrefixes []string
+	// FixedPrefixes start fixed-line numbers; anything matching neither list
+	// is rejected when both are set
+	FixedPrefixes []string
+}
+
+type lengthRange struct {
+	Min, Max int
+}
+
+var regions = []region{
+	{Code: "VN", CallingCode: "84", TrunkPrefix: "0", MinLength: 9, MaxLength: 10,
+		MobileLength: lengthRange{9, 9}, FixedLength: lengthRange{10, 10},
+		MobilePrefixes: []string{"3", "5", "7", "8", "9"}, FixedPrefixes: []string{"2"}},
+	{Code: "US", CallingCode: "1", TrunkPrefix: "1", MinLength: 10, MaxLength: 10},
+	{Code: "CA", CallingCode: "1", TrunkPrefix: "1", MinLength: 10, MaxLength: 10},
+	{Code: "GB", CallingCode: "44", TrunkPrefix: "0", MinLength: 9, MaxLength: 10,
+		MobileLength:   lengthRange{10, 10},
+		MobilePrefixes: []string{"7"}, FixedPrefixes: []string{"1", "2", "3", "5", "8", "9"}},
+	{Code: "AU", CallingCode: "61", TrunkPrefix: "0", MinLength: 9, MaxLength: 9,
+		MobilePrefixes: []string{"4"}, FixedPrefixes: []string{"2", "3", "7", "8"}},
+	{Code: "SG", CallingCode: "65", MinLength: 8, MaxLength: 8,
+		MobilePrefixes: []string{"8", "9"}, FixedPrefixes: []string{"6"}},
+	{Code: "TH", CallingCode: "66", TrunkPrefix: "0", MinLength: 8, MaxLength: 9,
+		MobileLength: lengthRange{9, 9}, FixedLength: lengthRange{8, 8},
+		MobilePrefixes: []string{"6", "8", "9"}, FixedPrefixes: []string{"2", "3", "4", "5", "7"}},
+	{Code: "MY", CallingCode: "60", TrunkPrefix: "0", MinLength: 8, MaxLength: 10,
+		MobileLength: lengthRange{9, 10}, FixedLength: lengthRange{8, 9},
+		MobilePrefixes: []string{"1"}, FixedPrefixes: []string{"3", "4", "5", "6", "7", "8", "9"}},
+	{Code: "ID", CallingCode: "62", TrunkPrefix: "0", MinLength: 8, MaxLength: 12,
+		MobileLength: lengthRange{9, 12}, FixedLength: lengthRange{8, 11},
+		MobilePrefixes: []string{"8"}, FixedPrefixes: []string{"2", "3", "4", "5", "6", "7", "9"}},
+	{Code: "PH", CallingCode: "63", TrunkPrefix: "0", MinLength: 8, MaxLength: 10,
+		MobileLength: lengthRange{10, 10}, FixedLength: lengthRange{8, 9},
+		MobilePrefixes: []string{"9"}, FixedPrefixes: []string{"2", "3", "4", "5", "6", "7", "8"}},
+	{Code: "KH", CallingCode: "855", TrunkPrefix: "0", MinLength: 8, MaxLength: 9},
+	{Code: "LA", CallingCode: "856", TrunkPrefix: "0", MinLength: 8, MaxLength: 10,
+		MobileLength:   lengthRange{10, 10},
+		MobilePrefixes: []string{"20"}},
+	{Code: "CN", CallingCode: "86", TrunkPrefix: "0", MinLength: 9, MaxLength: 11,
+		MobileLength:   lengthRange{11, 11},
+		MobilePrefixes: []string{"13", "14", "15", "16", "17", "18", "19"}},
+	{Code: "JP", CallingCode: "81", TrunkPrefix: "0", MinLength: 9, MaxLength: 10,
+		MobileLength:   lengthRange{10, 10},
+		MobilePrefixes: []string{"70", "80", "90"}},
+	{Code: "KR", CallingCode: "82", TrunkPrefix: "0", MinLength: 8, MaxLength: 10,
+		MobileLength:   lengthRange{10, 10},
+		MobilePrefixes: []string{"10"}},
+	{Code: "TW", CallingCode: "886", TrunkPrefix: "0", MinLength: 8, MaxLength: 9,
+		MobileLength:   lengthRange{9, 9},
+		MobilePrefixes: []string{"9"}},
+	{Code: "HK", CallingCode: "852", MinLength: 8, MaxLength: 8,
+		MobilePrefixes: []string{"5", "6", "7", "9"}, FixedPrefixes: []string{"2", "3"}},
+	{Code: "IN", CallingCode: "91", TrunkPrefix: "0", MinLength: 10, MaxLength: 10,
+		MobilePrefixes: []string{"6", "7", "8", "9"}},
+	{Code: "DE", CallingCode: "49", TrunkPrefix: "0", MinLength: 6, MaxLength: 13,
+		MobileLength:   lengthRange{10, 11},
+		MobilePrefixes: []string{"15", "16", "17"}},
+	{Code: "FR", CallingCode: "33", TrunkPrefix: "0", MinLength: 9, MaxLength: 9,
+		MobilePrefixes: []string{"6", "7"}, FixedPrefixes: []string{"1", "2", "3", "4", "5", "8", "9"}},
+}
+
+var (
+	regionsByCode    = make(map[string]*region)
+	regionsByCalling = make(map[string][]*region)
+)
+
+func init() {
+	for i := range regions {
+		r := &regions[i]
+		regionsByCode[r.Code] = r
+		regionsByCalling[r.CallingCode] = append(regionsByCalling[r.CallingCode], r)
+	}
+}
+
+// lengthFor returns the bounds that apply to national numbers of the given
+// type.
+func (r *region) lengthFor(numberType NumberType) lengthRange {
+	switch {
+	case numberType == TypeMobile && r.MobileLength.Max > 0:
+		return r.MobileLength
+	case numberType == TypeFixedLine && r.FixedLength.Max > 0:
+		return r.FixedLength
+	}
+	return lengthRange{r.MinLength, r.MaxLength}
+}
+
+// SupportedRegion reports whether code is a region Parse can use as the
+// default for national numbers.
+func SupportedRegion(code string) bool {
+	_, ok := regionsByCode[code]
+	return ok
+}
@@ -0,0 +1,152 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRegions(t *testing.T) {
+	tests := []struct {
+		region   string
+		raw      string
+		wantE164 string
+		wantType NumberType
+		wantErr  error
+	}{
+		{"VN", "0912 345 678", "+84912345678", TypeMobile, nil},
+		{"VN", "+84 912 345 678", "+84912345678", TypeMobile, nil},
+		{"VN", "84912345678", "+84912345678", TypeMobile, nil},
+		{"VN", "0912345678 9", "", "", ErrTooLong},
+		{"VN", "+84 9123 456 789", "", "", ErrTooLong},
+		{"VN", "091234567", "", "", ErrTooShort},
+		{"VN", "024 3826 4626", "+842438264626", TypeFixedLine, nil},
+		{"VN", "024 382 6462", "", "", ErrTooShort},
+		{"VN", "0112345678", "", "", ErrNotAllocated},
+
+		{"US", "(415) 555-2671", "+14155552671", TypeUnknown, nil},
+		{"US", "1 415 555 2671", "+14155552671", TypeUnknown, nil},
+		{"US", "415 555 267", "", "", ErrTooShort},
+		{"CA", "+1 604 555 0199", "+16045550199", TypeUnknown, nil},
+
+		{"GB", "07400 123456", "+447400123456", TypeMobile, nil},
+		{"GB", "+44 (0)20 7946 0958", "+442079460958", TypeFixedLine, nil},
+		{"GB", "01697 73456", "+44169773456", TypeFixedLine, nil},
+		{"GB", "07400 12345", "", "", ErrTooShort},
+
+		{"AU", "0412 345 678", "+61412345678", TypeMobile, nil},
+		{"AU", "02 9374 4000", "+61293744000", TypeFixedLine, nil},
+		{"AU", "0412 345 67", "", "", ErrTooShort},
+
+		{"SG", "9123 4567", "+6591234567", TypeMobile, nil},
+		{"SG", "6123 4567", "+6561234567", TypeFixedLine, nil},
+		{"SG", "4123 4567", "", "", ErrNotAllocated},
+
+		{"TH", "081 234 5678", "+66812345678", TypeMobile, nil},
+		{"TH", "02 123 4567", "+6621234567", TypeFixedLine, nil},
+		{"TH", "081 234 567", "", "", ErrTooShort},
+		{"TH", "02 123 45678", "", "", ErrTooLong},
+
+		{"MY", "012 345 6789", "+60123456789", TypeMobile, nil},
+		{"MY", "011 2345 6789", "+601123456789", TypeMobile, nil},
+		{"MY", "03 2345 6789", "+60323456789", TypeFixedLine, nil},
+		{"MY", "012 345 678", "", "", ErrTooShort},
+		{"MY", "03 2345 67890", "", "", ErrTooLong},
+
+		{"ID", "0812 3456 7890", "+6281234567890", TypeMobile, nil},
+		{"ID", "021 2345 6789", "+622123456789", TypeFixedLine, nil},
+		{"ID", "0812 3456", "", "", ErrTooShort},
+		{"ID", "021 2345 678901", "", "", ErrTooLong},
+
+		{"PH", "0917 123 4567", "+639171234567", TypeMobile, nil},
+		{"PH", "02 8123 4567", "+63281234567", TypeFixedLine, nil},
+		{"PH", "0917 123 456", "", "", ErrTooShort},
+		{"PH", "02 8123 45678", "", "", ErrTooLong},
+
+		{"KH", "012 345 678", "+85512345678", TypeUnknown, nil},
+		{"KH", "012 345", "", "", ErrTooShort},
+
+		{"LA", "020 5555 1234", "+8562055551234", TypeMobile, nil},
+		{"LA", "020 5555 123", "", "", ErrTooShort},
+		{"LA", "021 212 345", "+85621212345", TypeUnknown, nil},
+
+		{"CN", "138 0013 8000", "+8613800138000", TypeMobile, nil},
+		{"CN", "138 0013 800", "", "", ErrTooShort},
+		{"CN", "010 1234 5678", "+861012345678", TypeUnknown, nil},
+
+		{"JP", "090 1234 5678", "+819012345678", TypeMobile, nil},
+		{"JP", "090 1234 567", "", "", ErrTooShort},
+		{"JP", "03 1234 5678", "+81312345678", TypeUnknown, nil},
+
+		{"KR", "010 1234 5678", "+821012345678", TypeMobile, nil},
+		{"KR", "010 1234 567", "", "", ErrTooShort},
+		{"KR", "02 312 3456", "+8223123456", TypeUnknown, nil},
+
+		{"TW", "0912 345 678", "+886912345678", TypeMobile, nil},
+		{"TW", "0912 345 67", "", "", ErrTooShort},
+		{"TW", "02 2345 6789", "+886223456789", TypeUnknown, nil},
+
+		{"HK", "9123 4567", "+85291234567", TypeMobile, nil},
+		{"HK", "2123 4567", "+85221234567", TypeFixedLine, nil},
+		{"HK", "8123 4567", "", "", ErrNotAllocated},
+
+		{"IN", "098765 43210", "+919876543210", TypeMobile, nil},
+		{"IN", "98765 4321", "", "", ErrTooShort},
+
+		{"DE", "01512 3456789", "+4915123456789", TypeMobile, nil},
+		{"DE", "0170 1234567", "+491701234567", TypeMobile, nil},
+		{"DE", "0170 123456", "", "", ErrTooShort},
+		{"DE", "030 123456", "+4930123456", TypeUnknown, nil},
+
+		{"FR", "06 12 34 56 78", "+33612345678", TypeMobile, nil},
+		{"FR", "01 23 45 67 89", "+33123456789", TypeFixedLine, nil},
+		{"FR", "06 12 34 56 7", "", "", ErrTooShort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region+" "+tt.raw, func(t *testing.T) {
+			got, err := Parse(tt.raw, tt.region)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %v (got %+v)", err, tt.wantErr, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			if got.E164 != tt.wantE164 {
+				t.Errorf("E164 = %s, want %s", got.E164, tt.wantE164)
+			}
+			if got.Type != tt.wantType {
+				t.Errorf("Type = %s, want %s", got.Type, tt.wantType)
+			}
+			if got.Region != tt.region {
+				t.Errorf("Region = %s, want %s", got.Region, tt.region)
+			}
+		})
+	}
+}
+
+// TestRegionTable checks every region is covered above and that per-type
+// bounds stay inside the region-wide ones.
+func TestRegionTable(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, r := range regions {
+		if seen[r.Code] {
+			t.Errorf("region %s listed twice", r.Code)
+		}
+		seen[r.Code] = true
+
+		if r.MinLength < 1 || r.MinLength > r.MaxLength {
+			t.Errorf("%s: invalid bounds %d..%d", r.Code, r.MinLength, r.MaxLength)
+		}
+		for name, bounds := range map[string]lengthRange{"mobile": r.MobileLength, "fixed": r.FixedLength} {
+			if bounds == (lengthRange{}) {
+				continue
+			}
+			if bounds.Min < r.MinLength || bounds.Max > r.MaxLength || bounds.Min > bounds.Max {
+				t.Errorf("%s: %s bounds %d..%d outside %d..%d", r.Code, name, bounds.Min, bounds.Max, r.MinLength, r.MaxLength)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package sms implements the parts of the SMS wire format the gateway needs
+// to reason about: which alphabet a text is sent in and how many
+// concatenated segments it takes.
+package sms
+
+import (
+	"unicode/utf16"
+)
+
+type Encoding string
+
+const (
+	EncodingGSM7 Encoding = "GSM-7"
+	EncodingUCS2 Encoding = "UCS-2"
+)
+
+// Segment capacities. Concatenated messages lose room to the user data
+// header: 7 septets for GSM-7, 3 UTF-16 code units for UCS-2.
+const (
+	gsm7SingleSegment = 160
+	gsm7MultiSegment  = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet, minus the escape code.
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds the characters reached through the escape code; each
+// costs two septets.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+var (
+	basicSet     = runeSet(gsm7Basic)
+	extensionSet = runeSet(gsm7Extension)
+)
+
+// Info describes how a text goes over the air.
+type Info struct {
+	Encoding Encoding `json:"encoding"`
+	// Units is the length in septets for GSM-7 and UTF-16 code units for UCS-2
+	Units    int `json:"units"`
+	Segments int `json:"segments"`
+}
+
+// Analyze picks the encoding a phone would use for text and counts its
+// segments.
+func Analyze(text string) Info {
+	if IsGSM7(text) {
+		return analyzeGSM7(text)
+	}
+	return analyzeUCS2(text)
+}
+
+// IsGSM7 reports whether every character of text exists in the GSM 03.38
+// default alphabet or its extension table.
+func IsGSM7(text string) bool {
+	for _, r := range text {
+		if !basicSet[r] && !extensionSet[r] {
+			return false
+		}
+	}
+	return true
+}
+
+func analyzeGSM7(text string) Info {
+	widths := make([]int, 0, len(text))
+	for _, r := range text {
+		if extensionSet[r] {
+			widths = append(widths, 2)
+		} else {
+			widths = append(widths, 1)
+		}
+	}
+
+	units, segments := pack(widths, gsm7SingleSegment, gsm7MultiSegment)
+	return Info{Encoding: EncodingGSM7, Units: units, Segments: segments}
+}
+
+func analyzeUCS2(text string) Info {
+	widths := make([]int, 0, len(text))
+	for _, r := range text {
+		widths = append(widths, utf16.RuneLen(r))
+	}
+
+	units, segments := pack(widths, ucs2SingleSegment, ucs2MultiSegment)
+	return Info{Encoding: EncodingUCS2, Units: units, Segments: segments}
+}
+
+// pack counts segments for characters of the given widths. Multi-unit
+// characters (escape sequences, surrogate pairs) are never split across two
+// segments, so a segment may end short of its capacity.
+func pack(widths []int, single, multi int) (int, int) {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	if total <= single {
+		return total, 1
+	}
+
+	segments, used := 1, 0
+	for _, w := range widths {
+		if used+w > multi {
+			segments++
+			used = 0
+		}
+		used += w
+	}
+	return total, segments
+}
+
+func runeSet(chars string) map[rune]bool {
+	set := make(map[rune]bool, len(chars))
+	for _, r := range chars {
+		set[r] = true
+	}
+	return set
+}
@@ -0,0 +1,57 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want Info
+	}{
+		{"empty", "", Info{EncodingGSM7, 0, 0}},
+		{"short GSM-7", "hello", Info{EncodingGSM7, 5, 1}},
+		{"full GSM-7 segment", strings.Repeat("a", 160), Info{EncodingGSM7, 160, 1}},
+		{"two GSM-7 segments", strings.Repeat("a", 161), Info{EncodingGSM7, 161, 2}},
+		{"extension costs two septets", "€", Info{EncodingGSM7, 2, 1}},
+		{"extension fills a segment", strings.Repeat("€", 80), Info{EncodingGSM7, 160, 1}},
+		{"escape pair is not split", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10), Info{EncodingGSM7, 164, 2}},
+		{"escape pair moves to the next segment", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), Info{EncodingGSM7, 306, 3}},
+		{"vietnamese is UCS-2", "Việt Nam", Info{EncodingUCS2, 8, 1}},
+		{"full UCS-2 segment", strings.Repeat("ệ", 70), Info{EncodingUCS2, 70, 1}},
+		{"two UCS-2 segments", strings.Repeat("ệ", 71), Info{EncodingUCS2, 71, 2}},
+		{"emoji is a surrogate pair", "😀", Info{EncodingUCS2, 2, 1}},
+		{"surrogate pair is not split", strings.Repeat("ệ", 66) + "😀" + strings.Repeat("ệ", 5), Info{EncodingUCS2, 73, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Analyze(tt.text); got != tt.want {
+				t.Errorf("Analyze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsGSM7(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"", true},
+		{"Hello @ £5", true},
+		{"{braces} ^ ~ | \\", true},
+		{"ÄÖÑÜ äöñü àèéùìò", true},
+		{"Việt", false},
+		{"â", false},
+		{"😀", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsGSM7(tt.text); got != tt.want {
+			t.Errorf("IsGSM7(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
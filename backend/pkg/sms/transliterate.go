@@ -0,0 +1,70 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// replacements covers characters that lose nothing meaningful when swapped
+// for a GSM-7 look-alike but have no decomposition to strip.
+var replacements = map[rune]string{
+	'đ': "d", 'Đ': "D",
+	'ł': "l", 'Ł': "L",
+	'‘': "'", '’': "'", '‚': "'", '‛': "'",
+	'“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '‐': "-", '−': "-",
+	'…': "...",
+	' ': " ", ' ': " ", '​': "",
+	'\t': " ",
+}
+
+// Transliterate rewrites text so it fits the GSM-7 alphabet: diacritics are
+// stripped unless GSM-7 has the accented letter ("Xin chào Việt Nam" becomes
+// "Xin chào Viet Nam"), typographic punctuation is replaced with ASCII, and
+// anything left over becomes '?'. Characters already in GSM-7 are kept as
+// they are. Text is NFC-normalized first, so decomposed (NFD) input gives the
+// same result as its composed form.
+func Transliterate(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for _, r := range norm.NFC.String(text) {
+		if basicSet[r] || extensionSet[r] {
+			b.WriteRune(r)
+			continue
+		}
+
+		if replacement, ok := replacements[r]; ok {
+			b.WriteString(replacement)
+			continue
+		}
+
+		// a mark that has no precomposed form with its base letter
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		stripped := stripMarks(r)
+		if stripped != "" && IsGSM7(stripped) {
+			b.WriteString(stripped)
+			continue
+		}
+
+		b.WriteRune('?')
+	}
+
+	return b.String()
+}
+
+// stripMarks decomposes r and drops its combining marks.
+func stripMarks(r rune) string {
+	var b strings.Builder
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			b.WriteRune(d)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package sms
+
+import (
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"vietnamese NFC", "Xin chào Việt Nam", "Xin chào Viet Nam"},
+		{"vietnamese NFD", norm.NFD.String("Xin chào Việt Nam"), "Xin chào Viet Nam"},
+		{"d with stroke", "Đà Nẵng đẹp", "Dà Nang dep"},
+		{"GSM-7 accents are kept", "café à Zürich", "café à Zürich"},
+		{"GSM-7 accents in NFD are kept", norm.NFD.String("café à Zürich"), "café à Zürich"},
+		{"mark without a precomposed form", "q́", "q"},
+		{"typographic punctuation", "“quoted” – it’s…", "\"quoted\" - it's..."},
+		{"extension characters", "€10 [ok]", "€10 [ok]"},
+		{"no GSM-7 equivalent", "你好", "??"},
+		{"emoji", "hi 😀", "hi ?"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Transliterate(tt.in)
+			if got != tt.want {
+				t.Errorf("Transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !IsGSM7(got) {
+				t.Errorf("Transliterate(%q) = %q is not GSM-7", tt.in, got)
+			}
+		})
+	}
+}